@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"signalone/cmd/config"
 	"signalone/pkg/controllers"
@@ -56,6 +57,12 @@ func main() {
 	}
 	issuesCollectionClient := appDbClient.Database(cfg.ApplicationDbName).Collection(cfg.ApplicationIssuesCollectionName)
 	usersCollectionClient := appDbClient.Database(cfg.ApplicationDbName).Collection(cfg.ApplicationUsersCollectionName)
+	muteRulesCollectionClient := appDbClient.Database(cfg.ApplicationDbName).Collection(cfg.ApplicationMuteRulesCollectionName)
+	routingRulesCollectionClient := appDbClient.Database(cfg.ApplicationDbName).Collection(cfg.ApplicationRoutingRulesCollectionName)
+	batchItemsCollectionClient := appDbClient.Database(cfg.ApplicationDbName).Collection(cfg.ApplicationBatchItemsCollectionName)
+	if err := controllers.EnsureBatchItemIndexes(context.Background(), batchItemsCollectionClient); err != nil {
+		fmt.Println("Error: ", err)
+	}
 
 	savedAnalysisDbClient, err := mongo.Connect(
 		context.Background(),
@@ -70,6 +77,9 @@ func main() {
 		issuesCollectionClient,
 		usersCollectionClient,
 		savedAnalysisCollectionClient,
+		muteRulesCollectionClient,
+		routingRulesCollectionClient,
+		batchItemsCollectionClient,
 	)
 
 	//authController TBD
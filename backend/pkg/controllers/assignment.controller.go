@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"signalone/pkg/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AssignIssue godoc
+// @Summary Assign an issue.
+// @Description Assign an issue of the user to a user, or unassign it when the assignee is empty.
+// @Tags issues
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the issue"
+// @Param issueAssignRequest body models.IssueAssignRequest true "Assignee"
+// @Success 200 {object} models.Issue
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /issues/{id}/assignee [put]
+func (c *MainController) AssignIssue(ctx *gin.Context) {
+	var assignReq models.IssueAssignRequest
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&assignReq); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	issue, status, err := c.assignIssue(ctx, ctx.Param("id"), userId, assignReq.Assignee)
+	if err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, issue)
+}
+
+// assignIssue assigns an issue of the user to the user with the assignee id,
+// or unassigns it when the assignee id is empty, and returns the updated
+// issue. The assignee is not looked up, so assigning an issue answers the same
+// whether or not the user exists and does not reveal their name.
+func (c *MainController) assignIssue(ctx *gin.Context, id string, userId string, assigneeId string) (models.Issue, int, error) {
+	var issue models.Issue
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{"lastActivityAt": now},
+	}
+	if assigneeId == "" {
+		update["$unset"] = bson.M{"assignee": "", "assigneeName": "", "assignedAt": ""}
+	} else {
+		update["$set"] = bson.M{
+			"assignee":       assigneeId,
+			"assignedAt":     now,
+			"lastActivityAt": now,
+		}
+		update["$unset"] = bson.M{"assigneeName": ""}
+	}
+
+	err := c.issuesCollection.FindOneAndUpdate(ctx, ownedIssueFilter(id, userId), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&issue)
+	if err != nil {
+		return issue, http.StatusNotFound, errors.New("Not found")
+	}
+	return issue, http.StatusOK, nil
+}
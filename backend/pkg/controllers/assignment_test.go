@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+	"signalone/pkg/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestAssignAnotherUsersIssueIsNotFound(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("assign", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+
+		rec := serve(t, c.AssignIssue, "PUT", "/issues/:id/assignee", "/issues/issue-1/assignee", "user-b",
+			models.IssueAssignRequest{Assignee: "user-b"})
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body)
+		}
+		assertIssuesOwnedBy(t, mt, "user-b")
+	})
+}
+
+func TestAssignmentDoesNotLookUpTheAssignee(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("assign", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.M{"_id": "issue-1", "userId": "user-a", "assignee": "unknown"}}))
+
+		rec := serve(t, c.AssignIssue, "PUT", "/issues/:id/assignee", "/issues/issue-1/assignee", "user-a",
+			models.IssueAssignRequest{Assignee: "unknown"})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		if lookups := countCommands(mt, "find", "users"); lookups != 0 {
+			t.Errorf("expected the assignee not to be looked up, got %d lookups", lookups)
+		}
+		assertIssuesOwnedBy(t, mt, "user-a")
+	})
+}
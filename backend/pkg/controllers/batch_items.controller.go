@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"signalone/pkg/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureBatchItemIndexes creates the index that removes batch items once no
+// agent uploads them again, BATCH_ITEM_RETENTION after they were claimed.
+func EnsureBatchItemIndexes(ctx context.Context, batchItemsCollection *mongo.Collection) error {
+	_, err := batchItemsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "claimedAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(BATCH_ITEM_RETENTION.Seconds())),
+	})
+	return err
+}
+
+// processBatchItemOnce processes an item of a batch upload unless it was
+// processed before, in which case it returns the outcome of the first time.
+// An agent that did not get the response of an upload, e.g. because it
+// timed out, uploads the items again. Items are told apart per user, so the
+// ids one agent picks cannot collide with those of another.
+func (c *MainController) processBatchItemOnce(ctx *gin.Context, userId string, item BatchIngestionItem) BatchIngestionResult {
+	if item.Id == "" {
+		return BatchIngestionResult{Status: http.StatusBadRequest, Error: "Item id is required"}
+	}
+	key := batchItemKey(userId, item.Id)
+	previous, claimed, err := c.claimBatchItem(ctx, key)
+	if err != nil {
+		return BatchIngestionResult{Id: item.Id, Status: http.StatusInternalServerError, Error: err.Error()}
+	}
+	if !claimed {
+		if previous.State == models.BatchItemStateProcessed {
+			return BatchIngestionResult{Id: item.Id, Status: http.StatusOK, IssueId: previous.IssueId, Count: previous.Count}
+		}
+		return BatchIngestionResult{Id: item.Id, Status: http.StatusServiceUnavailable, Error: "Item is being processed"}
+	}
+
+	result := c.processBatchItem(ctx, userId, item)
+	if result.Status == http.StatusOK {
+		err = c.recordBatchItem(key, result)
+	} else {
+		err = c.releaseBatchItem(key)
+	}
+	if err != nil {
+		fmt.Println("Error: ", err)
+	}
+	return result
+}
+
+// batchItemKey identifies an item uploaded by the user.
+func batchItemKey(userId string, id string) string {
+	return userId + "/" + id
+}
+
+// claimBatchItem marks an item as being processed and reports whether the
+// caller got it. Otherwise it returns the item as another upload left it.
+// The claim of an upload that stopped processing the item is taken over
+// after BATCH_ITEM_CLAIM_TIMEOUT.
+func (c *MainController) claimBatchItem(ctx *gin.Context, id string) (models.BatchItem, bool, error) {
+	var previous models.BatchItem
+	now := time.Now()
+
+	_, err := c.batchItemsCollection.InsertOne(ctx, models.BatchItem{
+		Id:        id,
+		State:     models.BatchItemStateProcessing,
+		ClaimedAt: now,
+	})
+	if err == nil {
+		return previous, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return previous, false, err
+	}
+
+	err = c.batchItemsCollection.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":       id,
+			"state":     models.BatchItemStateProcessing,
+			"claimedAt": bson.M{"$lt": now.Add(-BATCH_ITEM_CLAIM_TIMEOUT)},
+		},
+		bson.M{"$set": bson.M{"claimedAt": now}},
+	).Decode(&previous)
+	if err == nil {
+		return previous, true, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return previous, false, err
+	}
+	err = c.batchItemsCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&previous)
+	return previous, false, err
+}
+
+// recordBatchItem stores the outcome of a processed item. It does not use
+// the request context, as the item was processed even if the agent gave up
+// on the request meanwhile.
+func (c *MainController) recordBatchItem(id string, result BatchIngestionResult) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	now := time.Now()
+	_, err := c.batchItemsCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"state":       models.BatchItemStateProcessed,
+			"processedAt": now,
+			"issueId":     result.IssueId,
+			"count":       result.Count,
+		},
+	})
+	return err
+}
+
+// releaseBatchItem drops the claim of an item that failed, so uploading it
+// again processes it again.
+func (c *MainController) releaseBatchItem(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := c.batchItemsCollection.DeleteOne(ctx, bson.M{"_id": id, "state": models.BatchItemStateProcessing})
+	return err
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"signalone/cmd/config"
+	"signalone/pkg/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func recoveryBatch(t *testing.T, ids ...string) BatchIngestionPayload {
+	t.Helper()
+	return recoveryBatchOf(t, "user-a", ids...)
+}
+
+func recoveryBatchOf(t *testing.T, userId string, ids ...string) BatchIngestionPayload {
+	t.Helper()
+	payload, err := json.Marshal(RecoveryPayload{UserId: userId, ContainerName: "/api", Reason: models.RecoveryReasonRestarted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := BatchIngestionPayload{}
+	for _, id := range ids {
+		batch.Items = append(batch.Items, BatchIngestionItem{Id: id, Kind: BATCH_ITEM_KIND_RECOVERY, Payload: payload})
+	}
+	return batch
+}
+
+func batchResults(t *testing.T, body []byte) []BatchIngestionResult {
+	t.Helper()
+	var response struct {
+		Results []BatchIngestionResult `json:"results"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Results
+}
+
+// duplicateKey answers an insert of an item that was claimed before.
+func duplicateKey() bson.D {
+	return mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"})
+}
+
+// notModified answers a findAndModify that matched nothing.
+func notModified() bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil})
+}
+
+func TestBatchItemIsProcessedAndRecorded(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("new item", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), modified(1), modified(1))
+
+		rec := serve(t, c.BatchIngestionTask, "PUT", "/issues/batch", "/issues/batch", "user-a", recoveryBatch(t, "item-1"))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		results := batchResults(t, rec.Body.Bytes())
+		if len(results) != 1 || results[0].Status != http.StatusOK || results[0].Count != 1 {
+			t.Fatalf("expected the recovery to resolve an issue, got %+v", results)
+		}
+		if inserts := countCommands(mt, "insert", "batchitems"); inserts != 1 {
+			t.Errorf("expected the item to be claimed, got %d inserts", inserts)
+		}
+		for _, command := range sentCommands(mt) {
+			if command.name == "insert" && command.filter.Lookup("_id").StringValue() != "user-a/item-1" {
+				t.Errorf("expected the item to be claimed for the user, got %s", command.filter)
+			}
+		}
+		if updates := countCommands(mt, "update", "batchitems"); updates != 1 {
+			t.Errorf("expected the outcome to be recorded, got %d updates", updates)
+		}
+	})
+}
+
+func TestBatchItemUploadedAgainIsNotProcessedAgain(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("processed item", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(
+			duplicateKey(),
+			notModified(),
+			found("batchitems", models.BatchItem{Id: "item-1", State: models.BatchItemStateProcessed, IssueId: "issue-1"}),
+		)
+
+		rec := serve(t, c.BatchIngestionTask, "PUT", "/issues/batch", "/issues/batch", "user-a", recoveryBatch(t, "item-1"))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		results := batchResults(t, rec.Body.Bytes())
+		if len(results) != 1 || results[0].Status != http.StatusOK || results[0].IssueId != "issue-1" {
+			t.Fatalf("expected the outcome of the first upload, got %+v", results)
+		}
+		if updates := countCommands(mt, "update", "issues"); updates != 0 {
+			t.Errorf("expected the item not to be processed again, got %d updates of issues", updates)
+		}
+	})
+	mt.Run("item being processed", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(
+			duplicateKey(),
+			notModified(),
+			found("batchitems", models.BatchItem{Id: "item-1", State: models.BatchItemStateProcessing, ClaimedAt: time.Now()}),
+		)
+
+		rec := serve(t, c.BatchIngestionTask, "PUT", "/issues/batch", "/issues/batch", "user-a", recoveryBatch(t, "item-1"))
+
+		results := batchResults(t, rec.Body.Bytes())
+		if len(results) != 1 || results[0].Status != http.StatusServiceUnavailable {
+			t.Fatalf("expected the item to be retried later, got %+v", results)
+		}
+		if updates := countCommands(mt, "update", "issues"); updates != 0 {
+			t.Errorf("expected the item not to be processed twice at once, got %d updates of issues", updates)
+		}
+	})
+}
+
+func TestBatchItemsBeyondTheTimeBudgetAreLeftForRetry(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("budget used up", func(mt *mtest.T) {
+		c := newTestController(mt)
+		c.batchTimeBudget = -time.Second
+
+		rec := serve(t, c.BatchIngestionTask, "PUT", "/issues/batch", "/issues/batch", "user-a", recoveryBatch(t, "item-1", "item-2"))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		for _, result := range batchResults(t, rec.Body.Bytes()) {
+			if result.Status != http.StatusServiceUnavailable {
+				t.Errorf("expected %s to be left for a retry, got %+v", result.Id, result)
+			}
+		}
+		if commands := len(sentCommands(mt)); commands != 0 {
+			t.Errorf("expected no item to be processed, got %d commands", commands)
+		}
+	})
+}
+
+func TestFailedBatchItemIsReleased(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("unsupported kind", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), modified(1))
+		batch := BatchIngestionPayload{Items: []BatchIngestionItem{{Id: "item-1", Kind: "metrics", Payload: json.RawMessage("{}")}}}
+
+		rec := serve(t, c.BatchIngestionTask, "PUT", "/issues/batch", "/issues/batch", "user-a", batch)
+
+		results := batchResults(t, rec.Body.Bytes())
+		if len(results) != 1 || results[0].Status != http.StatusBadRequest {
+			t.Fatalf("expected the item to be rejected, got %+v", results)
+		}
+		if deletes := countCommands(mt, "delete", "batchitems"); deletes != 1 {
+			t.Errorf("expected the claim to be released, got %d deletes", deletes)
+		}
+	})
+}
+
+func TestBatchItemOfAnotherUserIsRejected(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("another user", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), modified(1))
+
+		rec := serve(t, c.BatchIngestionTask, "PUT", "/issues/batch", "/issues/batch", "user-a", recoveryBatchOf(t, "user-b", "item-1"))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		results := batchResults(t, rec.Body.Bytes())
+		if len(results) != 1 || results[0].Status != http.StatusForbidden {
+			t.Fatalf("expected the item to be rejected, got %+v", results)
+		}
+		if updates := countCommands(mt, "update", "issues"); updates != 0 {
+			t.Errorf("expected no issue of the other user to be touched, got %d updates", updates)
+		}
+	})
+	mt.Run("no token", func(mt *mtest.T) {
+		c := newTestController(mt)
+
+		rec := serve(t, c.BatchIngestionTask, "PUT", "/issues/batch", "/issues/batch", "", recoveryBatch(t, "item-1"))
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+// predictionService answers analyses in place of the prediction agent for
+// the duration of the test.
+func predictionService(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.IssueAnalysis{Title: "Connection refused"})
+	}))
+	cfg := config.GetInstance()
+	url := cfg.PredicitonAgentServiceUrl
+	cfg.PredicitonAgentServiceUrl = server.URL
+	t.Cleanup(func() {
+		cfg.PredicitonAgentServiceUrl = url
+		server.Close()
+	})
+}
+
+func TestBatchItemIsReleasedWhenTheIssueIsNotStored(t *testing.T) {
+	predictionService(t)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("insert fails", func(mt *mtest.T) {
+		c := newTestController(mt)
+		payload, err := json.Marshal(LogAnalysisPayload{UserId: "user-a", ContainerName: "/api", Logs: "ERROR connection refused"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			found("users", models.User{UserId: "user-a", IsPro: true}),
+			found("muterules"),
+			notModified(),
+			found("routingrules"),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Message: "shutdown in progress"}),
+			modified(1),
+		)
+		batch := BatchIngestionPayload{Items: []BatchIngestionItem{{Id: "item-1", Kind: BATCH_ITEM_KIND_LOG_ANALYSIS, Payload: payload}}}
+
+		rec := serve(t, c.BatchIngestionTask, "PUT", "/issues/batch", "/issues/batch", "user-a", batch)
+
+		results := batchResults(t, rec.Body.Bytes())
+		if len(results) != 1 || results[0].Status != http.StatusInternalServerError {
+			t.Fatalf("expected the item to fail, got %+v", results)
+		}
+		if inserts := countCommands(mt, "insert", "issues"); inserts != 1 {
+			t.Errorf("expected the issue to be inserted, got %d inserts", inserts)
+		}
+		if deletes := countCommands(mt, "delete", "batchitems"); deletes != 1 {
+			t.Errorf("expected the claim to be released for a retry, got %d deletes", deletes)
+		}
+		if updates := countCommands(mt, "update", "batchitems"); updates != 0 {
+			t.Errorf("expected the item not to be recorded as processed, got %d updates", updates)
+		}
+	})
+}
+
+func TestBatchItemsExpireAfterTheRetention(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("ttl index", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		if err := EnsureBatchItemIndexes(context.Background(), c.batchItemsCollection); err != nil {
+			t.Fatal(err)
+		}
+
+		event := mt.GetStartedEvent()
+		if event == nil || event.CommandName != "createIndexes" {
+			t.Fatalf("expected an index to be created, got %+v", event)
+		}
+		index := event.Command.Lookup("indexes", "0").Document()
+		if key := index.Lookup("key", "claimedAt"); key.Type == 0 {
+			t.Errorf("expected the index on claimedAt, got %s", index)
+		}
+		if seconds, _ := index.Lookup("expireAfterSeconds").AsInt64OK(); seconds < int64((24 * time.Hour).Seconds()) {
+			t.Errorf("expected items to outlive the retries of the agent, got %d seconds", seconds)
+		}
+	})
+}
@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"signalone/pkg/models"
+	"signalone/pkg/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const MAX_BULK_ISSUES = 500
+
+const (
+	BULK_ACTION_RESOLVE = "resolve"
+	BULK_ACTION_REOPEN  = "reopen"
+	BULK_ACTION_ASSIGN  = "assign"
+	BULK_ACTION_TAG     = "tag"
+	BULK_ACTION_RATE    = "rate"
+	BULK_ACTION_DELETE  = "delete"
+)
+
+// BulkIssuesPayload applies an action to the issues with the ids or, without
+// ids, to the issues matching the filter, which takes the criteria of
+// IssuesSearch.
+type BulkIssuesPayload struct {
+	Action   string            `json:"action" binding:"required"`
+	Ids      []string          `json:"ids"`
+	Filter   map[string]string `json:"filter"`
+	Reason   string            `json:"reason"`
+	Assignee string            `json:"assignee"`
+	Tags     []string          `json:"tags"`
+	Score    *int32            `json:"score"`
+	DryRun   bool              `json:"dryRun"`
+}
+
+// BulkIssueResult reports whether an issue was, or in a dry run would be,
+// changed by a bulk action.
+type BulkIssueResult struct {
+	Id      string `json:"id"`
+	Status  int    `json:"status"`
+	Changed bool   `json:"changed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkIssues godoc
+// @Summary Resolve, reopen, assign, tag, rate or delete issues in bulk.
+// @Description Apply an action to the issues with the given ids, or to up to 500 issues matching a filter with the criteria of the issue search. The response reports per issue whether it changed; with dryRun nothing is changed and the response reports what would change.
+// @Tags issues
+// @Accept json
+// @Produce json
+// @Param bulkIssuesPayload body BulkIssuesPayload true "Action and issues"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 413 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /issues/bulk [post]
+func (c *MainController) BulkIssues(ctx *gin.Context) {
+	var bulkPayload BulkIssuesPayload
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&bulkPayload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateBulkPayload(&bulkPayload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ids := bulkPayload.Ids
+	if len(ids) == 0 {
+		var status int
+		ids, status, err = c.findBulkIssueIds(ctx, userId, bulkPayload.Filter)
+		if err != nil {
+			ctx.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if len(ids) > MAX_BULK_ISSUES {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Bulk operation exceeds %d issues", MAX_BULK_ISSUES)})
+		return
+	}
+
+	changed := 0
+	results := make([]BulkIssueResult, 0, len(ids))
+	for _, id := range ids {
+		result := c.processBulkIssue(ctx, bulkPayload, id, userId)
+		if result.Changed {
+			changed++
+		}
+		results = append(results, result)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"dryRun":  bulkPayload.DryRun,
+		"changed": changed,
+		"results": results,
+	})
+}
+
+func validateBulkPayload(bulkPayload *BulkIssuesPayload) error {
+	if len(bulkPayload.Ids) == 0 && bulkPayload.Filter == nil {
+		return errors.New("Bulk operation needs ids or a filter")
+	}
+	if len(bulkPayload.Ids) > 0 && bulkPayload.Filter != nil {
+		return errors.New("Bulk operation takes either ids or a filter")
+	}
+	switch bulkPayload.Action {
+	case BULK_ACTION_RESOLVE:
+		if bulkPayload.Reason == "" {
+			bulkPayload.Reason = models.ResolutionReasonManual
+		}
+	case BULK_ACTION_REOPEN, BULK_ACTION_ASSIGN, BULK_ACTION_DELETE:
+	case BULK_ACTION_TAG:
+		if len(bulkPayload.Tags) == 0 {
+			return errors.New("Tag action needs tags")
+		}
+	case BULK_ACTION_RATE:
+		if bulkPayload.Score == nil || *bulkPayload.Score < -1 || *bulkPayload.Score > 1 {
+			return errors.New("Score must be one of: -1, 0, 1")
+		}
+	default:
+		return fmt.Errorf("Unsupported bulk action: %s", bulkPayload.Action)
+	}
+	return nil
+}
+
+// findBulkIssueIds returns the ids of the issues of the user matching the
+// IssuesSearch criteria, one more than a bulk operation accepts at most so
+// exceeding the limit is noticed.
+func (c *MainController) findBulkIssueIds(ctx *gin.Context, userId string, criteria map[string]string) ([]string, int, error) {
+	filter, status, err := issueSearchFilter(bson.M{"userId": userId}, userId, func(key string) string { return criteria[key] })
+	if err != nil {
+		return nil, status, err
+	}
+
+	var issues []struct {
+		Id string `bson:"_id"`
+	}
+	cursor, err := c.issuesCollection.Find(ctx, filter,
+		options.Find().SetProjection(bson.M{"_id": 1}).SetSort(bson.M{"timestamp": -1}).SetLimit(MAX_BULK_ISSUES+1),
+	)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if err := cursor.All(ctx, &issues); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	ids := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		ids = append(ids, issue.Id)
+	}
+	return ids, http.StatusOK, nil
+}
+
+// processBulkIssue applies the bulk action to one issue of the user, or in a
+// dry run only checks whether it would change the issue, so one failing issue
+// does not fail the others. Issues of other users are not found.
+func (c *MainController) processBulkIssue(ctx *gin.Context, bulkPayload BulkIssuesPayload, id string, userId string) BulkIssueResult {
+	var issue models.Issue
+	result := BulkIssueResult{Id: id, Status: http.StatusOK}
+
+	if err := c.issuesCollection.FindOne(ctx, ownedIssueFilter(id, userId)).Decode(&issue); err != nil {
+		result.Status = http.StatusNotFound
+		result.Error = "Not found"
+		return result
+	}
+
+	var status int
+	var err error
+	switch bulkPayload.Action {
+	case BULK_ACTION_RESOLVE, BULK_ACTION_REOPEN:
+		state := models.IssueStateResolved
+		if bulkPayload.Action == BULK_ACTION_REOPEN {
+			state = models.IssueStateReopened
+		}
+		from := utils.GetIssueState(issue.State, issue.IsResolved)
+		if from == models.IssueStateResolved && state == models.IssueStateResolved {
+			break
+		}
+		if err := utils.ValidateIssueTransition(from, state); err != nil {
+			result.Status = http.StatusConflict
+			result.Error = err.Error()
+			return result
+		}
+		result.Changed = true
+		if !bulkPayload.DryRun {
+			_, status, err = c.changeIssueState(ctx, ownedIssueFilter(id, userId), userId, state, bulkPayload.Reason)
+		}
+	case BULK_ACTION_ASSIGN:
+		result.Changed = issue.Assignee != bulkPayload.Assignee
+		if result.Changed && !bulkPayload.DryRun {
+			_, status, err = c.assignIssue(ctx, id, userId, bulkPayload.Assignee)
+		}
+	case BULK_ACTION_TAG:
+		result.Changed = hasMissingTags(issue.Tags, bulkPayload.Tags)
+		if result.Changed && !bulkPayload.DryRun {
+			status, err = c.tagIssue(ctx, id, userId, bulkPayload.Tags)
+		}
+	case BULK_ACTION_RATE:
+		result.Changed = issue.Score != *bulkPayload.Score
+		if result.Changed && !bulkPayload.DryRun {
+			status, err = c.rateIssue(ctx, issue, userId, *bulkPayload.Score)
+		}
+	case BULK_ACTION_DELETE:
+		result.Changed = true
+		if !bulkPayload.DryRun {
+			if _, err = c.issuesCollection.DeleteOne(ctx, ownedIssueFilter(id, userId)); err != nil {
+				status = http.StatusInternalServerError
+			}
+		}
+	}
+	if err != nil {
+		result.Status = status
+		result.Changed = false
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func hasMissingTags(tags []string, added []string) bool {
+	for _, tag := range added {
+		missing := true
+		for _, existing := range tags {
+			if existing == tag {
+				missing = false
+				break
+			}
+		}
+		if missing {
+			return true
+		}
+	}
+	return false
+}
+
+// tagIssue adds the tags an issue of the user does not have yet.
+func (c *MainController) tagIssue(ctx *gin.Context, id string, userId string, tags []string) (int, error) {
+	res, err := c.issuesCollection.UpdateOne(ctx, ownedIssueFilter(id, userId), bson.M{
+		"$addToSet": bson.M{"tags": bson.M{"$each": tags}},
+		"$set":      bson.M{"lastActivityAt": time.Now()},
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if res.MatchedCount == 0 {
+		return http.StatusNotFound, errors.New("Not found")
+	}
+	return http.StatusOK, nil
+}
+
+// rateIssue changes the score of an issue of the user and moves the counter
+// of the user by the difference.
+func (c *MainController) rateIssue(ctx *gin.Context, issue models.Issue, userId string, score int32) (int, error) {
+	filter := ownedIssueFilter(issue.Id, userId)
+	filter["score"] = issue.Score
+	res, err := c.issuesCollection.UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{"score": score},
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if res.MatchedCount == 0 {
+		return http.StatusConflict, errors.New("Issue was rated in the meantime")
+	}
+
+	res, err = c.usersCollection.UpdateOne(ctx, bson.M{"userId": userId}, bson.M{
+		"$inc": bson.M{"counter": utils.CalculateNewCounter(issue.Score, score, 0)},
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if res.MatchedCount == 0 {
+		return http.StatusNotFound, errors.New("User cannot be found")
+	}
+	return http.StatusOK, nil
+}
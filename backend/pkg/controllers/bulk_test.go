@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"signalone/pkg/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type bulkResponse struct {
+	DryRun  bool              `json:"dryRun"`
+	Changed int               `json:"changed"`
+	Results []BulkIssueResult `json:"results"`
+}
+
+func decodeBulkResponse(t *testing.T, rec *httptest.ResponseRecorder) bulkResponse {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response bulkResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestBulkFilterOnlyMatchesIssuesOfUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("delete", func(mt *mtest.T) {
+		c := newTestController(mt)
+		// Scoped to user-b, the search only finds the issue of user-b.
+		mt.AddMockResponses(
+			found("issues", models.Issue{Id: "issue-b1"}),
+			found("issues", models.Issue{Id: "issue-b1", UserId: "user-b"}),
+			modified(1),
+		)
+
+		rec := serve(t, c.BulkIssues, "POST", "/issues/bulk", "/issues/bulk", "user-b",
+			BulkIssuesPayload{Action: BULK_ACTION_DELETE, Filter: map[string]string{}})
+
+		response := decodeBulkResponse(t, rec)
+		if response.Changed != 1 || len(response.Results) != 1 || response.Results[0].Id != "issue-b1" {
+			t.Errorf("expected only issue-b1 to be deleted, got %+v", response)
+		}
+		assertIssuesOwnedBy(t, mt, "user-b")
+	})
+}
+
+func TestBulkIdsOfAnotherUserAreNotFound(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("delete", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(found("issues"))
+
+		rec := serve(t, c.BulkIssues, "POST", "/issues/bulk", "/issues/bulk", "user-b",
+			BulkIssuesPayload{Action: BULK_ACTION_DELETE, Ids: []string{"issue-a1"}})
+
+		response := decodeBulkResponse(t, rec)
+		if response.Changed != 0 || len(response.Results) != 1 || response.Results[0].Status != http.StatusNotFound {
+			t.Errorf("expected issue-a1 not to be found, got %+v", response)
+		}
+		assertIssuesOwnedBy(t, mt, "user-b")
+		if deletes := countCommands(mt, "delete", "issues"); deletes != 0 {
+			t.Errorf("expected no delete, got %d", deletes)
+		}
+	})
+}
+
+func TestBulkDryRunReportsChangesWithoutApplyingThem(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("resolve", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(
+			found("issues", models.Issue{Id: "issue-1", UserId: "user-a", State: models.IssueStateOpen}),
+			found("issues", models.Issue{Id: "issue-2", UserId: "user-a", State: models.IssueStateResolved, IsResolved: true}),
+			found("issues", models.Issue{Id: "issue-3", UserId: "user-a", State: models.IssueStateIgnored, IsResolved: true}),
+		)
+
+		rec := serve(t, c.BulkIssues, "POST", "/issues/bulk", "/issues/bulk", "user-a",
+			BulkIssuesPayload{Action: BULK_ACTION_RESOLVE, Ids: []string{"issue-1", "issue-2", "issue-3"}, DryRun: true})
+
+		response := decodeBulkResponse(t, rec)
+		want := []BulkIssueResult{
+			{Id: "issue-1", Status: http.StatusOK, Changed: true},
+			{Id: "issue-2", Status: http.StatusOK, Changed: false},
+			{Id: "issue-3", Status: http.StatusConflict, Changed: false},
+		}
+		if !response.DryRun || response.Changed != 1 || len(response.Results) != len(want) {
+			t.Fatalf("expected a dry run changing 1 issue, got %+v", response)
+		}
+		for i, result := range response.Results {
+			if result.Id != want[i].Id || result.Status != want[i].Status || result.Changed != want[i].Changed {
+				t.Errorf("expected %+v, got %+v", want[i], result)
+			}
+		}
+		if updates := countCommands(mt, "update", "issues"); updates != 0 {
+			t.Errorf("expected no update in a dry run, got %d", updates)
+		}
+	})
+}
+
+func TestBulkApplyChangesIssues(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("resolve", func(mt *mtest.T) {
+		c := newTestController(mt)
+		open := models.Issue{Id: "issue-1", UserId: "user-a", State: models.IssueStateOpen}
+		mt.AddMockResponses(
+			found("issues", open),
+			found("issues", open),
+			modified(1),
+			found("issues", models.Issue{Id: "issue-2", UserId: "user-a", State: models.IssueStateResolved, IsResolved: true}),
+		)
+
+		rec := serve(t, c.BulkIssues, "POST", "/issues/bulk", "/issues/bulk", "user-a",
+			BulkIssuesPayload{Action: BULK_ACTION_RESOLVE, Ids: []string{"issue-1", "issue-2"}})
+
+		response := decodeBulkResponse(t, rec)
+		if response.DryRun || response.Changed != 1 || !response.Results[0].Changed || response.Results[1].Changed {
+			t.Errorf("expected only issue-1 to be resolved, got %+v", response)
+		}
+		if updates := countCommands(mt, "update", "issues"); updates != 1 {
+			t.Errorf("expected 1 update, got %d", updates)
+		}
+		assertIssuesOwnedBy(t, mt, "user-a")
+	})
+}
+
+func TestRateIssueUsesTokenUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("rate", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(
+			found("issues", models.Issue{Id: "issue-1", UserId: "user-a"}),
+			modified(1),
+			modified(1),
+		)
+		score := int32(1)
+
+		rec := serve(t, c.RateIssue, "PUT", "/issues/:id/score", "/issues/issue-1/score", "user-a", models.IssueRateRequest{Score: &score})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		assertIssuesOwnedBy(t, mt, "user-a")
+		for _, command := range sentCommands(mt) {
+			if command.collection != "users" {
+				continue
+			}
+			if user, _ := command.filter.Lookup("userId").StringValueOK(); user != "user-a" {
+				t.Errorf("expected the counter of user-a to change, got %s", command.filter)
+			}
+		}
+	})
+}
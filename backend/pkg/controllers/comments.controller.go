@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"signalone/pkg/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AddIssueComment godoc
+// @Summary Comment on an issue.
+// @Description Add a comment to an issue the user owns or is assigned to, or a reply to one of its comments when parentId is set.
+// @Tags issues
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the issue"
+// @Param issueCommentRequest body models.IssueCommentRequest true "Comment"
+// @Success 200 {object} models.IssueComment
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /issues/{id}/comments [post]
+func (c *MainController) AddIssueComment(ctx *gin.Context) {
+	var commentReq models.IssueCommentRequest
+	var user models.User
+	id := ctx.Param("id")
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&commentReq); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.usersCollection.FindOne(ctx, bson.M{"userId": userId}).Decode(&user); err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	now := time.Now()
+	comment := models.IssueComment{
+		Id:         uuid.New().String(),
+		ParentId:   commentReq.ParentId,
+		AuthorId:   userId,
+		AuthorName: user.UserName,
+		Body:       commentReq.Body,
+		CreatedAt:  now,
+	}
+	filter := visibleIssueFilter(id, userId)
+	if comment.ParentId != "" {
+		filter["comments"] = bson.M{"$elemMatch": bson.M{"id": comment.ParentId, "isDeleted": bson.M{"$ne": true}}}
+	}
+	res, err := c.issuesCollection.UpdateOne(ctx, filter, bson.M{
+		"$push": bson.M{"comments": comment},
+		"$set":  bson.M{"lastActivityAt": now},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if res.MatchedCount == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, comment)
+}
+
+// UpdateIssueComment godoc
+// @Summary Edit a comment on an issue.
+// @Description Replace the body of a comment. Only its author can edit it.
+// @Tags issues
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the issue"
+// @Param commentId path string true "ID of the comment"
+// @Param issueCommentRequest body models.IssueCommentRequest true "Comment"
+// @Success 200 {object} models.IssueComment
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 403 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /issues/{id}/comments/{commentId} [put]
+func (c *MainController) UpdateIssueComment(ctx *gin.Context) {
+	var commentReq models.IssueCommentRequest
+	id := ctx.Param("id")
+	commentId := ctx.Param("commentId")
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&commentReq); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	comment, status, err := c.findAuthoredComment(ctx, id, commentId, userId)
+	if err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	_, err = c.issuesCollection.UpdateOne(ctx, visibleCommentFilter(id, userId, commentId), bson.M{
+		"$set": bson.M{
+			"comments.$.body":      commentReq.Body,
+			"comments.$.updatedAt": now,
+			"lastActivityAt":       now,
+		},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	comment.Body = commentReq.Body
+	comment.UpdatedAt = &now
+
+	ctx.JSON(http.StatusOK, comment)
+}
+
+// DeleteIssueComment godoc
+// @Summary Delete a comment on an issue.
+// @Description Delete a comment. Only its author can delete it. A comment with replies is kept in the thread without its body.
+// @Tags issues
+// @Produce json
+// @Param id path string true "ID of the issue"
+// @Param commentId path string true "ID of the comment"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 403 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /issues/{id}/comments/{commentId} [delete]
+func (c *MainController) DeleteIssueComment(ctx *gin.Context) {
+	id := ctx.Param("id")
+	commentId := ctx.Param("commentId")
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if _, status, err := c.findAuthoredComment(ctx, id, commentId, userId); err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	replyFilter := visibleIssueFilter(id, userId)
+	replyFilter["comments.parentId"] = commentId
+	hasReplies, err := c.issuesCollection.CountDocuments(ctx, replyFilter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if hasReplies > 0 {
+		_, err = c.issuesCollection.UpdateOne(ctx, visibleCommentFilter(id, userId, commentId), bson.M{
+			"$set": bson.M{
+				"comments.$.body":      "",
+				"comments.$.isDeleted": true,
+				"comments.$.updatedAt": now,
+				"lastActivityAt":       now,
+			},
+		})
+	} else {
+		_, err = c.issuesCollection.UpdateOne(ctx, visibleIssueFilter(id, userId), bson.M{
+			"$pull": bson.M{"comments": bson.M{"id": commentId}},
+			"$set":  bson.M{"lastActivityAt": now},
+		})
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Success",
+	})
+}
+
+// findAuthoredComment returns a comment of an issue the user owns or is
+// assigned to, failing unless the user wrote it.
+func (c *MainController) findAuthoredComment(ctx *gin.Context, id string, commentId string, userId string) (models.IssueComment, int, error) {
+	var issue models.Issue
+
+	err := c.issuesCollection.FindOne(ctx,
+		visibleCommentFilter(id, userId, commentId),
+		options.FindOne().SetProjection(bson.M{"comments.$": 1}),
+	).Decode(&issue)
+	if err == mongo.ErrNoDocuments {
+		return models.IssueComment{}, http.StatusNotFound, errors.New("Not found")
+	}
+	if err != nil {
+		return models.IssueComment{}, http.StatusInternalServerError, err
+	}
+	if len(issue.Comments) == 0 || issue.Comments[0].IsDeleted {
+		return models.IssueComment{}, http.StatusNotFound, errors.New("Not found")
+	}
+	if issue.Comments[0].AuthorId != userId {
+		return models.IssueComment{}, http.StatusForbidden, errors.New("Only the author can change a comment")
+	}
+	return issue.Comments[0], http.StatusOK, nil
+}
+
+// visibleCommentFilter matches the issue the user owns or is assigned to with
+// the comment, so the positional operator refers to the comment.
+func visibleCommentFilter(id string, userId string, commentId string) bson.M {
+	filter := visibleIssueFilter(id, userId)
+	filter["comments.id"] = commentId
+	return filter
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"net/http"
+	"signalone/pkg/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCommentOnAnotherUsersIssueIsNotFound(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("comment", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(
+			found("users", models.User{UserId: "user-b", UserName: "bob"}),
+			modified(0),
+		)
+
+		rec := serve(t, c.AddIssueComment, "POST", "/issues/:id/comments", "/issues/issue-1/comments", "user-b",
+			models.IssueCommentRequest{Body: "looking into it"})
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body)
+		}
+		assertIssuesVisibleTo(t, mt, "user-b")
+	})
+}
+
+func TestReplyRequiresParentThatIsNotDeleted(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("reply", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(
+			found("users", models.User{UserId: "user-a", UserName: "alice"}),
+			modified(1),
+		)
+
+		rec := serve(t, c.AddIssueComment, "POST", "/issues/:id/comments", "/issues/issue-1/comments", "user-a",
+			models.IssueCommentRequest{Body: "same here", ParentId: "comment-1"})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		assertIssuesVisibleTo(t, mt, "user-a")
+		for _, command := range sentCommands(mt) {
+			if command.name != "update" {
+				continue
+			}
+			match := command.filter.Lookup("comments", "$elemMatch")
+			if id, _ := match.Document().Lookup("id").StringValueOK(); id != "comment-1" {
+				t.Errorf("expected the reply to match its parent, got %s", command.filter)
+			}
+			if deleted, ok := match.Document().Lookup("isDeleted", "$ne").BooleanOK(); !ok || !deleted {
+				t.Errorf("expected the reply to skip deleted parents, got %s", command.filter)
+			}
+		}
+	})
+}
+
+func TestOnlyAuthorCanEditComment(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("edit", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(found("issues", models.Issue{
+			Id:       "issue-1",
+			UserId:   "user-a",
+			Comments: []models.IssueComment{{Id: "comment-1", AuthorId: "user-c", Body: "first"}},
+		}))
+
+		rec := serve(t, c.UpdateIssueComment, "PUT", "/issues/:id/comments/:commentId", "/issues/issue-1/comments/comment-1", "user-a",
+			models.IssueCommentRequest{Body: "edited"})
+
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d: %s", rec.Code, rec.Body)
+		}
+		if updates := countCommands(mt, "update", "issues"); updates != 0 {
+			t.Errorf("expected no update, got %d", updates)
+		}
+	})
+}
+
+func TestAssigneeCanCommentOnAssignedIssue(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("comment", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(
+			found("users", models.User{UserId: "user-b", UserName: "bob"}),
+			modified(1),
+		)
+
+		rec := serve(t, c.AddIssueComment, "POST", "/issues/:id/comments", "/issues/issue-1/comments", "user-b",
+			models.IssueCommentRequest{Body: "looking into it"})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		assertIssuesVisibleTo(t, mt, "user-b")
+	})
+}
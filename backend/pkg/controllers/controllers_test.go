@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"signalone/cmd/config"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestMain loads a configuration with a known token secret, which the config
+// package only reads from the working directory.
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	dir, err := os.MkdirTemp("", "signalone-controllers")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".default"), []byte("SIGNAL_ONE_SECRET=test-secret\n"), 0600); err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	if config.GetInstance() == nil {
+		panic("test configuration not loaded")
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestController returns a controller on collections of the mocked
+// deployment, which answers commands with the responses queued by the test
+// in order.
+func newTestController(mt *mtest.T) *MainController {
+	collection := func(name string) *mongo.Collection {
+		return mt.CreateCollection(mtest.Collection{Name: name}, false)
+	}
+	return NewMainController(collection("issues"), collection("users"), collection("analysis"), collection("muterules"),
+		collection("routingrules"), collection("batchitems"))
+}
+
+// serve runs the handler for a request of the user, or without a token when
+// the user is empty.
+func serve(t *testing.T, handler gin.HandlerFunc, method string, route string, path string, userId string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	engine := gin.New()
+	engine.Handle(method, route, handler)
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if userId != "" {
+		token, err := createToken(userId, userId, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+// found answers a query with the documents.
+func found(collection string, documents ...any) bson.D {
+	batch := make([]bson.D, 0, len(documents))
+	for _, document := range documents {
+		data, err := bson.Marshal(document)
+		if err != nil {
+			panic(err)
+		}
+		var doc bson.D
+		if err := bson.Unmarshal(data, &doc); err != nil {
+			panic(err)
+		}
+		batch = append(batch, doc)
+	}
+	return mtest.CreateCursorResponse(0, "test."+collection, mtest.FirstBatch, batch...)
+}
+
+// modified answers an update or delete that matched n documents.
+func modified(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+type sentCommand struct {
+	name       string
+	collection string
+	filter     bson.Raw
+}
+
+// sentCommands returns the commands sent to the deployment with the filter
+// of the documents they read or change, or the document they insert.
+func sentCommands(mt *mtest.T) []sentCommand {
+	commands := make([]sentCommand, 0)
+	for _, event := range mt.GetAllStartedEvents() {
+		command := sentCommand{name: event.CommandName}
+		command.collection, _ = event.Command.Index(0).Value().StringValueOK()
+		var filter bson.RawValue
+		switch event.CommandName {
+		case "find":
+			filter = event.Command.Lookup("filter")
+		case "insert":
+			filter = event.Command.Lookup("documents", "0")
+		case "update":
+			filter = event.Command.Lookup("updates", "0", "q")
+		case "delete":
+			filter = event.Command.Lookup("deletes", "0", "q")
+		case "findAndModify":
+			filter = event.Command.Lookup("query")
+		case "aggregate":
+			filter = event.Command.Lookup("pipeline", "0", "$match")
+		}
+		command.filter, _ = filter.DocumentOK()
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+// assertIssuesOwnedBy fails unless every command on the issues filters by
+// the owner.
+func assertIssuesOwnedBy(t *testing.T, mt *mtest.T, userId string) {
+	t.Helper()
+	for _, command := range sentCommands(mt) {
+		if command.collection != "issues" {
+			continue
+		}
+		if owner, _ := command.filter.Lookup("userId").StringValueOK(); owner != userId {
+			t.Errorf("expected %s on issues to filter by userId %s, got %s", command.name, userId, command.filter)
+		}
+	}
+}
+
+// assertIssuesVisibleTo checks that every command on issues filters by the
+// issues the user owns or is assigned to.
+func assertIssuesVisibleTo(t *testing.T, mt *mtest.T, userId string) {
+	t.Helper()
+	expected, err := bson.Marshal(bson.M{"$or": bson.A{bson.M{"userId": userId}, bson.M{"assignee": userId}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range sentCommands(mt) {
+		if command.collection != "issues" {
+			continue
+		}
+		if visibility := command.filter.Lookup("$or"); !visibility.Equal(bson.Raw(expected).Lookup("$or")) {
+			t.Errorf("expected %s on issues to filter by the issues visible to %s, got %s", command.name, userId, command.filter)
+		}
+	}
+}
+
+// countCommands counts the commands with the name sent on the collection.
+func countCommands(mt *mtest.T, name string, collection string) int {
+	count := 0
+	for _, command := range sentCommands(mt) {
+		if command.name == name && command.collection == collection {
+			count++
+		}
+	}
+	return count
+}
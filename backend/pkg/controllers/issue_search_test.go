@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"signalone/pkg/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestIssueSearchFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		criteria map[string]string
+		expected bson.M
+		absent   []string
+	}{
+		{
+			name:     "defaults to open issues without muted ones",
+			criteria: map[string]string{},
+			expected: bson.M{"$or": visibleTo("user-a"), "isResolved": false, "muteRuleId": bson.M{"$in": bson.A{nil, ""}}},
+			absent:   []string{"$and", "composeProject", "assignee"},
+		},
+		{
+			name:     "compose service",
+			criteria: map[string]string{"composeProject": "shop", "composeService": "api"},
+			expected: bson.M{"$or": visibleTo("user-a"), "composeProject": "shop", "composeService": "api"},
+		},
+		{
+			name:     "muted issues included",
+			criteria: map[string]string{"includeMuted": "true", "isResolved": "true"},
+			expected: bson.M{"$or": visibleTo("user-a"), "isResolved": true},
+			absent:   []string{"muteRuleId"},
+		},
+		{
+			name:     "state replaces the resolved flag",
+			criteria: map[string]string{"state": "acknowledged,inProgress"},
+			expected: bson.M{"$or": visibleTo("user-a"), "$and": bson.A{bson.M{"$or": bson.A{bson.M{"state": "acknowledged"}, bson.M{"state": "inProgress"}}}}},
+			absent:   []string{"isResolved"},
+		},
+		{
+			name:     "assigned to me",
+			criteria: map[string]string{"assignedToMe": "true", "assignee": "user-b"},
+			expected: bson.M{"$or": visibleTo("user-a"), "assignee": "user-a"},
+		},
+		{
+			name:     "tag, severity and type",
+			criteria: map[string]string{"tag": "db", "issueSeverity": "CRITICAL", "issueType": "ANOMALY"},
+			expected: bson.M{"$or": visibleTo("user-a"), "tags": "db", "severity": "CRITICAL", "type": "ANOMALY"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, status, err := issueSearchFilter(visibleIssuesScope("user-a"), "user-a", func(key string) string { return test.criteria[key] })
+			if err != nil {
+				t.Fatalf("expected a filter, got %d: %v", status, err)
+			}
+			for key, value := range test.expected {
+				if !equalBson(t, filter[key], value) {
+					t.Errorf("expected %s to be %v, got %v", key, value, filter[key])
+				}
+			}
+			for _, key := range test.absent {
+				if _, ok := filter[key]; ok {
+					t.Errorf("expected no %s, got %v", key, filter[key])
+				}
+			}
+		})
+	}
+}
+
+// visibleTo is the condition matching the issues the user owns or is assigned
+// to.
+func visibleTo(userId string) bson.A {
+	return bson.A{bson.M{"userId": userId}, bson.M{"assignee": userId}}
+}
+
+func TestIssueSearchFilterOfBulkChangesIsOwned(t *testing.T) {
+	filter, _, err := issueSearchFilter(bson.M{"userId": "user-a"}, "user-a", func(key string) string {
+		if key == "assignedToMe" {
+			return "true"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter["userId"] != "user-a" || filter["assignee"] != "user-a" {
+		t.Errorf("expected issues of user-a assigned to user-a, got %v", filter)
+	}
+	if _, ok := filter["$or"]; ok {
+		t.Errorf("expected no assigned issues of other users, got %v", filter)
+	}
+}
+
+func TestIssueSearchFilterRejectsUnknownState(t *testing.T) {
+	_, status, err := issueSearchFilter(visibleIssuesScope("user-a"), "user-a", func(key string) string {
+		if key == "state" {
+			return "open,closed"
+		}
+		return ""
+	})
+	if err == nil || status != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d: %v", status, err)
+	}
+}
+
+func TestComposeGroupKey(t *testing.T) {
+	key, err := composeGroupKey(GROUP_BY_COMPOSE_PROJECT)
+	if err != nil || len(key) != 1 || key["composeProject"] != "$composeProject" {
+		t.Errorf("expected issues grouped per project, got %v: %v", key, err)
+	}
+	key, err = composeGroupKey(GROUP_BY_COMPOSE_SERVICE)
+	if err != nil || len(key) != 2 || key["composeProject"] != "$composeProject" || key["composeService"] != "$composeService" {
+		t.Errorf("expected issues grouped per service within a project, got %v: %v", key, err)
+	}
+	if _, err := composeGroupKey("containerName"); err == nil {
+		t.Error("expected an error for an unsupported grouping")
+	}
+}
+
+func TestIssuesSearchGroupsIssuesOfTheUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("groupBy", func(mt *mtest.T) {
+		c := newTestController(mt)
+		latest := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		mt.AddMockResponses(
+			found("issues",
+				models.IssueSearchResult{Id: "issue-1", ComposeProject: "shop", ComposeService: "api", TimeStamp: latest},
+				models.IssueSearchResult{Id: "issue-2", ComposeProject: "shop", ComposeService: "db", TimeStamp: latest},
+			),
+			found("issues", bson.M{"n": 2}),
+			found("issues",
+				models.IssueGroup{ComposeProject: "shop", ComposeService: "api", Count: 1, LatestTimestamp: latest},
+				models.IssueGroup{ComposeProject: "shop", ComposeService: "db", Count: 1, LatestTimestamp: latest},
+			),
+		)
+
+		rec := serve(t, c.IssuesSearch, "GET", "/issues", "/issues?composeProject=shop&groupBy=composeService", "user-a", nil)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		var response struct {
+			Issues []models.IssueSearchResult `json:"issues"`
+			Max    int64                      `json:"max"`
+			Groups []models.IssueGroup        `json:"groups"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Issues) != 2 || response.Max != 2 {
+			t.Errorf("expected 2 issues, got %d of %d", len(response.Issues), response.Max)
+		}
+		if len(response.Groups) != 2 || response.Groups[0].ComposeService != "api" || response.Groups[1].ComposeService != "db" {
+			t.Errorf("expected a group per service, got %+v", response.Groups)
+		}
+		assertIssuesVisibleTo(t, mt, "user-a")
+		for _, command := range sentCommands(mt) {
+			if project, _ := command.filter.Lookup("composeProject").StringValueOK(); project != "shop" {
+				t.Errorf("expected %s to filter by the project, got %s", command.name, command.filter)
+			}
+		}
+	})
+}
+
+func TestIssuesSearchRejectsUnsupportedGrouping(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("groupBy", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(found("issues"), found("issues", bson.M{"n": 0}))
+
+		rec := serve(t, c.IssuesSearch, "GET", "/issues", "/issues?groupBy=containerName", "user-a", nil)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestGetIssueOfAnotherUserIsNotFound(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("get", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(found("issues"))
+
+		rec := serve(t, c.GetIssue, "GET", "/issues/:id", "/issues/issue-1", "user-b", nil)
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body)
+		}
+		assertIssuesVisibleTo(t, mt, "user-b")
+
+		if rec := serve(t, c.GetIssue, "GET", "/issues/:id", "/issues/issue-1", "", nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 without a token, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestAssigneeCanGetAssignedIssue(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("get", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(found("issues", models.Issue{Id: "issue-1", UserId: "user-a", Assignee: "user-b"}))
+
+		rec := serve(t, c.GetIssue, "GET", "/issues/:id", "/issues/issue-1", "user-b", nil)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		assertIssuesVisibleTo(t, mt, "user-b")
+	})
+}
+
+// equalBson compares filter values by their BSON encoding, which ignores
+// the Go types they were built with.
+func equalBson(t *testing.T, actual any, expected any) bool {
+	t.Helper()
+	encode := func(value any) string {
+		data, err := bson.Marshal(bson.M{"v": value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bson.Raw(data).String()
+	}
+	return encode(actual) == encode(expected)
+}
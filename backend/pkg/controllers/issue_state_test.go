@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"signalone/pkg/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestStateChangeOfAnotherUsersIssueIsNotFound(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("resolve", func(mt *mtest.T) {
+		c := newTestController(mt)
+		// The issue belongs to user-a and is not assigned to user-b, so the
+		// filter of user-b finds nothing.
+		mt.AddMockResponses(found("issues"))
+
+		rec := serve(t, c.ResolveIssue, "POST", "/issues/:id", "/issues/issue-1", "user-b", nil)
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body)
+		}
+		assertIssuesVisibleTo(t, mt, "user-b")
+		if updates := countCommands(mt, "update", "issues"); updates != 0 {
+			t.Errorf("expected no update, got %d", updates)
+		}
+	})
+}
+
+func TestResolvingResolvedIssueIsNoop(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("resolve", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(found("issues", models.Issue{Id: "issue-1", UserId: "user-a", State: models.IssueStateResolved, IsResolved: true}))
+
+		rec := serve(t, c.ResolveIssue, "POST", "/issues/:id", "/issues/issue-1", "user-a", nil)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		if updates := countCommands(mt, "update", "issues"); updates != 0 {
+			t.Errorf("expected no update, got %d", updates)
+		}
+	})
+}
+
+func TestUpdateIssueStateAppliesValidTransition(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("acknowledge", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(
+			found("issues", models.Issue{Id: "issue-1", UserId: "user-a", State: models.IssueStateOpen}),
+			modified(1),
+		)
+
+		rec := serve(t, c.UpdateIssueState, "PUT", "/issues/:id/state", "/issues/issue-1/state", "user-a",
+			models.IssueStateRequest{State: models.IssueStateAcknowledged})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		var response struct {
+			State string `json:"state"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || response.State != models.IssueStateAcknowledged {
+			t.Errorf("expected acknowledged state, got %s", rec.Body)
+		}
+		assertIssuesVisibleTo(t, mt, "user-a")
+		for _, command := range sentCommands(mt) {
+			if command.name == "update" {
+				if state, _ := command.filter.Lookup("state").StringValueOK(); state != models.IssueStateOpen {
+					t.Errorf("expected the update to apply to the open state only, got %s", command.filter)
+				}
+			}
+		}
+	})
+}
+
+func TestAssigneeCanChangeTheStateOfAnAssignedIssue(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("acknowledge", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(
+			found("issues", models.Issue{Id: "issue-1", UserId: "user-a", Assignee: "user-b", State: models.IssueStateOpen}),
+			modified(1),
+		)
+
+		rec := serve(t, c.UpdateIssueState, "PUT", "/issues/:id/state", "/issues/issue-1/state", "user-b",
+			models.IssueStateRequest{State: models.IssueStateAcknowledged})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		assertIssuesVisibleTo(t, mt, "user-b")
+	})
+}
+
+func TestUpdateIssueStateRejectsInvalidTransition(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("ignored to acknowledged", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(found("issues", models.Issue{Id: "issue-1", UserId: "user-a", State: models.IssueStateIgnored, IsResolved: true}))
+
+		rec := serve(t, c.UpdateIssueState, "PUT", "/issues/:id/state", "/issues/issue-1/state", "user-a",
+			models.IssueStateRequest{State: models.IssueStateAcknowledged})
+
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body)
+		}
+		if updates := countCommands(mt, "update", "issues"); updates != 0 {
+			t.Errorf("expected no update, got %d", updates)
+		}
+	})
+}
@@ -11,6 +11,7 @@ import (
 	_ "signalone/docs"
 	"signalone/pkg/models"
 	"signalone/pkg/utils"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -24,9 +25,49 @@ import (
 )
 
 type LogAnalysisPayload struct {
-	UserId        string `json:"userId"`
-	ContainerName string `json:"containerName"`
-	Logs          string `json:"logs"`
+	UserId                 string                    `json:"userId"`
+	ContainerName          string                    `json:"containerName"`
+	ComposeProject         string                    `json:"composeProject"`
+	ComposeService         string                    `json:"composeService"`
+	ComposeContainerNumber int                       `json:"composeContainerNumber"`
+	Labels                 map[string]string         `json:"labels"`
+	IssueType              string                    `json:"issueType"`
+	Logs                   string                    `json:"logs"`
+	ParsedLogs             []models.ParsedLogLine    `json:"parsedLogs"`
+	LogContext             []models.LogContextLine   `json:"logContext"`
+	CrashLoop              *models.CrashLoopReport   `json:"crashLoop"`
+	Termination            *models.TerminationReport `json:"termination"`
+	Health                 *models.HealthReport      `json:"health"`
+	Anomaly                *models.AnomalyReport     `json:"anomaly"`
+	ContainerSnapshot      *models.ContainerSnapshot `json:"containerSnapshot"`
+}
+
+type RecoveryPayload struct {
+	UserId                 string    `json:"userId"`
+	ContainerName          string    `json:"containerName"`
+	ComposeProject         string    `json:"composeProject"`
+	ComposeService         string    `json:"composeService"`
+	ComposeContainerNumber int       `json:"composeContainerNumber"`
+	Reason                 string    `json:"reason"`
+	Since                  time.Time `json:"since"`
+}
+
+type BatchIngestionItem struct {
+	Id      string          `json:"id"`
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type BatchIngestionPayload struct {
+	Items []BatchIngestionItem `json:"items"`
+}
+
+type BatchIngestionResult struct {
+	Id      string `json:"id"`
+	Status  int    `json:"status"`
+	IssueId string `json:"issueId,omitempty"`
+	Count   int64  `json:"count,omitempty"`
+	Error   string `json:"error,omitempty"`
 }
 
 type GetIssuesPayload struct {
@@ -37,18 +78,55 @@ type MainController struct {
 	issuesCollection        *mongo.Collection
 	usersCollection         *mongo.Collection
 	analysisStoreCollection *mongo.Collection
+	muteRulesCollection     *mongo.Collection
+	routingRulesCollection  *mongo.Collection
+	batchItemsCollection    *mongo.Collection
+	batchTimeBudget         time.Duration
 }
 
 const ACCESS_TOKEN_EXPIRATION_TIME = time.Minute * 10
 const REFRESH_TOKEN_EXPIRATION_TIME = time.Hour * 24
 
+const MAX_BATCH_ITEMS = 100
+const MAX_BATCH_BODY_SIZE = 32 << 20
+
+// BATCH_TIME_BUDGET is how long a batch upload may take to process, well
+// within the 60 second timeout of agent requests. Items left over are
+// answered with 503 and uploaded again.
+const BATCH_TIME_BUDGET = 30 * time.Second
+
+// BATCH_ITEM_CLAIM_TIMEOUT is after how long an item claimed for processing
+// is taken over by another upload, in case the backend stopped processing it.
+const BATCH_ITEM_CLAIM_TIMEOUT = 10 * time.Minute
+
+// BATCH_ITEM_RETENTION is how long processed batch items are remembered. It
+// exceeds the 24 hours an agent keeps retrying a detection by default.
+const BATCH_ITEM_RETENTION = 72 * time.Hour
+
+const (
+	BATCH_ITEM_KIND_LOG_ANALYSIS = "log_analysis"
+	BATCH_ITEM_KIND_RECOVERY     = "recovery"
+)
+
+const (
+	GROUP_BY_COMPOSE_PROJECT = "composeProject"
+	GROUP_BY_COMPOSE_SERVICE = "composeService"
+)
+
 func NewMainController(issuesCollection *mongo.Collection,
 	usersCollection *mongo.Collection,
-	analysisStoreCollection *mongo.Collection) *MainController {
+	analysisStoreCollection *mongo.Collection,
+	muteRulesCollection *mongo.Collection,
+	routingRulesCollection *mongo.Collection,
+	batchItemsCollection *mongo.Collection) *MainController {
 	return &MainController{
 		issuesCollection:        issuesCollection,
 		usersCollection:         usersCollection,
 		analysisStoreCollection: analysisStoreCollection,
+		muteRulesCollection:     muteRulesCollection,
+		routingRulesCollection:  routingRulesCollection,
+		batchItemsCollection:    batchItemsCollection,
+		batchTimeBudget:         BATCH_TIME_BUDGET,
 	}
 }
 
@@ -63,37 +141,85 @@ func NewMainController(issuesCollection *mongo.Collection,
 // @Success 200 {object} map[string]any
 // @Failure 400 {object} map[string]any
 // @Failure 401 {object} map[string]any
+// @Failure 403 {object} map[string]any
 // @Router /issues/analysis [put]
 func (c *MainController) LogAnalysisTask(ctx *gin.Context) {
-	var user models.User
-	var analysisResponse models.IssueAnalysis
-
-	bearerToken := ctx.GetHeader("Authorization")
-	if bearerToken == "" {
-		ctx.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(401, gin.H{"error": err.Error()})
 		return
 	}
-	bearerToken = strings.TrimPrefix(bearerToken, "Bearer ")
 	var logAnalysisPayload LogAnalysisPayload
 	if err := ctx.ShouldBindJSON(&logAnalysisPayload); err != nil {
 		ctx.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	logAnalysisPayload.UserId, err = agentPayloadUserId(userId, logAnalysisPayload.UserId)
+	if err != nil {
+		ctx.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	issueId, status, err := c.processLogAnalysis(ctx, logAnalysisPayload)
+	if err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "Success",
+		"issueId": issueId,
+	})
+}
+
+// processLogAnalysis analyses the logs of a detection and stores the issue.
+// On failure it returns the HTTP status the error should be reported with.
+func (c *MainController) processLogAnalysis(ctx *gin.Context, logAnalysisPayload LogAnalysisPayload) (string, int, error) {
+	var user models.User
+	var analysisResponse models.IssueAnalysis
+
 	userResult := c.usersCollection.FindOne(ctx, bson.M{"userId": logAnalysisPayload.UserId})
 	err := userResult.Decode(&user)
 	if err != nil {
-		ctx.JSON(400, gin.H{"error": err.Error()})
-		return
+		return "", 400, err
+	}
+
+	issueType := strings.ToUpper(logAnalysisPayload.IssueType)
+	if issueType == "" {
+		issueType = models.IssueTypeError
+	}
+	fingerprint := utils.IssueFingerprint(logAnalysisPayload.UserId, logAnalysisPayload.ContainerName, logAnalysisPayload.ComposeProject,
+		logAnalysisPayload.ComposeService, logAnalysisPayload.ComposeContainerNumber, issueType, logAnalysisPayload.ParsedLogs, logAnalysisPayload.CrashLoop,
+		logAnalysisPayload.Termination, logAnalysisPayload.Health, logAnalysisPayload.Anomaly)
+	severity := utils.ClassifyIssueSeverity(issueType, logAnalysisPayload.ParsedLogs, logAnalysisPayload.Termination)
+	muteRules, err := c.activeMuteRules(ctx, logAnalysisPayload.UserId)
+	if err != nil {
+		return "", 500, err
+	}
+	muteRuleId := ""
+	if muteRule := utils.MatchMuteRule(muteRules, logAnalysisPayload.ContainerName, fingerprint, severity, logAnalysisPayload.Logs); muteRule != nil {
+		if muteRule.Action == models.MuteActionDrop {
+			return "", 200, nil
+		}
+		muteRuleId = muteRule.Id
+	}
+
+	reopenedIssueId, err := c.reopenRecurringIssue(ctx, logAnalysisPayload, fingerprint, severity, muteRuleId)
+	if err != nil {
+		return "", 500, err
+	}
+	if reopenedIssueId != "" {
+		return reopenedIssueId, 200, nil
 	}
+
 	issueId := uuid.New().String()
-	data := map[string]string{"logs": logAnalysisPayload.Logs}
+	analysisLogs := logAnalysisPayload.Logs
+	if len(logAnalysisPayload.LogContext) > 0 {
+		analysisLogs = utils.JoinLogContext(logAnalysisPayload.LogContext)
+	}
+	data := map[string]string{"logs": utils.BuildAnalysisInput(analysisLogs, logAnalysisPayload.Termination, logAnalysisPayload.Health, logAnalysisPayload.Anomaly, logAnalysisPayload.ContainerSnapshot)}
 	jsonData, _ := json.Marshal(data)
 	analysisResponse, err = utils.CallPredictionAgentService(jsonData)
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
-		return
+		return "", 500, err
 	}
 
 	if !user.IsPro {
@@ -103,28 +229,366 @@ func (c *MainController) LogAnalysisTask(ctx *gin.Context) {
 		})
 	}
 
+	routingRules, err := c.routingRules(ctx, logAnalysisPayload.UserId)
+	if err != nil {
+		return "", 500, err
+	}
+	// Agents before the labels were sent with the detection only send them
+	// with the configuration snapshot.
+	labels := logAnalysisPayload.Labels
+	if labels == nil && logAnalysisPayload.ContainerSnapshot != nil {
+		labels = logAnalysisPayload.ContainerSnapshot.Labels
+	}
+	routingRule := utils.MatchRoutingRule(routingRules, logAnalysisPayload.ContainerName, logAnalysisPayload.ComposeProject, labels)
+
 	formattedAnalysisLogs := strings.Split(logAnalysisPayload.Logs, "\n")
+	now := time.Now()
 
-	c.issuesCollection.InsertOne(ctx, models.Issue{
+	issue := models.Issue{
 		Id:                        issueId,
 		UserId:                    logAnalysisPayload.UserId,
 		ContainerName:             logAnalysisPayload.ContainerName,
+		ComposeProject:            logAnalysisPayload.ComposeProject,
+		ComposeService:            logAnalysisPayload.ComposeService,
+		ComposeContainerNumber:    logAnalysisPayload.ComposeContainerNumber,
 		Score:                     0,
-		Severity:                  strings.ToUpper("Critical"), // TODO: Implement severity detection
+		Severity:                  severity,
+		Type:                      issueType,
 		Title:                     analysisResponse.Title,
-		TimeStamp:                 time.Now(),
+		TimeStamp:                 now,
+		LastActivityAt:            now,
 		IsResolved:                false,
+		State:                     models.IssueStateOpen,
+		History:                   make([]models.IssueStateChange, 0),
+		Comments:                  make([]models.IssueComment, 0),
+		Fingerprint:               fingerprint,
+		MuteRuleId:                muteRuleId,
 		Logs:                      formattedAnalysisLogs,
+		ParsedLogs:                logAnalysisPayload.ParsedLogs,
+		LogContext:                logAnalysisPayload.LogContext,
+		CrashLoop:                 logAnalysisPayload.CrashLoop,
+		Termination:               logAnalysisPayload.Termination,
+		Health:                    logAnalysisPayload.Health,
+		Anomaly:                   logAnalysisPayload.Anomaly,
+		ContainerSnapshot:         logAnalysisPayload.ContainerSnapshot,
 		LogSummary:                analysisResponse.LogSummary,
 		PredictedSolutionsSummary: analysisResponse.PredictedSolutions,
 		PredictedSolutionsSources: analysisResponse.Sources,
-	})
+	}
+	if routingRule != nil {
+		issue.Assignee = routingRule.Assignee
+		issue.AssignedAt = &now
+		issue.RoutingRuleId = routingRule.Id
+	}
+	// The agent drops a detection once it was accepted, so a failed insert
+	// must fail the detection for the agent to upload it again.
+	if _, err := c.issuesCollection.InsertOne(ctx, issue); err != nil {
+		return "", 500, err
+	}
+	return issueId, 200, nil
+}
+
+// reopenRecurringIssue reopens the latest issue with the fingerprint that was
+// resolved automatically after the container recovered, updating it with the
+// new detection instead of raising a new issue. It returns an empty id when
+// there is no such issue; issues resolved by a user are left alone.
+func (c *MainController) reopenRecurringIssue(ctx *gin.Context, logAnalysisPayload LogAnalysisPayload, fingerprint string, severity string,
+	muteRuleId string) (string, error) {
+	var issue models.Issue
+	now := time.Now()
+
+	err := c.issuesCollection.FindOneAndUpdate(ctx,
+		bson.M{
+			"userId":      logAnalysisPayload.UserId,
+			"fingerprint": fingerprint,
+			"isResolved":  true,
+			"resolutionReason": bson.M{
+				"$in": []string{models.RecoveryReasonHealthy, models.RecoveryReasonStable, models.RecoveryReasonRestarted},
+			},
+		},
+		bson.M{
+			"$set": bson.M{
+				"isResolved":             false,
+				"state":                  models.IssueStateReopened,
+				"containerName":          logAnalysisPayload.ContainerName,
+				"composeContainerNumber": logAnalysisPayload.ComposeContainerNumber,
+				"severity":               severity,
+				"muteRuleId":             muteRuleId,
+				"logs":                   strings.Split(logAnalysisPayload.Logs, "\n"),
+				"parsedLogs":             logAnalysisPayload.ParsedLogs,
+				"logContext":             logAnalysisPayload.LogContext,
+				"crashLoop":              logAnalysisPayload.CrashLoop,
+				"termination":            logAnalysisPayload.Termination,
+				"health":                 logAnalysisPayload.Health,
+				"anomaly":                logAnalysisPayload.Anomaly,
+				"containerSnapshot":      logAnalysisPayload.ContainerSnapshot,
+				"timestamp":              now,
+				"lastActivityAt":         now,
+				"reopenedAt":             now,
+			},
+			"$unset": bson.M{
+				"resolutionReason": "",
+				"resolvedAt":       "",
+			},
+			"$inc": bson.M{
+				"reopenCount": 1,
+			},
+			"$push": bson.M{
+				"history": models.IssueStateChange{
+					From:      models.IssueStateResolved,
+					To:        models.IssueStateReopened,
+					ChangedBy: models.IssueChangedBySystem,
+					Reason:    "recurred",
+					Timestamp: now,
+				},
+			},
+		},
+		options.FindOneAndUpdate().SetSort(bson.M{"timestamp": -1}),
+	).Decode(&issue)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return issue.Id, nil
+}
+
+// RecoveryTask godoc
+// @Summary Resolve issues of a container that recovered.
+// @Description Resolve the open issues raised for a failure the container has recovered from: a passing healthcheck, a run lasting the stability window after a failure, or a clean restart. Resolved issues record the reason and are reopened when the same problem recurs.
+// @Tags analysis
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param recoveryPayload body RecoveryPayload true "Recovery payload"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 403 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /issues/recovery [put]
+func (c *MainController) RecoveryTask(ctx *gin.Context) {
+	var recoveryPayload RecoveryPayload
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(401, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&recoveryPayload); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	recoveryPayload.UserId, err = agentPayloadUserId(userId, recoveryPayload.UserId)
+	if err != nil {
+		ctx.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, status, err := c.processRecovery(ctx, recoveryPayload)
+	if err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
 	ctx.JSON(200, gin.H{
 		"message": "Success",
-		"issueId": issueId,
+		"count":   count,
 	})
 }
 
+// processRecovery resolves the open issues of a container that recovered.
+// A healthy container resolves its healthcheck issues, a stable run its exit
+// and crash-loop issues and a clean restart every issue but anomalies, which
+// a restart does not fix, in each case only the issues raised before the
+// recovered run started. Issues being worked on are left to the user. The
+// container is identified like in fingerprints, by its Compose replica when
+// it has one, so replicas do not resolve each other's issues.
+// On failure it returns the HTTP status the error should be reported with.
+func (c *MainController) processRecovery(ctx *gin.Context, recoveryPayload RecoveryPayload) (int64, int, error) {
+	conditions := []bson.M{
+		{"userId": recoveryPayload.UserId},
+		recoveredContainerFilter(recoveryPayload),
+		utils.IssueStateFilter([]string{models.IssueStateOpen, models.IssueStateReopened}),
+	}
+	switch recoveryPayload.Reason {
+	case models.RecoveryReasonHealthy:
+		conditions = append(conditions, bson.M{"health": bson.M{"$exists": true}})
+	case models.RecoveryReasonStable:
+		conditions = append(conditions, bson.M{"$or": []bson.M{
+			{"termination": bson.M{"$exists": true}},
+			{"crashLoop": bson.M{"$exists": true}},
+		}})
+	case models.RecoveryReasonRestarted:
+		conditions = append(conditions, bson.M{"type": bson.M{"$ne": models.IssueTypeAnomaly}})
+	default:
+		return 0, 400, errors.New("Unsupported recovery reason")
+	}
+	if !recoveryPayload.Since.IsZero() {
+		conditions = append(conditions, bson.M{"timestamp": bson.M{"$lt": recoveryPayload.Since}})
+	}
+	filter := bson.M{"$and": conditions}
+
+	// The history entry is built from the state each issue is in, so the
+	// update runs as a pipeline.
+	now := time.Now()
+	res, err := c.issuesCollection.UpdateMany(ctx, filter, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"isResolved":       true,
+			"state":            models.IssueStateResolved,
+			"resolutionReason": recoveryPayload.Reason,
+			"resolvedAt":       now,
+			"lastActivityAt":   now,
+			"history": bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$history", bson.A{}}},
+				bson.A{bson.M{
+					"from":      bson.M{"$ifNull": bson.A{"$state", models.IssueStateOpen}},
+					"to":        models.IssueStateResolved,
+					"changedBy": models.IssueChangedBySystem,
+					"reason":    recoveryPayload.Reason,
+					"timestamp": now,
+				}},
+			}},
+		}}},
+	})
+	if err != nil {
+		return 0, 500, err
+	}
+	return res.ModifiedCount, 200, nil
+}
+
+// recoveredContainerFilter matches the issues of the container of a recovery,
+// or of its Compose replica, which recreated containers keep.
+func recoveredContainerFilter(recoveryPayload RecoveryPayload) bson.M {
+	if recoveryPayload.ComposeService == "" {
+		return bson.M{"containerName": recoveryPayload.ContainerName}
+	}
+	return bson.M{
+		"composeProject":         recoveryPayload.ComposeProject,
+		"composeService":         recoveryPayload.ComposeService,
+		"composeContainerNumber": recoveryPayload.ComposeContainerNumber,
+	}
+}
+
+// BatchIngestionTask godoc
+// @Summary Ingest a batch of agent detections.
+// @Description Process multiple log analysis and recovery items uploaded in one request, optionally gzip or zstd compressed. Every item is processed on its own and its outcome reported in the results. An item uploaded again is answered with the outcome of its first upload; items not processed within the time budget of the request are answered with 503 and should be uploaded again.
+// @Tags analysis
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param Content-Encoding header string false "gzip, zstd or identity"
+// @Param batchIngestionPayload body BatchIngestionPayload true "Batch ingestion payload"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 413 {object} map[string]any
+// @Failure 415 {object} map[string]any
+// @Router /issues/batch [put]
+func (c *MainController) BatchIngestionTask(ctx *gin.Context) {
+	var batchPayload BatchIngestionPayload
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(401, gin.H{"error": err.Error()})
+		return
+	}
+	body, err := utils.DecompressRequestBody(ctx.Request.Body, ctx.GetHeader("Content-Encoding"), MAX_BATCH_BODY_SIZE)
+	if errors.Is(err, utils.ErrUnsupportedContentEncoding) {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, MAX_BATCH_BODY_SIZE+1))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(data) > MAX_BATCH_BODY_SIZE {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Batch is too large"})
+		return
+	}
+	if err := json.Unmarshal(data, &batchPayload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(batchPayload.Items) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Batch is empty"})
+		return
+	}
+	if len(batchPayload.Items) > MAX_BATCH_ITEMS {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Batch exceeds %d items", MAX_BATCH_ITEMS)})
+		return
+	}
+
+	accepted := 0
+	results := make([]BatchIngestionResult, 0, len(batchPayload.Items))
+	deadline := time.Now().Add(c.batchTimeBudget)
+	for _, item := range batchPayload.Items {
+		var result BatchIngestionResult
+		if time.Now().After(deadline) {
+			result = BatchIngestionResult{
+				Id:     item.Id,
+				Status: http.StatusServiceUnavailable,
+				Error:  "Not processed within the time budget of the batch",
+			}
+		} else {
+			result = c.processBatchItemOnce(ctx, userId, item)
+		}
+		if result.Status == 200 {
+			accepted++
+		}
+		results = append(results, result)
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "Success",
+		"accepted": accepted,
+		"rejected": len(results) - accepted,
+		"results":  results,
+	})
+}
+
+// processBatchItem processes an item of a batch uploaded with the token of
+// the user, rejecting items of other users.
+func (c *MainController) processBatchItem(ctx *gin.Context, userId string, item BatchIngestionItem) BatchIngestionResult {
+	var err error
+	result := BatchIngestionResult{Id: item.Id}
+	switch item.Kind {
+	case BATCH_ITEM_KIND_LOG_ANALYSIS:
+		var logAnalysisPayload LogAnalysisPayload
+		if err = json.Unmarshal(item.Payload, &logAnalysisPayload); err != nil {
+			result.Status = 400
+			break
+		}
+		if logAnalysisPayload.UserId, err = agentPayloadUserId(userId, logAnalysisPayload.UserId); err != nil {
+			result.Status = 403
+			break
+		}
+		result.IssueId, result.Status, err = c.processLogAnalysis(ctx, logAnalysisPayload)
+	case BATCH_ITEM_KIND_RECOVERY:
+		var recoveryPayload RecoveryPayload
+		if err = json.Unmarshal(item.Payload, &recoveryPayload); err != nil {
+			result.Status = 400
+			break
+		}
+		if recoveryPayload.UserId, err = agentPayloadUserId(userId, recoveryPayload.UserId); err != nil {
+			result.Status = 403
+			break
+		}
+		result.Count, result.Status, err = c.processRecovery(ctx, recoveryPayload)
+	default:
+		result.Status, err = 400, fmt.Errorf("Unsupported item kind: %s", item.Kind)
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
 // IssuesSearch godoc
 // @Summary Search for issues based on specified criteria.
 // @Description Search for issues based on specified criteria.
@@ -135,11 +599,20 @@ func (c *MainController) LogAnalysisTask(ctx *gin.Context) {
 // @Param limit query int false "Maximum number of results per page (default: 30, max: 100)"
 // @Param searchString query string false "Search string for filtering issues"
 // @Param container query string false "Filter by container name"
+// @Param composeProject query string false "Filter by Compose project"
+// @Param composeService query string false "Filter by Compose service"
+// @Param groupBy query string false "Also count the matching issues per composeProject or composeService"
 // @Param issueSeverity query string false "Filter by issue severity"
 // @Param issueType query string false "Filter by issue type"
 // @Param startTimestamp query string false "Filter issues starting from this timestamp (RFC3339 format)"
 // @Param endTimestamp query string false "Filter issues until this timestamp (RFC3339 format)"
 // @Param isResolved query bool false "Filter resolved or unresolved issues"
+// @Param state query string false "Filter by comma-separated issue states, e.g. open,reopened"
+// @Param includeMuted query bool false "Include issues marked by mute rules"
+// @Param assignee query string false "Filter by the ID of the assigned user"
+// @Param assignedToMe query bool false "Only list the issues assigned to the user"
+// @Param tag query string false "Filter by issue tag"
+// @Param sortBy query string false "timestamp (default) or activity, the latest comment, state change or recurrence first"
 // @Success 200 {object} map[string]any
 // @Failure 400 {object} map[string]any
 // @Router /issues [get]
@@ -147,20 +620,12 @@ func (c *MainController) IssuesSearch(ctx *gin.Context) {
 	var max int64
 	issues := make([]models.IssueSearchResult, 0)
 
-	container := ctx.Query("container")
-	endTimestampQuery := ctx.Query("endTimestamp")
-	issueSeverity := ctx.Query("issueSeverity")
-	issueType := ctx.Query("issueType")
+	groupBy := ctx.Query("groupBy")
 	limitQuery := ctx.Query("limit")
 	offsetQuery := ctx.Query("offset")
-	startTimestampQuery := ctx.Query("startTimestamp")
+	sortBy := ctx.Query("sortBy")
 	_ = ctx.Query("searchString")
 
-	isResolved, err := strconv.ParseBool(ctx.Query("isResolved"))
-	if err != nil {
-		isResolved = false
-	}
-
 	offset, err := strconv.Atoi(offsetQuery)
 	if err != nil || offsetQuery == "" {
 		offset = 0
@@ -171,6 +636,114 @@ func (c *MainController) IssuesSearch(ctx *gin.Context) {
 		limit = 30
 	}
 
+	qOpts := options.Find()
+	qOpts.SetLimit(int64(limit))
+	qOpts.SetSkip(int64(offset))
+	switch sortBy {
+	case "", "timestamp":
+		qOpts.SetSort(bson.M{"timestamp": -1})
+	case "activity":
+		qOpts.SetSort(bson.D{{Key: "lastActivityAt", Value: -1}, {Key: "timestamp", Value: -1}})
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported sortBy: " + sortBy})
+		return
+	}
+	qOpts.SetProjection(bson.M{
+		"_id":              1,
+		"containerName":    1,
+		"composeProject":   1,
+		"composeService":   1,
+		"severity":         1,
+		"title":            1,
+		"type":             1,
+		"isResolved":       1,
+		"state":            1,
+		"resolutionReason": 1,
+		"timestamp":        1,
+		"lastActivityAt":   1,
+		"assignee":         1,
+		"tags":             1,
+	})
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	filter, status, err := issueSearchFilter(visibleIssuesScope(userId), userId, ctx.Query)
+	if err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	cursor, err := c.issuesCollection.Find(ctx, filter, qOpts)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var issue models.IssueSearchResult
+
+		if err := cursor.Decode(&issue); err != nil {
+			continue
+		}
+		issue.State = utils.GetIssueState(issue.State, issue.IsResolved)
+		if issue.LastActivityAt.IsZero() {
+			issue.LastActivityAt = issue.TimeStamp
+		}
+
+		issues = append(issues, issue)
+	}
+
+	max, _ = c.issuesCollection.CountDocuments(ctx, filter)
+
+	response := gin.H{
+		"issues": issues,
+		"max":    max,
+	}
+	if groupBy != "" {
+		groups, status, err := c.groupIssues(ctx, filter, groupBy)
+		if err != nil {
+			ctx.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		response["groups"] = groups
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// issueSearchFilter builds the filter of the search criteria IssuesSearch
+// accepts, read with the query function, matching only the issues in the
+// scope. On failure it returns the HTTP status the error should be reported
+// with.
+func issueSearchFilter(scope bson.M, userId string, query func(string) string) (bson.M, int, error) {
+	container := query("container")
+	composeProject := query("composeProject")
+	composeService := query("composeService")
+	endTimestampQuery := query("endTimestamp")
+	issueSeverity := query("issueSeverity")
+	issueType := query("issueType")
+	startTimestampQuery := query("startTimestamp")
+	stateQuery := query("state")
+	assignee := query("assignee")
+	tag := query("tag")
+
+	includeMuted, _ := strconv.ParseBool(query("includeMuted"))
+
+	if assignedToMe, _ := strconv.ParseBool(query("assignedToMe")); assignedToMe {
+		assignee = userId
+	}
+
+	isResolvedQuery := query("isResolved")
+	isResolved, err := strconv.ParseBool(isResolvedQuery)
+	if err != nil {
+		isResolved = false
+	}
+
 	startTimestamp, err := time.Parse(time.RFC3339, startTimestampQuery)
 	if err != nil {
 		fmt.Print("Error: ", err)
@@ -183,34 +756,52 @@ func (c *MainController) IssuesSearch(ctx *gin.Context) {
 		endTimestamp = time.Now().UTC()
 	}
 
-	qOpts := options.Find()
-	qOpts.SetLimit(int64(limit))
-	qOpts.SetSkip(int64(offset))
-	qOpts.SetSort(bson.M{"timestamp": -1})
-	qOpts.SetProjection(bson.M{
-		"_id":           1,
-		"containerName": 1,
-		"severity":      1,
-		"title":         1,
-		"isResolved":    1,
-		"timestamp":     1,
-	})
-
 	fmt.Print("startTimestamp: ", startTimestamp.UTC())
 	fmt.Print("endTimestamp: ", endTimestamp.UTC())
 
 	filter := bson.M{
-		"isResolved": isResolved,
 		"timestamp": bson.M{
 			"$gte": startTimestamp.UTC(),
 			"$lte": endTimestamp.UTC(),
 		},
 	}
+	for key, value := range scope {
+		filter[key] = value
+	}
+
+	// Without a state filter only open or only resolved issues are listed,
+	// like before issues had states.
+	if stateQuery == "" || isResolvedQuery != "" {
+		filter["isResolved"] = isResolved
+	}
+
+	if !includeMuted {
+		filter["muteRuleId"] = bson.M{"$in": bson.A{nil, ""}}
+	}
+
+	if stateQuery != "" {
+		states := strings.Split(stateQuery, ",")
+		for _, state := range states {
+			if !utils.IsValidIssueState(state) {
+				return nil, http.StatusBadRequest, errors.New("Unknown issue state: " + state)
+			}
+		}
+		// The scope may match with $or as well.
+		filter["$and"] = bson.A{utils.IssueStateFilter(states)}
+	}
 
 	if container != "" {
 		filter["containerName"] = container
 	}
 
+	if composeProject != "" {
+		filter["composeProject"] = composeProject
+	}
+
+	if composeService != "" {
+		filter["composeService"] = composeService
+	}
+
 	if issueSeverity != "" {
 		filter["severity"] = issueSeverity
 	}
@@ -219,30 +810,48 @@ func (c *MainController) IssuesSearch(ctx *gin.Context) {
 		filter["type"] = issueType
 	}
 
-	cursor, err := c.issuesCollection.Find(ctx, filter, qOpts)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if assignee != "" {
+		filter["assignee"] = assignee
 	}
 
-	defer cursor.Close(ctx)
-
-	for cursor.Next(ctx) {
-		var issue models.IssueSearchResult
-
-		if err := cursor.Decode(&issue); err != nil {
-			continue
-		}
-
-		issues = append(issues, issue)
+	if tag != "" {
+		filter["tags"] = tag
 	}
 
-	max, _ = c.issuesCollection.CountDocuments(ctx, filter)
+	return filter, http.StatusOK, nil
+}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"issues": issues,
-		"max":    max,
+// groupIssues counts the issues matching the filter per Compose project or
+// per service within a project, most recently active first.
+func (c *MainController) groupIssues(ctx *gin.Context, filter bson.M, groupBy string) ([]models.IssueGroup, int, error) {
+	groupKey, err := composeGroupKey(groupBy)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	cursor, err := c.issuesCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":             groupKey,
+			"count":           bson.M{"$sum": 1},
+			"latestTimestamp": bson.M{"$max": "$timestamp"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":             0,
+			"composeProject":  "$_id.composeProject",
+			"composeService":  "$_id.composeService",
+			"count":           1,
+			"latestTimestamp": 1,
+		}}},
+		{{Key: "$sort", Value: bson.M{"latestTimestamp": -1}}},
 	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	groups := make([]models.IssueGroup, 0)
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return groups, http.StatusOK, nil
 }
 
 // GetIssue godoc
@@ -253,16 +862,32 @@ func (c *MainController) IssuesSearch(ctx *gin.Context) {
 // @Produce json
 // @Param id path string true "ID of the issue"
 // @Success 200 {object} models.Issue
+// @Failure 401 {object} map[string]any
 // @Failure 404 {object} map[string]any
 // @Router /issues/{id} [get]
 func (c *MainController) GetIssue(ctx *gin.Context) {
 	var issue models.Issue
 	id := ctx.Param("id")
 
-	if err := c.issuesCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&issue); err != nil {
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.issuesCollection.FindOne(ctx, visibleIssueFilter(id, userId)).Decode(&issue); err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
 		return
 	}
+	issue.State = utils.GetIssueState(issue.State, issue.IsResolved)
+	if issue.History == nil {
+		issue.History = make([]models.IssueStateChange, 0)
+	}
+	if issue.Comments == nil {
+		issue.Comments = make([]models.IssueComment, 0)
+	}
+	if issue.LastActivityAt.IsZero() {
+		issue.LastActivityAt = issue.TimeStamp
+	}
 
 	ctx.JSON(http.StatusOK, issue)
 }
@@ -270,7 +895,6 @@ func (c *MainController) GetIssue(ctx *gin.Context) {
 func (c *MainController) RateIssue(ctx *gin.Context) {
 	var issue models.Issue
 	var issueRateReq models.IssueRateRequest
-	var user models.User
 
 	userId, err := getUserIdFromToken(ctx)
 	if err != nil {
@@ -278,12 +902,10 @@ func (c *MainController) RateIssue(ctx *gin.Context) {
 		return
 	}
 
-	//TODO: Remove hardcoded userId
-	userId = "4c78e05c-2f83-4e6e-b4c1-8721618a1c89"
-
 	err = ctx.ShouldBindJSON(&issueRateReq)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	if *issueRateReq.Score != -1 && *issueRateReq.Score != 0 && *issueRateReq.Score != 1 {
@@ -291,71 +913,22 @@ func (c *MainController) RateIssue(ctx *gin.Context) {
 		return
 	}
 
-	userResult := c.usersCollection.FindOne(ctx, bson.M{"userId": userId})
-
-	err = userResult.Decode(&user)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
 	id := ctx.Param("id")
 
-	issueConditions := bson.M{
-		"_id":    id,
-		"userId": userId,
-	}
-
-	filter := utils.GenerateFilter(issueConditions, "$and")
-	issueResult := c.issuesCollection.FindOne(ctx, filter)
-
-	err = issueResult.Decode(&issue)
+	err = c.issuesCollection.FindOne(ctx, ownedIssueFilter(id, userId)).Decode(&issue)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Issue cannot be found"})
 		return
 	}
 
-	var currentIssueScore = issue.Score
-
-	if currentIssueScore == *issueRateReq.Score {
+	if issue.Score == *issueRateReq.Score {
 		ctx.JSON(http.StatusOK, gin.H{"message": "Issue already rated with the same score"})
 		return
 	}
 
-	updatedIssueResult, err := c.issuesCollection.UpdateOne(ctx,
-		filter,
-		bson.M{
-			"$set": bson.M{
-				"score": issueRateReq.Score,
-			},
-		})
+	status, err := c.rateIssue(ctx, issue, userId, *issueRateReq.Score)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if updatedIssueResult.MatchedCount == 0 {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Issue cannot be found"})
-		return
-	}
-
-	counter := user.Counter
-	counter = utils.CalculateNewCounter(currentIssueScore, *issueRateReq.Score, counter)
-
-	updatedUserResult, err := c.usersCollection.UpdateOne(ctx,
-		bson.M{"userId": userId},
-		bson.M{
-			"$set": bson.M{
-				"counter": counter,
-			},
-		})
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if updatedUserResult.MatchedCount == 0 {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "User cannot be found"})
+		ctx.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -366,42 +939,165 @@ func (c *MainController) RateIssue(ctx *gin.Context) {
 
 // ResolveIssue godoc
 // @Summary Resolve an issue by setting its status to resolved.
-// @Description Resolve an issue by providing its ID and updating its status to resolved.
+// @Description Resolve an issue by providing its ID and updating its status to resolved. The change is recorded in the issue history.
 // @Tags issues
 // @Accept json
 // @Produce json
 // @Param id path string true "ID of the issue to be resolved"
 // @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
 // @Failure 404 {object} map[string]any
+// @Failure 409 {object} map[string]any
 // @Failure 500 {object} map[string]any
 // @Router /issues/resolve/{id} [post]
 // @RequestBody application/json ResolveIssueRequest true "Issue resolution request"
 func (c *MainController) ResolveIssue(ctx *gin.Context) {
-	id := ctx.Param("id")
+	c.handleIssueStateChange(ctx, models.IssueStateResolved, models.ResolutionReasonManual)
+}
 
-	res, err := c.issuesCollection.UpdateOne(ctx,
-		bson.M{"_id": id},
-		bson.M{
-			"$set": bson.M{
-				"isResolved": true,
-			},
-		})
+// ReopenIssue godoc
+// @Summary Reopen a resolved or ignored issue.
+// @Description Reopen a resolved or ignored issue by providing its ID. The change is recorded in the issue history.
+// @Tags issues
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the issue to be reopened"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Failure 409 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /issues/{id}/reopen [post]
+func (c *MainController) ReopenIssue(ctx *gin.Context) {
+	c.handleIssueStateChange(ctx, models.IssueStateReopened, "")
+}
 
+// UpdateIssueState godoc
+// @Summary Move an issue to another lifecycle state.
+// @Description Move an issue to open, acknowledged, inProgress, resolved, ignored or reopened. Only valid transitions are accepted and each change is recorded in the issue history with its author.
+// @Tags issues
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the issue"
+// @Param issueStateRequest body models.IssueStateRequest true "New state of the issue"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Failure 409 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /issues/{id}/state [put]
+func (c *MainController) UpdateIssueState(ctx *gin.Context) {
+	var issueStateReq models.IssueStateRequest
+
+	if err := ctx.ShouldBindJSON(&issueStateReq); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !utils.IsValidIssueState(issueStateReq.State) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Unknown issue state: " + issueStateReq.State})
+		return
+	}
+	reason := issueStateReq.Reason
+	if reason == "" && issueStateReq.State == models.IssueStateResolved {
+		reason = models.ResolutionReasonManual
+	}
+	c.handleIssueStateChange(ctx, issueStateReq.State, reason)
+}
+
+func (c *MainController) handleIssueStateChange(ctx *gin.Context, state string, reason string) {
+	userId, err := getUserIdFromToken(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	if res.MatchedCount == 0 {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	issue, status, err := c.changeIssueState(ctx, visibleIssueFilter(ctx.Param("id"), userId), userId, state, reason)
+	if err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "Success",
+		"state":   issue.State,
 	})
 }
 
+// changeIssueState moves the issue matching the filter to the state on behalf
+// of the user after validating the transition and records the change in the
+// issue history. The update only applies to the state the transition was
+// validated against, so concurrent changes are reported as conflicts.
+// Resolving a resolved issue changes nothing, like before issues had states.
+// On failure it returns the HTTP status the error should be reported with.
+func (c *MainController) changeIssueState(ctx *gin.Context, issueFilter bson.M, userId string, state string, reason string) (models.Issue, int, error) {
+	var issue models.Issue
+
+	if err := c.issuesCollection.FindOne(ctx, issueFilter).Decode(&issue); err != nil {
+		return issue, http.StatusNotFound, errors.New("Not found")
+	}
+	from := utils.GetIssueState(issue.State, issue.IsResolved)
+	if from == models.IssueStateResolved && state == models.IssueStateResolved {
+		issue.State = from
+		return issue, http.StatusOK, nil
+	}
+	if err := utils.ValidateIssueTransition(from, state); err != nil {
+		return issue, http.StatusConflict, err
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"state":          state,
+		"isResolved":     utils.IsClosedIssueState(state),
+		"lastActivityAt": now,
+	}
+	unset := bson.M{}
+	update := bson.M{
+		"$push": bson.M{
+			"history": models.IssueStateChange{
+				From:      from,
+				To:        state,
+				ChangedBy: userId,
+				Reason:    reason,
+				Timestamp: now,
+			},
+		},
+	}
+	switch state {
+	case models.IssueStateResolved:
+		set["resolutionReason"] = reason
+		set["resolvedAt"] = now
+	case models.IssueStateReopened:
+		set["reopenedAt"] = now
+		update["$inc"] = bson.M{"reopenCount": 1}
+		unset["resolutionReason"] = ""
+		unset["resolvedAt"] = ""
+	}
+	update["$set"] = set
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	filter := bson.M{}
+	for key, value := range issueFilter {
+		filter[key] = value
+	}
+	filter["state"] = issue.State
+	if issue.State == "" {
+		filter["state"] = bson.M{"$in": bson.A{nil, ""}}
+		filter["isResolved"] = issue.IsResolved
+	}
+	res, err := c.issuesCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return issue, http.StatusInternalServerError, err
+	}
+	if res.MatchedCount == 0 {
+		return issue, http.StatusConflict, errors.New("Issue was changed by another request")
+	}
+	issue.State = state
+	return issue, http.StatusOK, nil
+}
+
 // DeleteIssues godoc
 // @Summary Delete issues based on the provided container name.
 // @Description Delete issues based on the provided container name.
@@ -428,12 +1124,17 @@ func (c *MainController) DeleteIssues(ctx *gin.Context) {
 
 // GetContainers godoc
 // @Summary Get a list of containers based on the provided user ID.
-// @Description Get a list of containers based on the provided user ID.
+// @Description Get the containers that raised issues for the user, optionally filtered by Compose project and service or grouped by them.
 // @Tags containers
 // @Accept json
 // @Produce json
 // @Param userId query string true "User ID to filter containers"
+// @Param composeProject query string false "Filter by Compose project"
+// @Param composeService query string false "Filter by Compose service"
+// @Param groupBy query string false "Group the containers by composeProject or composeService"
 // @Success 200 {array} string
+// @Success 200 {array} models.ContainerGroup
+// @Failure 400 {object} map[string]any
 // @Failure 500 {object} map[string]any
 // @Router /containers [get]
 func (c *MainController) GetContainers(ctx *gin.Context) {
@@ -445,7 +1146,25 @@ func (c *MainController) GetContainers(ctx *gin.Context) {
 		return
 	}
 
-	results, err := c.issuesCollection.Distinct(ctx, "containerName", bson.M{"userId": userId})
+	filter := bson.M{"userId": userId}
+	if composeProject := ctx.Query("composeProject"); composeProject != "" {
+		filter["composeProject"] = composeProject
+	}
+	if composeService := ctx.Query("composeService"); composeService != "" {
+		filter["composeService"] = composeService
+	}
+
+	if groupBy := ctx.Query("groupBy"); groupBy != "" {
+		groups, status, err := c.groupContainers(ctx, filter, groupBy)
+		if err != nil {
+			ctx.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, groups)
+		return
+	}
+
+	results, err := c.issuesCollection.Distinct(ctx, "containerName", filter)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -458,6 +1177,54 @@ func (c *MainController) GetContainers(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, containers)
 }
 
+// groupContainers lists the containers that raised issues matching the
+// filter per Compose project or per service within a project. Containers not
+// started by Compose end up in the group with an empty project.
+func (c *MainController) groupContainers(ctx *gin.Context, filter bson.M, groupBy string) ([]models.ContainerGroup, int, error) {
+	groupKey, err := composeGroupKey(groupBy)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	cursor, err := c.issuesCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        groupKey,
+			"containers": bson.M{"$addToSet": "$containerName"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":            0,
+			"composeProject": "$_id.composeProject",
+			"composeService": "$_id.composeService",
+			"containers":     1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "composeProject", Value: 1}, {Key: "composeService", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	groups := make([]models.ContainerGroup, 0)
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	for _, group := range groups {
+		sort.Strings(group.Containers)
+	}
+	return groups, http.StatusOK, nil
+}
+
+// composeGroupKey returns the $group key for grouping issues by Compose
+// project, or by service within a project, as service names are only unique
+// within their project.
+func composeGroupKey(groupBy string) (bson.M, error) {
+	switch groupBy {
+	case GROUP_BY_COMPOSE_PROJECT:
+		return bson.M{"composeProject": "$composeProject"}, nil
+	case GROUP_BY_COMPOSE_SERVICE:
+		return bson.M{"composeProject": "$composeProject", "composeService": "$composeService"}, nil
+	}
+	return nil, fmt.Errorf("groupBy must be one of: %s, %s", GROUP_BY_COMPOSE_PROJECT, GROUP_BY_COMPOSE_SERVICE)
+}
+
 // Auth Handlers
 func (c *MainController) LoginWithGithubHandler(ctx *gin.Context) {
 	var requestData models.GithubTokenRequest
@@ -626,6 +1393,35 @@ func (c *MainController) RefreshTokenHandler(ctx *gin.Context) {
 	})
 }
 
+// ownedIssueFilter matches the issue with the id only when it belongs to the
+// user, so users cannot read or change the issues of others.
+func ownedIssueFilter(id string, userId string) bson.M {
+	return bson.M{"_id": id, "userId": userId}
+}
+
+// visibleIssuesScope matches the issues the user owns or is assigned to.
+func visibleIssuesScope(userId string) bson.M {
+	return bson.M{"$or": bson.A{bson.M{"userId": userId}, bson.M{"assignee": userId}}}
+}
+
+// visibleIssueFilter matches the issue with the id only when the user owns it
+// or is assigned to it, so assignees can work on the issues of others.
+func visibleIssueFilter(id string, userId string) bson.M {
+	filter := visibleIssuesScope(userId)
+	filter["_id"] = id
+	return filter
+}
+
+// agentPayloadUserId returns the user of the agent token as the user of a
+// payload the agent sent, rejecting payloads of another user. Payloads
+// without a user belong to the user of the token.
+func agentPayloadUserId(tokenUserId string, payloadUserId string) (string, error) {
+	if payloadUserId != "" && payloadUserId != tokenUserId {
+		return "", errors.New("Token does not belong to the user")
+	}
+	return tokenUserId, nil
+}
+
 func getUserIdFromToken(ctx *gin.Context) (string, error) {
 	bearerToken := ctx.GetHeader("Authorization")
 
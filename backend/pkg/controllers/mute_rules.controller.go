@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"net/http"
+	"signalone/pkg/models"
+	"signalone/pkg/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ListMuteRules godoc
+// @Summary List the mute rules of the user.
+// @Description List the mute rules of the user, including expired ones, newest first.
+// @Tags muteRules
+// @Produce json
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /mute-rules [get]
+func (c *MainController) ListMuteRules(ctx *gin.Context) {
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules := make([]models.MuteRule, 0)
+	cursor, err := c.muteRulesCollection.Find(ctx, bson.M{"userId": userId}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := cursor.All(ctx, &rules); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+	})
+}
+
+// CreateMuteRule godoc
+// @Summary Mute or snooze matching detections.
+// @Description Create a rule muting the detections of a container, with an issue fingerprint, matching a log pattern or of a severity. Set expiresAt to snooze them until a date. Dropped detections raise no issue, marked ones raise muted issues.
+// @Tags muteRules
+// @Accept json
+// @Produce json
+// @Param muteRuleRequest body models.MuteRuleRequest true "Mute rule"
+// @Success 200 {object} models.MuteRule
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /mute-rules [post]
+func (c *MainController) CreateMuteRule(ctx *gin.Context) {
+	var muteRuleReq models.MuteRuleRequest
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&muteRuleReq); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.MuteRule{
+		Id:            uuid.New().String(),
+		UserId:        userId,
+		ContainerName: muteRuleReq.ContainerName,
+		Fingerprint:   muteRuleReq.Fingerprint,
+		Pattern:       muteRuleReq.Pattern,
+		Severity:      muteRuleReq.Severity,
+		Action:        muteRuleReq.Action,
+		Comment:       muteRuleReq.Comment,
+		CreatedBy:     userId,
+		CreatedAt:     time.Now(),
+		ExpiresAt:     muteRuleReq.ExpiresAt,
+	}
+	if err := utils.ValidateMuteRule(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := c.muteRulesCollection.InsertOne(ctx, rule); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rule)
+}
+
+// DeleteMuteRule godoc
+// @Summary Delete a mute rule.
+// @Description Delete a mute rule of the user, so matching detections raise issues again.
+// @Tags muteRules
+// @Produce json
+// @Param id path string true "ID of the mute rule"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /mute-rules/{id} [delete]
+func (c *MainController) DeleteMuteRule(ctx *gin.Context) {
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := c.muteRulesCollection.DeleteOne(ctx, bson.M{"_id": ctx.Param("id"), "userId": userId})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if res.DeletedCount == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Success",
+	})
+}
+
+// AgentMuteRules godoc
+// @Summary Get the active mute rules for an agent.
+// @Description Get the mute rules of the user the agent token belongs to that have not expired, so the agent can skip sending detections they drop.
+// @Tags analysis
+// @Produce json
+// @Param Authorization header string true "Bearer <token>"
+// @Param userId query string false "ID of the user the agent reports for, which has to be the user of the token"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 403 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /mute-rules [get]
+func (c *MainController) AgentMuteRules(ctx *gin.Context) {
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if queryUserId := ctx.Query("userId"); queryUserId != "" && queryUserId != userId {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Token does not belong to the user"})
+		return
+	}
+
+	rules, err := c.activeMuteRules(ctx, userId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+	})
+}
+
+// activeMuteRules returns the mute rules of the user that have not expired.
+func (c *MainController) activeMuteRules(ctx *gin.Context, userId string) ([]models.MuteRule, error) {
+	rules := make([]models.MuteRule, 0)
+	cursor, err := c.muteRulesCollection.Find(ctx, bson.M{
+		"userId": userId,
+		"$or": []bson.M{
+			{"expiresAt": nil},
+			{"expiresAt": bson.M{"$gt": time.Now()}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
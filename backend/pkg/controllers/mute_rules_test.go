@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestAgentMuteRulesRequireValidToken(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("no token", func(mt *mtest.T) {
+		c := newTestController(mt)
+
+		rec := serve(t, c.AgentMuteRules, "GET", "/mute-rules", "/mute-rules?userId=user-a", "", nil)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d: %s", rec.Code, rec.Body)
+		}
+		if finds := countCommands(mt, "find", "muterules"); finds != 0 {
+			t.Errorf("expected no rules to be read, got %d queries", finds)
+		}
+	})
+	mt.Run("other user", func(mt *mtest.T) {
+		c := newTestController(mt)
+
+		rec := serve(t, c.AgentMuteRules, "GET", "/mute-rules", "/mute-rules?userId=user-a", "user-b", nil)
+
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d: %s", rec.Code, rec.Body)
+		}
+		if finds := countCommands(mt, "find", "muterules"); finds != 0 {
+			t.Errorf("expected no rules to be read, got %d queries", finds)
+		}
+	})
+	mt.Run("own rules", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(found("muterules"))
+
+		rec := serve(t, c.AgentMuteRules, "GET", "/mute-rules", "/mute-rules?userId=user-a", "user-a", nil)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		for _, command := range sentCommands(mt) {
+			if user, _ := command.filter.Lookup("userId").StringValueOK(); command.collection == "muterules" && user != "user-a" {
+				t.Errorf("expected the rules of user-a to be read, got %s", command.filter)
+			}
+		}
+	})
+}
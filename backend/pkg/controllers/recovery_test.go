@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"signalone/pkg/models"
+	"signalone/pkg/utils"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// recoveryConditions returns the conditions of the update a recovery sent
+// on the issues, with values as plain Go types.
+func recoveryConditions(t *testing.T, mt *mtest.T) []bson.M {
+	t.Helper()
+	for _, command := range sentCommands(mt) {
+		if command.name != "update" || command.collection != "issues" {
+			continue
+		}
+		var filter struct {
+			And []bson.M `bson:"$and"`
+		}
+		if err := bson.Unmarshal(command.filter, &filter); err != nil {
+			t.Fatal(err)
+		}
+		return filter.And
+	}
+	t.Fatal("expected an update of the issues")
+	return nil
+}
+
+// hasCondition reports whether one of the conditions is on the key.
+func hasCondition(conditions []bson.M, key string) bool {
+	for _, condition := range conditions {
+		if _, ok := condition[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func recoveryStates(t *testing.T, conditions []bson.M) map[string]bool {
+	t.Helper()
+	states := make(map[string]bool)
+	for _, condition := range conditions {
+		alternatives, ok := condition["$or"].(bson.A)
+		if !ok {
+			continue
+		}
+		for _, alternative := range alternatives {
+			if state, ok := alternative.(bson.M)["state"].(string); ok {
+				states[state] = true
+			}
+		}
+	}
+	return states
+}
+
+func TestRestartResolvesOpenIssuesOfTheReplica(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("restarted", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(modified(2))
+
+		rec := serve(t, c.RecoveryTask, "PUT", "/issues/recovery", "/issues/recovery", "user-a", RecoveryPayload{
+			UserId:                 "user-a",
+			ContainerName:          "/shop-api-2",
+			ComposeProject:         "shop",
+			ComposeService:         "api",
+			ComposeContainerNumber: 2,
+			Reason:                 models.RecoveryReasonRestarted,
+		})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		conditions := recoveryConditions(t, mt)
+		if hasCondition(conditions, "containerName") {
+			t.Errorf("expected the replica, not the container name, to be matched, got %v", conditions)
+		}
+		replica := false
+		for _, condition := range conditions {
+			if condition["composeService"] == "api" && condition["composeContainerNumber"] == int32(2) {
+				replica = true
+			}
+		}
+		if !replica {
+			t.Errorf("expected the issues of replica 2 of shop/api to be matched, got %v", conditions)
+		}
+		states := recoveryStates(t, conditions)
+		if !states[models.IssueStateOpen] || !states[models.IssueStateReopened] {
+			t.Errorf("expected open and reopened issues to be resolved, got %v", states)
+		}
+		if states[models.IssueStateAcknowledged] || states[models.IssueStateInProgress] {
+			t.Errorf("expected issues being worked on to be left alone, got %v", states)
+		}
+		anomaliesExcluded := false
+		for _, condition := range conditions {
+			if typeCondition, ok := condition["type"].(bson.M); ok && typeCondition["$ne"] == models.IssueTypeAnomaly {
+				anomaliesExcluded = true
+			}
+		}
+		if !anomaliesExcluded {
+			t.Errorf("expected anomalies to be left open by a restart, got %v", conditions)
+		}
+	})
+}
+
+func TestHealthyRecoveryResolvesHealthcheckIssuesOfTheContainer(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("healthy", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(modified(1))
+
+		rec := serve(t, c.RecoveryTask, "PUT", "/issues/recovery", "/issues/recovery", "user-a", RecoveryPayload{
+			UserId:        "user-a",
+			ContainerName: "/api",
+			Reason:        models.RecoveryReasonHealthy,
+		})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		conditions := recoveryConditions(t, mt)
+		if !hasCondition(conditions, "containerName") || hasCondition(conditions, "composeService") {
+			t.Errorf("expected a container without Compose to be matched by name, got %v", conditions)
+		}
+		if !hasCondition(conditions, "health") {
+			t.Errorf("expected only healthcheck issues to be resolved, got %v", conditions)
+		}
+	})
+}
+
+func TestUnsupportedRecoveryReasonIsRejected(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("unsupported", func(mt *mtest.T) {
+		c := newTestController(mt)
+
+		rec := serve(t, c.RecoveryTask, "PUT", "/issues/recovery", "/issues/recovery", "user-a", RecoveryPayload{
+			UserId:        "user-a",
+			ContainerName: "/api",
+			Reason:        "manual",
+		})
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body)
+		}
+		if updates := countCommands(mt, "update", "issues"); updates != 0 {
+			t.Errorf("expected no update, got %d", updates)
+		}
+	})
+}
+
+func TestRecoveryOfAnotherUserIsRejected(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("another user", func(mt *mtest.T) {
+		c := newTestController(mt)
+
+		rec := serve(t, c.RecoveryTask, "PUT", "/issues/recovery", "/issues/recovery", "user-a", RecoveryPayload{
+			UserId:        "user-b",
+			ContainerName: "/api",
+			Reason:        models.RecoveryReasonRestarted,
+		})
+
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d: %s", rec.Code, rec.Body)
+		}
+		if commands := len(sentCommands(mt)); commands != 0 {
+			t.Errorf("expected no issue to be touched, got %d commands", commands)
+		}
+	})
+	mt.Run("no token", func(mt *mtest.T) {
+		c := newTestController(mt)
+
+		rec := serve(t, c.RecoveryTask, "PUT", "/issues/recovery", "/issues/recovery", "", RecoveryPayload{
+			UserId:        "user-a",
+			ContainerName: "/api",
+			Reason:        models.RecoveryReasonRestarted,
+		})
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestRecoveryIsBoundToTheUserOfTheToken(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("without user", func(mt *mtest.T) {
+		c := newTestController(mt)
+		mt.AddMockResponses(modified(1))
+
+		rec := serve(t, c.RecoveryTask, "PUT", "/issues/recovery", "/issues/recovery", "user-a", RecoveryPayload{
+			ContainerName: "/api",
+			Reason:        models.RecoveryReasonRestarted,
+		})
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		conditions := recoveryConditions(t, mt)
+		if len(conditions) == 0 || conditions[0]["userId"] != "user-a" {
+			t.Errorf("expected the issues of the token's user to be resolved, got %v", conditions)
+		}
+	})
+}
+
+func TestRecurrenceReopensRecoveredIssue(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("reopen", func(mt *mtest.T) {
+		c := newTestController(mt)
+		payload := LogAnalysisPayload{
+			UserId:                 "user-a",
+			ContainerName:          "/shop-api-2",
+			ComposeProject:         "shop",
+			ComposeService:         "api",
+			ComposeContainerNumber: 2,
+			Logs:                   "ERROR connection refused",
+			ParsedLogs:             []models.ParsedLogLine{{Level: "error", Message: "connection refused"}},
+		}
+		mt.AddMockResponses(
+			found("users", models.User{UserId: "user-a"}),
+			found("muterules"),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.M{"_id": "issue-1", "userId": "user-a"}}),
+		)
+
+		rec := serve(t, c.LogAnalysisTask, "PUT", "/issues/analysis", "/issues/analysis", "user-a", payload)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		var response struct {
+			IssueId string `json:"issueId"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.IssueId != "issue-1" {
+			t.Errorf("expected the recovered issue to be reopened, got %q", response.IssueId)
+		}
+		if inserts := countCommands(mt, "insert", "issues"); inserts != 0 {
+			t.Errorf("expected no new issue, got %d", inserts)
+		}
+
+		fingerprint := utils.IssueFingerprint("user-a", "/shop-api-2", "shop", "api", 2, models.IssueTypeError,
+			payload.ParsedLogs, nil, nil, nil, nil)
+		for _, command := range sentCommands(mt) {
+			if command.name != "findAndModify" {
+				continue
+			}
+			if got, _ := command.filter.Lookup("fingerprint").StringValueOK(); got != fingerprint {
+				t.Errorf("expected the issue with fingerprint %s to be reopened, got %s", fingerprint, got)
+			}
+			if owner, _ := command.filter.Lookup("userId").StringValueOK(); owner != "user-a" {
+				t.Errorf("expected the reopened issue to be owned by user-a, got %s", command.filter)
+			}
+		}
+	})
+}
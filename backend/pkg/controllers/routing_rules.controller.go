@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"net/http"
+	"signalone/pkg/models"
+	"signalone/pkg/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ListRoutingRules godoc
+// @Summary List the routing rules of the user.
+// @Description List the routing rules of the user in the order they are tried, oldest first.
+// @Tags routingRules
+// @Produce json
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /routing-rules [get]
+func (c *MainController) ListRoutingRules(ctx *gin.Context) {
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules, err := c.routingRules(ctx, userId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+	})
+}
+
+// CreateRoutingRule godoc
+// @Summary Route new issues to an owner.
+// @Description Create a rule assigning the new issues of a container, a Compose project or the containers with a label to a user. The oldest matching rule wins.
+// @Tags routingRules
+// @Accept json
+// @Produce json
+// @Param routingRuleRequest body models.RoutingRuleRequest true "Routing rule"
+// @Success 200 {object} models.RoutingRule
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /routing-rules [post]
+func (c *MainController) CreateRoutingRule(ctx *gin.Context) {
+	var routingRuleReq models.RoutingRuleRequest
+
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&routingRuleReq); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.RoutingRule{
+		Id:             uuid.New().String(),
+		UserId:         userId,
+		ContainerName:  routingRuleReq.ContainerName,
+		ComposeProject: routingRuleReq.ComposeProject,
+		LabelKey:       routingRuleReq.LabelKey,
+		LabelValue:     routingRuleReq.LabelValue,
+		Assignee:       routingRuleReq.Assignee,
+		Comment:        routingRuleReq.Comment,
+		CreatedBy:      userId,
+		CreatedAt:      time.Now(),
+	}
+	if err := utils.ValidateRoutingRule(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := c.routingRulesCollection.InsertOne(ctx, rule); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rule)
+}
+
+// DeleteRoutingRule godoc
+// @Summary Delete a routing rule.
+// @Description Delete a routing rule of the user. Issues it assigned keep their assignee.
+// @Tags routingRules
+// @Produce json
+// @Param id path string true "ID of the routing rule"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /routing-rules/{id} [delete]
+func (c *MainController) DeleteRoutingRule(ctx *gin.Context) {
+	userId, err := getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := c.routingRulesCollection.DeleteOne(ctx, bson.M{"_id": ctx.Param("id"), "userId": userId})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if res.DeletedCount == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Success",
+	})
+}
+
+// routingRules returns the routing rules of the user, oldest first.
+func (c *MainController) routingRules(ctx *gin.Context, userId string) ([]models.RoutingRule, error) {
+	rules := make([]models.RoutingRule, 0)
+	cursor, err := c.routingRulesCollection.Find(ctx, bson.M{"userId": userId}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
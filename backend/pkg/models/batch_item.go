@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	BatchItemStateProcessing = "processing"
+	BatchItemStateProcessed  = "processed"
+)
+
+// BatchItem records an item of an agent batch upload by the user and the id
+// the agent gave it, so an item uploaded again after the agent missed the response is
+// answered with its first outcome instead of raising a second issue.
+type BatchItem struct {
+	Id          string     `json:"id" bson:"_id"`
+	State       string     `json:"state" bson:"state"`
+	ClaimedAt   time.Time  `json:"claimedAt" bson:"claimedAt"`
+	ProcessedAt *time.Time `json:"processedAt,omitempty" bson:"processedAt,omitempty"`
+	IssueId     string     `json:"issueId,omitempty" bson:"issueId,omitempty"`
+	Count       int64      `json:"count,omitempty" bson:"count,omitempty"`
+}
@@ -4,34 +4,271 @@ import (
 	"time"
 )
 
+const (
+	IssueTypeError   = "ERROR"
+	IssueTypeAnomaly = "ANOMALY"
+)
+
+const (
+	RecoveryReasonHealthy   = "healthy"
+	RecoveryReasonStable    = "stable"
+	RecoveryReasonRestarted = "restarted"
+)
+
+// ResolutionReasonManual marks issues resolved by a user. Issues resolved
+// automatically carry the recovery reason of the container instead.
+const ResolutionReasonManual = "manual"
+
+const (
+	IssueStateOpen         = "open"
+	IssueStateAcknowledged = "acknowledged"
+	IssueStateInProgress   = "inProgress"
+	IssueStateResolved     = "resolved"
+	IssueStateIgnored      = "ignored"
+	IssueStateReopened     = "reopened"
+)
+
+// IssueChangedBySystem is recorded as the author of state changes made when
+// the agent reports a recovery or a recurrence.
+const IssueChangedBySystem = "system"
+
 type IssueRateRequest struct {
 	Score *int32 `json:"score" binding:"required"` // it must be a pointer because if we get 0 then the required error arises
 }
+
+type IssueStateRequest struct {
+	State  string `json:"state" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// IssueAssignRequest assigns an issue to the user with the id, or unassigns it
+// when the assignee is empty.
+type IssueAssignRequest struct {
+	Assignee string `json:"assignee"`
+}
+
+type IssueCommentRequest struct {
+	Body     string `json:"body" binding:"required"`
+	ParentId string `json:"parentId"`
+}
+
+// IssueComment is a comment on an issue, or a reply to the comment with the
+// parent id. Deleted comments with replies stay in the thread without a
+// body.
+type IssueComment struct {
+	Id         string     `json:"id" bson:"id"`
+	ParentId   string     `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	AuthorId   string     `json:"authorId" bson:"authorId"`
+	AuthorName string     `json:"authorName" bson:"authorName"`
+	Body       string     `json:"body" bson:"body"`
+	IsDeleted  bool       `json:"isDeleted,omitempty" bson:"isDeleted,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  *time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+}
+
+// IssueStateChange is an entry of the history of an issue.
+type IssueStateChange struct {
+	From      string    `json:"from" bson:"from"`
+	To        string    `json:"to" bson:"to"`
+	ChangedBy string    `json:"changedBy" bson:"changedBy"`
+	Reason    string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+}
 type IssueSolutionPredictionSolutionSource struct {
 	Title string `json:"title" bson:"title"`
 	Url   string `json:"url" bson:"url"`
 }
 
+type ParsedLogLine struct {
+	Format    string            `json:"format" bson:"format"`
+	Level     string            `json:"level,omitempty" bson:"level,omitempty"`
+	Message   string            `json:"message" bson:"message"`
+	Timestamp string            `json:"timestamp,omitempty" bson:"timestamp,omitempty"`
+	Error     string            `json:"error,omitempty" bson:"error,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty" bson:"fields,omitempty"`
+}
+
+type ContainerRun struct {
+	ExitCode   int       `json:"exitCode" bson:"exitCode"`
+	FinishedAt time.Time `json:"finishedAt" bson:"finishedAt"`
+	Logs       string    `json:"logs" bson:"logs"`
+}
+
+type CrashLoopReport struct {
+	Restarts          int            `json:"restarts" bson:"restarts"`
+	WindowSeconds     int            `json:"windowSeconds" bson:"windowSeconds"`
+	TotalRestartCount int            `json:"totalRestartCount" bson:"totalRestartCount"`
+	StartedAt         string         `json:"startedAt" bson:"startedAt"`
+	FinishedAt        string         `json:"finishedAt" bson:"finishedAt"`
+	Runs              []ContainerRun `json:"runs" bson:"runs"`
+}
+
+type TerminationReport struct {
+	ExitCode             int    `json:"exitCode" bson:"exitCode"`
+	ExitCodeMeaning      string `json:"exitCodeMeaning" bson:"exitCodeMeaning"`
+	Signal               string `json:"signal,omitempty" bson:"signal,omitempty"`
+	OOMKilled            bool   `json:"oomKilled" bson:"oomKilled"`
+	Error                string `json:"error,omitempty" bson:"error,omitempty"`
+	FinishedAt           string `json:"finishedAt" bson:"finishedAt"`
+	MemoryLimitBytes     int64  `json:"memoryLimitBytes" bson:"memoryLimitBytes"`
+	LastMemoryUsageBytes uint64 `json:"lastMemoryUsageBytes" bson:"lastMemoryUsageBytes"`
+	RestartPolicy        string `json:"restartPolicy" bson:"restartPolicy"`
+	MaximumRetryCount    int    `json:"maximumRetryCount" bson:"maximumRetryCount"`
+	RestartCount         int    `json:"restartCount" bson:"restartCount"`
+}
+
+type HealthProbe struct {
+	Start    time.Time `json:"start" bson:"start"`
+	End      time.Time `json:"end" bson:"end"`
+	ExitCode int       `json:"exitCode" bson:"exitCode"`
+	Output   string    `json:"output" bson:"output"`
+}
+
+type HealthReport struct {
+	Status        string        `json:"status" bson:"status"`
+	FailingStreak int           `json:"failingStreak" bson:"failingStreak"`
+	Probes        []HealthProbe `json:"probes" bson:"probes"`
+}
+
+type ResourceSample struct {
+	Timestamp                time.Time `json:"timestamp" bson:"timestamp"`
+	CpuPercent               float64   `json:"cpuPercent" bson:"cpuPercent"`
+	CpuLimitPercent          float64   `json:"cpuLimitPercent" bson:"cpuLimitPercent"`
+	MemoryUsageBytes         uint64    `json:"memoryUsageBytes" bson:"memoryUsageBytes"`
+	MemoryLimitBytes         uint64    `json:"memoryLimitBytes" bson:"memoryLimitBytes"`
+	NetworkRxBytesPerSecond  float64   `json:"networkRxBytesPerSecond" bson:"networkRxBytesPerSecond"`
+	NetworkTxBytesPerSecond  float64   `json:"networkTxBytesPerSecond" bson:"networkTxBytesPerSecond"`
+	BlockReadBytesPerSecond  float64   `json:"blockReadBytesPerSecond" bson:"blockReadBytesPerSecond"`
+	BlockWriteBytesPerSecond float64   `json:"blockWriteBytesPerSecond" bson:"blockWriteBytesPerSecond"`
+	Pids                     uint64    `json:"pids" bson:"pids"`
+	PidsLimit                uint64    `json:"pidsLimit" bson:"pidsLimit"`
+}
+
+type LogRateSample struct {
+	Timestamp      time.Time `json:"timestamp" bson:"timestamp"`
+	LinesPerSecond float64   `json:"linesPerSecond" bson:"linesPerSecond"`
+	BaselineMean   float64   `json:"baselineMean" bson:"baselineMean"`
+	BaselineStdDev float64   `json:"baselineStdDev" bson:"baselineStdDev"`
+}
+
+type AnomalyReport struct {
+	Kind           string           `json:"kind" bson:"kind"`
+	Description    string           `json:"description" bson:"description"`
+	ResourceSeries []ResourceSample `json:"resourceSeries,omitempty" bson:"resourceSeries,omitempty"`
+	LogRateSeries  []LogRateSample  `json:"logRateSeries,omitempty" bson:"logRateSeries,omitempty"`
+}
+
+// LogContextLine is a line of the log context leading up to a detection.
+// Triggering lines are the ones that raised the detection.
+type LogContextLine struct {
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	Line      string    `json:"line" bson:"line"`
+	IsTrigger bool      `json:"isTrigger,omitempty" bson:"isTrigger,omitempty"`
+}
+
+type ContainerMount struct {
+	Type        string `json:"type" bson:"type"`
+	Source      string `json:"source" bson:"source"`
+	Destination string `json:"destination" bson:"destination"`
+	ReadWrite   bool   `json:"readWrite" bson:"readWrite"`
+}
+
+type ContainerResources struct {
+	MemoryBytes            int64 `json:"memoryBytes" bson:"memoryBytes"`
+	MemoryReservationBytes int64 `json:"memoryReservationBytes" bson:"memoryReservationBytes"`
+	NanoCpus               int64 `json:"nanoCpus" bson:"nanoCpus"`
+	CpuShares              int64 `json:"cpuShares" bson:"cpuShares"`
+	PidsLimit              int64 `json:"pidsLimit" bson:"pidsLimit"`
+}
+
+// ContainerSnapshot is the configuration of the container at the time of the
+// detection, with secrets masked by the agent.
+type ContainerSnapshot struct {
+	Image             string             `json:"image" bson:"image"`
+	ImageId           string             `json:"imageId" bson:"imageId"`
+	ImageDigest       string             `json:"imageDigest,omitempty" bson:"imageDigest,omitempty"`
+	Command           []string           `json:"command" bson:"command"`
+	Entrypoint        []string           `json:"entrypoint" bson:"entrypoint"`
+	Env               []string           `json:"env" bson:"env"`
+	Mounts            []ContainerMount   `json:"mounts" bson:"mounts"`
+	Ports             []string           `json:"ports" bson:"ports"`
+	Networks          []string           `json:"networks" bson:"networks"`
+	RestartPolicy     string             `json:"restartPolicy" bson:"restartPolicy"`
+	MaximumRetryCount int                `json:"maximumRetryCount" bson:"maximumRetryCount"`
+	Resources         ContainerResources `json:"resources" bson:"resources"`
+	Labels            map[string]string  `json:"labels" bson:"labels"`
+}
+
 type IssueSearchResult struct {
-	Id            string    `json:"id" bson:"_id"`
-	ContainerName string    `json:"containerName" bson:"containerName"`
-	Title         string    `json:"title" bson:"title"`
-	IsResolved    bool      `json:"isResolved" bson:"isResolved"`
-	TimeStamp     time.Time `json:"timestamp" bson:"timestamp"`
-	Severity      string    `json:"severity" bson:"severity"`
+	Id               string    `json:"id" bson:"_id"`
+	ContainerName    string    `json:"containerName" bson:"containerName"`
+	ComposeProject   string    `json:"composeProject" bson:"composeProject"`
+	ComposeService   string    `json:"composeService" bson:"composeService"`
+	Title            string    `json:"title" bson:"title"`
+	Type             string    `json:"type" bson:"type"`
+	IsResolved       bool      `json:"isResolved" bson:"isResolved"`
+	State            string    `json:"state" bson:"state"`
+	ResolutionReason string    `json:"resolutionReason,omitempty" bson:"resolutionReason,omitempty"`
+	TimeStamp        time.Time `json:"timestamp" bson:"timestamp"`
+	LastActivityAt   time.Time `json:"lastActivityAt" bson:"lastActivityAt"`
+	Assignee         string    `json:"assignee,omitempty" bson:"assignee,omitempty"`
+	Tags             []string  `json:"tags,omitempty" bson:"tags,omitempty"`
+	Severity         string    `json:"severity" bson:"severity"`
+}
+
+// IssueGroup counts the issues of a Compose project, or of a service within
+// a project, matching a search.
+type IssueGroup struct {
+	ComposeProject  string    `json:"composeProject" bson:"composeProject"`
+	ComposeService  string    `json:"composeService,omitempty" bson:"composeService,omitempty"`
+	Count           int64     `json:"count" bson:"count"`
+	LatestTimestamp time.Time `json:"latestTimestamp" bson:"latestTimestamp"`
+}
+
+// ContainerGroup lists the containers of a Compose project, or of a service
+// within a project, that raised issues.
+type ContainerGroup struct {
+	ComposeProject string   `json:"composeProject" bson:"composeProject"`
+	ComposeService string   `json:"composeService,omitempty" bson:"composeService,omitempty"`
+	Containers     []string `json:"containers" bson:"containers"`
 }
 
 type Issue struct {
-	Id                        string    `json:"id" bson:"_id"`
-	UserId                    string    `json:"userId" bson:"userId"`
-	ContainerName             string    `json:"containerName" bson:"containerName"`
-	Score                     int32     `json:"score" bson:"score" binding:"odeof=-1 0 1"`
-	Severity                  string    `json:"severity" bson:"severity"`
-	Logs                      []string  `json:"logs" bson:"logs"`
-	Title                     string    `json:"title" bson:"title"`
-	IsResolved                bool      `json:"isResolved" bson:"isResolved"`
-	TimeStamp                 time.Time `json:"timestamp" bson:"timestamp"`
-	LogSummary                string    `json:"logSummary" bson:"logSummary"`
-	PredictedSolutionsSummary string    `json:"predictedSolutionsSummary" bson:"predictedSolutionsSummary"`
-	PredictedSolutionsSources []string  `json:"issuePredictedSolutionsSources" bson:"issuePredictedSolutionsSources"`
+	Id                        string             `json:"id" bson:"_id"`
+	UserId                    string             `json:"userId" bson:"userId"`
+	ContainerName             string             `json:"containerName" bson:"containerName"`
+	ComposeProject            string             `json:"composeProject" bson:"composeProject"`
+	ComposeService            string             `json:"composeService" bson:"composeService"`
+	ComposeContainerNumber    int                `json:"composeContainerNumber" bson:"composeContainerNumber"`
+	Score                     int32              `json:"score" bson:"score" binding:"odeof=-1 0 1"`
+	Severity                  string             `json:"severity" bson:"severity"`
+	Type                      string             `json:"type" bson:"type"`
+	Logs                      []string           `json:"logs" bson:"logs"`
+	ParsedLogs                []ParsedLogLine    `json:"parsedLogs" bson:"parsedLogs"`
+	LogContext                []LogContextLine   `json:"logContext,omitempty" bson:"logContext,omitempty"`
+	CrashLoop                 *CrashLoopReport   `json:"crashLoop,omitempty" bson:"crashLoop,omitempty"`
+	Termination               *TerminationReport `json:"termination,omitempty" bson:"termination,omitempty"`
+	Health                    *HealthReport      `json:"health,omitempty" bson:"health,omitempty"`
+	Anomaly                   *AnomalyReport     `json:"anomaly,omitempty" bson:"anomaly,omitempty"`
+	ContainerSnapshot         *ContainerSnapshot `json:"containerSnapshot,omitempty" bson:"containerSnapshot,omitempty"`
+	Title                     string             `json:"title" bson:"title"`
+	IsResolved                bool               `json:"isResolved" bson:"isResolved"`
+	State                     string             `json:"state" bson:"state"`
+	History                   []IssueStateChange `json:"history" bson:"history"`
+	Comments                  []IssueComment     `json:"comments" bson:"comments"`
+	ResolutionReason          string             `json:"resolutionReason,omitempty" bson:"resolutionReason,omitempty"`
+	ResolvedAt                *time.Time         `json:"resolvedAt,omitempty" bson:"resolvedAt,omitempty"`
+	Fingerprint               string             `json:"fingerprint" bson:"fingerprint"`
+	MuteRuleId                string             `json:"muteRuleId,omitempty" bson:"muteRuleId,omitempty"`
+	Assignee                  string             `json:"assignee,omitempty" bson:"assignee,omitempty"`
+	AssignedAt                *time.Time         `json:"assignedAt,omitempty" bson:"assignedAt,omitempty"`
+	RoutingRuleId             string             `json:"routingRuleId,omitempty" bson:"routingRuleId,omitempty"`
+	Tags                      []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+	ReopenCount               int                `json:"reopenCount" bson:"reopenCount"`
+	ReopenedAt                *time.Time         `json:"reopenedAt,omitempty" bson:"reopenedAt,omitempty"`
+	TimeStamp                 time.Time          `json:"timestamp" bson:"timestamp"`
+	LastActivityAt            time.Time          `json:"lastActivityAt" bson:"lastActivityAt"`
+	LogSummary                string             `json:"logSummary" bson:"logSummary"`
+	PredictedSolutionsSummary string             `json:"predictedSolutionsSummary" bson:"predictedSolutionsSummary"`
+	PredictedSolutionsSources []string           `json:"issuePredictedSolutionsSources" bson:"issuePredictedSolutionsSources"`
 }
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	MuteActionDrop = "drop"
+	MuteActionMark = "mark"
+)
+
+// MuteRule silences the detections matching every criterion it sets, until
+// it expires. Dropped detections raise no issue; marked ones raise issues
+// hidden from searches unless muted issues are asked for.
+type MuteRule struct {
+	Id            string     `json:"id" bson:"_id"`
+	UserId        string     `json:"userId" bson:"userId"`
+	ContainerName string     `json:"containerName,omitempty" bson:"containerName,omitempty"`
+	Fingerprint   string     `json:"fingerprint,omitempty" bson:"fingerprint,omitempty"`
+	Pattern       string     `json:"pattern,omitempty" bson:"pattern,omitempty"`
+	Severity      string     `json:"severity,omitempty" bson:"severity,omitempty"`
+	Action        string     `json:"action" bson:"action"`
+	Comment       string     `json:"comment,omitempty" bson:"comment,omitempty"`
+	CreatedBy     string     `json:"createdBy" bson:"createdBy"`
+	CreatedAt     time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
+
+type MuteRuleRequest struct {
+	ContainerName string     `json:"containerName"`
+	Fingerprint   string     `json:"fingerprint"`
+	Pattern       string     `json:"pattern"`
+	Severity      string     `json:"severity"`
+	Action        string     `json:"action"`
+	Comment       string     `json:"comment"`
+	ExpiresAt     *time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// RoutingRule assigns the new issues of the containers matching every
+// criterion it sets to an owner.
+type RoutingRule struct {
+	Id             string    `json:"id" bson:"_id"`
+	UserId         string    `json:"userId" bson:"userId"`
+	ContainerName  string    `json:"containerName,omitempty" bson:"containerName,omitempty"`
+	ComposeProject string    `json:"composeProject,omitempty" bson:"composeProject,omitempty"`
+	LabelKey       string    `json:"labelKey,omitempty" bson:"labelKey,omitempty"`
+	LabelValue     string    `json:"labelValue,omitempty" bson:"labelValue,omitempty"`
+	Assignee       string    `json:"assignee" bson:"assignee"`
+	Comment        string    `json:"comment,omitempty" bson:"comment,omitempty"`
+	CreatedBy      string    `json:"createdBy" bson:"createdBy"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+type RoutingRuleRequest struct {
+	ContainerName  string `json:"containerName"`
+	ComposeProject string `json:"composeProject"`
+	LabelKey       string `json:"labelKey"`
+	LabelValue     string `json:"labelValue"`
+	Assignee       string `json:"assignee" binding:"required"`
+	Comment        string `json:"comment"`
+}
@@ -30,9 +30,22 @@ func (mr *MainRouter) RegisterRoutes(rg *gin.RouterGroup) {
 		userRouterGroup.POST("/agent/authenticate", func(c *gin.Context) {})
 		userRouterGroup.GET("/containers", mr.mainController.GetContainers)
 		userRouterGroup.GET("/issues", mr.mainController.IssuesSearch)
+		userRouterGroup.POST("/issues/bulk", mr.mainController.BulkIssues)
 		userRouterGroup.GET("/issues/:id", mr.mainController.GetIssue)
 		userRouterGroup.POST("/issues/:id", mr.mainController.ResolveIssue)
+		userRouterGroup.POST("/issues/:id/reopen", mr.mainController.ReopenIssue)
+		userRouterGroup.PUT("/issues/:id/state", mr.mainController.UpdateIssueState)
 		userRouterGroup.PUT("/issues/:id/score", mr.mainController.RateIssue)
+		userRouterGroup.PUT("/issues/:id/assignee", mr.mainController.AssignIssue)
+		userRouterGroup.POST("/issues/:id/comments", mr.mainController.AddIssueComment)
+		userRouterGroup.PUT("/issues/:id/comments/:commentId", mr.mainController.UpdateIssueComment)
+		userRouterGroup.DELETE("/issues/:id/comments/:commentId", mr.mainController.DeleteIssueComment)
+		userRouterGroup.GET("/mute-rules", mr.mainController.ListMuteRules)
+		userRouterGroup.POST("/mute-rules", mr.mainController.CreateMuteRule)
+		userRouterGroup.DELETE("/mute-rules/:id", mr.mainController.DeleteMuteRule)
+		userRouterGroup.GET("/routing-rules", mr.mainController.ListRoutingRules)
+		userRouterGroup.POST("/routing-rules", mr.mainController.CreateRoutingRule)
+		userRouterGroup.DELETE("/routing-rules/:id", mr.mainController.DeleteRoutingRule)
 		userRouterGroup.GET("/settings", func(c *gin.Context) {})
 		userRouterGroup.POST("/settings", func(c *gin.Context) {})
 	}
@@ -40,4 +53,7 @@ func (mr *MainRouter) RegisterRoutes(rg *gin.RouterGroup) {
 	agentRouterGroup := rg.Group("/agent")
 	agentRouterGroup.DELETE("/issues", mr.mainController.DeleteIssues)
 	agentRouterGroup.PUT("/issues/analysis", mr.mainController.LogAnalysisTask)
+	agentRouterGroup.PUT("/issues/recovery", mr.mainController.RecoveryTask)
+	agentRouterGroup.PUT("/issues/batch", mr.mainController.BatchIngestionTask)
+	agentRouterGroup.GET("/mute-rules", mr.mainController.AgentMuteRules)
 }
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var ErrUnsupportedContentEncoding = errors.New("Unsupported content encoding")
+
+// DecompressRequestBody wraps a request body into a reader decoding its
+// Content-Encoding. Uncompressed, gzip and zstd bodies are supported.
+func DecompressRequestBody(body io.Reader, contentEncoding string, maxSize int64) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip":
+		return gzip.NewReader(body)
+	case "zstd":
+		decoder, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(maxSize)))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, ErrUnsupportedContentEncoding
+}
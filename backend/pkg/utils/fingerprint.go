@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"signalone/pkg/models"
+	"strings"
+)
+
+var (
+	fingerprintUuidPattern   = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	fingerprintHexPattern    = regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]{8,}\b`)
+	fingerprintNumberPattern = regexp.MustCompile(`[\w.:/-]*\d[\w.:/-]*`)
+
+	// The keywords the agent judges log lines without a level by.
+	errorOrWarningPattern = regexp.MustCompile(`(?i)(abort|blocked|corrupt|crash|critical|deadlock|denied|err|error|exception|fatal|forbidden|freeze|hang|illegal|invalid|issue|missing|panic|rejected|refused|stacktrace|timeout|traceback|unauthorized|uncaught|unexpected|unhandled|unimplemented|unsupported|warn|warning)`)
+	criticalPattern       = regexp.MustCompile(`(?i)(crash|critical|deadlock|fatal|panic)`)
+	errorPattern          = regexp.MustCompile(`(?i)(abort|corrupt|denied|err|error|exception|forbidden|illegal|refused|rejected|traceback|unauthorized|uncaught|unhandled)`)
+)
+
+// IssueFingerprint identifies a problem of a container across detections.
+// Recreated containers of a Compose replica share fingerprints, while each
+// replica has its own, the way recoveries are matched to issues. Log events
+// are compared by their most severe message with ids and numbers left out,
+// so a recurrence matches the issue it was raised before.
+// The agent derives the same fingerprints to apply mute rules; both are
+// checked against testdata/fingerprint_vectors.json at the repository root.
+func IssueFingerprint(userId string, containerName string, composeProject string, composeService string,
+	composeContainerNumber int, issueType string,
+	parsedLogs []models.ParsedLogLine, crashLoop *models.CrashLoopReport, termination *models.TerminationReport,
+	health *models.HealthReport, anomaly *models.AnomalyReport) string {
+	scope := containerName
+	if composeService != "" {
+		scope = fmt.Sprintf("%s/%s/%d", composeProject, composeService, composeContainerNumber)
+	}
+	var signature string
+	switch {
+	case crashLoop != nil:
+		signature = "crash-loop"
+	case anomaly != nil:
+		signature = "anomaly:" + anomaly.Kind
+	case health != nil:
+		signature = "unhealthy"
+	case termination != nil:
+		signature = fmt.Sprintf("exit:%d:%t", termination.ExitCode, termination.OOMKilled)
+	default:
+		signature = "log:" + NormalizeLogMessage(mostSevereMessage(parsedLogs))
+	}
+	hash := sha256.Sum256([]byte(strings.Join([]string{userId, scope, issueType, signature}, "\n")))
+	return hex.EncodeToString(hash[:16])
+}
+
+// NormalizeLogMessage masks the parts of a log message that change between
+// occurrences of the same problem: uuids, hex ids and addresses, and any word
+// with a digit in it, like timestamps, request ids, ports and counts. Plain
+// log lines without a level keep their timestamp in the message, so it has to
+// go as a whole.
+func NormalizeLogMessage(message string) string {
+	message = fingerprintUuidPattern.ReplaceAllString(message, "<uuid>")
+	message = fingerprintHexPattern.ReplaceAllString(message, "<hex>")
+	message = fingerprintNumberPattern.ReplaceAllString(message, "<n>")
+	return strings.ToLower(strings.Join(strings.Fields(message), " "))
+}
+
+// mostSevereMessage returns the message of the line reporting the most
+// severe problem, judging lines without a level by their keywords, so the
+// lines logged around a problem do not change its fingerprint.
+func mostSevereMessage(parsedLogs []models.ParsedLogLine) string {
+	levels := make([]string, len(parsedLogs))
+	for i, parsed := range parsedLogs {
+		levels[i] = logLineProblemLevel(parsed)
+	}
+	for _, level := range []string{"critical", "error", "warning"} {
+		for i, parsed := range parsedLogs {
+			if levels[i] == level {
+				return parsed.Message
+			}
+		}
+	}
+	if len(parsedLogs) == 0 {
+		return ""
+	}
+	return parsedLogs[0].Message
+}
+
+// logLineProblemLevel returns the level of the problem a parsed log line
+// reports the way the agent does, or an empty string if it reports none.
+func logLineProblemLevel(parsed models.ParsedLogLine) string {
+	if parsed.Level != "" {
+		switch parsed.Level {
+		case "critical", "error", "warning":
+			return parsed.Level
+		}
+		return ""
+	}
+	if parsed.Error != "" {
+		return "error"
+	}
+	switch {
+	case !errorOrWarningPattern.MatchString(parsed.Message):
+		return ""
+	case criticalPattern.MatchString(parsed.Message):
+		return "critical"
+	case errorPattern.MatchString(parsed.Message):
+		return "error"
+	}
+	return "warning"
+}
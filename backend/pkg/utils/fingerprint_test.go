@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"signalone/pkg/models"
+	"strings"
+	"testing"
+)
+
+// fingerprintVectorsFile is shared with the agent tests, so the agent and the
+// backend fail together when their fingerprints drift apart.
+const fingerprintVectorsFile = "../../../testdata/fingerprint_vectors.json"
+
+type fingerprintVector struct {
+	Name    string `json:"name"`
+	UserId  string `json:"userId"`
+	Payload struct {
+		ContainerName          string                    `json:"containerName"`
+		ComposeProject         string                    `json:"composeProject"`
+		ComposeService         string                    `json:"composeService"`
+		ComposeContainerNumber int                       `json:"composeContainerNumber"`
+		IssueType              string                    `json:"issueType"`
+		ParsedLogs             []models.ParsedLogLine    `json:"parsedLogs"`
+		CrashLoop              *models.CrashLoopReport   `json:"crashLoop"`
+		Termination            *models.TerminationReport `json:"termination"`
+		Health                 *models.HealthReport      `json:"health"`
+		Anomaly                *models.AnomalyReport     `json:"anomaly"`
+	} `json:"payload"`
+	Fingerprint string `json:"fingerprint"`
+	Severity    string `json:"severity"`
+}
+
+func TestIssueFingerprintMatchesSharedVectors(t *testing.T) {
+	data, err := os.ReadFile(fingerprintVectorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []fingerprintVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	for _, vector := range vectors {
+		t.Run(vector.Name, func(t *testing.T) {
+			payload := vector.Payload
+			issueType := strings.ToUpper(payload.IssueType)
+			if issueType == "" {
+				issueType = models.IssueTypeError
+			}
+			fingerprint := IssueFingerprint(vector.UserId, payload.ContainerName, payload.ComposeProject, payload.ComposeService,
+				payload.ComposeContainerNumber, issueType,
+				payload.ParsedLogs, payload.CrashLoop, payload.Termination, payload.Health, payload.Anomaly)
+			if fingerprint != vector.Fingerprint {
+				t.Errorf("expected fingerprint %s, got %s", vector.Fingerprint, fingerprint)
+			}
+			if severity := ClassifyIssueSeverity(issueType, payload.ParsedLogs, payload.Termination); severity != vector.Severity {
+				t.Errorf("expected severity %s, got %s", vector.Severity, severity)
+			}
+		})
+	}
+}
+
+func TestNormalizeLogMessageMasksChangingParts(t *testing.T) {
+	first := NormalizeLogMessage("2024-05-01T10:00:00.123Z req-a1b2c3 user 42 at 0xc000123 failed")
+	second := NormalizeLogMessage("2024-05-02T18:30:12.999Z  req-ff9911 user 7 at 0xc000999 failed")
+	if first != second {
+		t.Errorf("expected recurrences to normalize alike, got %q and %q", first, second)
+	}
+	if !strings.Contains(first, "failed") || !strings.Contains(first, "user") {
+		t.Errorf("expected words without digits to be kept, got %q", first)
+	}
+}
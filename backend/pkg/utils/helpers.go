@@ -3,10 +3,12 @@ package utils
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"signalone/cmd/config"
 	"signalone/pkg/models"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
@@ -15,6 +17,162 @@ func CalculateNewCounter(currentScore int32, newScore int32, counter int32) int3
 	return counter + (newScore - currentScore)
 }
 
+// ClassifyIssueSeverity derives the issue severity from the container
+// termination report, falling back to the log levels the agent extracted.
+// Anomalies are early warnings unless the container already failed.
+func ClassifyIssueSeverity(issueType string, parsedLogs []models.ParsedLogLine, termination *models.TerminationReport) string {
+	if termination != nil && (termination.OOMKilled || termination.ExitCode != 0) {
+		return "CRITICAL"
+	}
+	if issueType == models.IssueTypeAnomaly {
+		return "WARNING"
+	}
+	return SeverityFromParsedLogs(parsedLogs)
+}
+
+// BuildAnalysisInput prefixes the logs sent to the prediction agent with a
+// summary of the container termination and healthcheck state, so the
+// analysis does not have to guess why the container stopped, is unhealthy or
+// behaves unusually. The container configuration comes last, so suggested
+// fixes can refer to the actual image, command, mounts and limits.
+func BuildAnalysisInput(logs string, termination *models.TerminationReport, health *models.HealthReport, anomaly *models.AnomalyReport,
+	snapshot *models.ContainerSnapshot) string {
+	summary := append(describeHealth(health), describeTermination(termination)...)
+	summary = append(summary, describeAnomaly(anomaly)...)
+	summary = append(summary, describeContainerSnapshot(snapshot)...)
+	if len(summary) == 0 {
+		return logs
+	}
+	return strings.Join(summary, " ") + "\n" + logs
+}
+
+// JoinLogContext returns the log context of a detection as text, so the
+// analysis sees the lines leading up to the triggering ones.
+func JoinLogContext(lines []models.LogContextLine) string {
+	texts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		texts = append(texts, line.Line)
+	}
+	return strings.Join(texts, "\n")
+}
+
+func describeHealth(health *models.HealthReport) []string {
+	if health == nil {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("Container healthcheck is %s after %d consecutive failed probes.", health.Status, health.FailingStreak),
+	}
+}
+
+func describeAnomaly(anomaly *models.AnomalyReport) []string {
+	if anomaly == nil {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("Anomaly detected in container behaviour (%s): %s.", anomaly.Kind, anomaly.Description),
+	}
+}
+
+func describeContainerSnapshot(snapshot *models.ContainerSnapshot) []string {
+	if snapshot == nil {
+		return nil
+	}
+	image := snapshot.Image
+	if snapshot.ImageDigest != "" {
+		image += " (" + snapshot.ImageDigest + ")"
+	}
+	summary := []string{fmt.Sprintf("Container image: %s.", image)}
+	if command := append(append([]string(nil), snapshot.Entrypoint...), snapshot.Command...); len(command) > 0 {
+		summary = append(summary, fmt.Sprintf("Command: %s.", strings.Join(command, " ")))
+	}
+	if len(snapshot.Env) > 0 {
+		names := make([]string, 0, len(snapshot.Env))
+		for _, env := range snapshot.Env {
+			name, _, _ := strings.Cut(env, "=")
+			names = append(names, name)
+		}
+		summary = append(summary, fmt.Sprintf("Environment variables set: %s.", strings.Join(names, ", ")))
+	}
+	if len(snapshot.Mounts) > 0 {
+		mounts := make([]string, 0, len(snapshot.Mounts))
+		for _, mount := range snapshot.Mounts {
+			mode := "ro"
+			if mount.ReadWrite {
+				mode = "rw"
+			}
+			mounts = append(mounts, fmt.Sprintf("%s %s:%s (%s)", mount.Type, mount.Source, mount.Destination, mode))
+		}
+		summary = append(summary, fmt.Sprintf("Mounts: %s.", strings.Join(mounts, ", ")))
+	}
+	if len(snapshot.Ports) > 0 {
+		summary = append(summary, fmt.Sprintf("Ports: %s.", strings.Join(snapshot.Ports, ", ")))
+	}
+	if len(snapshot.Networks) > 0 {
+		summary = append(summary, fmt.Sprintf("Networks: %s.", strings.Join(snapshot.Networks, ", ")))
+	}
+	limits := make([]string, 0)
+	if snapshot.Resources.MemoryBytes > 0 {
+		limits = append(limits, fmt.Sprintf("memory %d MiB", snapshot.Resources.MemoryBytes/(1<<20)))
+	}
+	if snapshot.Resources.NanoCpus > 0 {
+		limits = append(limits, fmt.Sprintf("%.2f CPUs", float64(snapshot.Resources.NanoCpus)/1e9))
+	}
+	if snapshot.Resources.PidsLimit > 0 {
+		limits = append(limits, fmt.Sprintf("%d processes", snapshot.Resources.PidsLimit))
+	}
+	if len(limits) > 0 {
+		summary = append(summary, fmt.Sprintf("Resource limits: %s.", strings.Join(limits, ", ")))
+	}
+	return summary
+}
+
+func describeTermination(termination *models.TerminationReport) []string {
+	if termination == nil {
+		return nil
+	}
+	summary := []string{
+		fmt.Sprintf("Container exited with code %d: %s.", termination.ExitCode, termination.ExitCodeMeaning),
+	}
+	if termination.Signal != "" {
+		summary = append(summary, fmt.Sprintf("The process was terminated by %s.", termination.Signal))
+	}
+	if termination.OOMKilled {
+		summary = append(summary, "The container was killed by the kernel OOM killer.")
+	}
+	if termination.MemoryLimitBytes > 0 {
+		summary = append(summary, fmt.Sprintf("Memory limit: %d MiB, last memory usage: %d MiB.",
+			termination.MemoryLimitBytes/(1<<20), termination.LastMemoryUsageBytes/(1<<20)))
+	} else if termination.LastMemoryUsageBytes > 0 {
+		summary = append(summary, fmt.Sprintf("No memory limit, last memory usage: %d MiB.", termination.LastMemoryUsageBytes/(1<<20)))
+	}
+	if termination.Error != "" {
+		summary = append(summary, fmt.Sprintf("Docker reported error: %s.", termination.Error))
+	}
+	if termination.RestartPolicy != "" && termination.RestartPolicy != "no" {
+		summary = append(summary, fmt.Sprintf("Restart policy: %s, restarted %d times.", termination.RestartPolicy, termination.RestartCount))
+	}
+	return summary
+}
+
+// SeverityFromParsedLogs derives the issue severity from the log levels the
+// agent extracted. Issues without a recognised level stay critical.
+func SeverityFromParsedLogs(parsedLogs []models.ParsedLogLine) string {
+	hasWarning := false
+	for _, parsed := range parsedLogs {
+		switch parsed.Level {
+		case "error", "critical":
+			return "CRITICAL"
+		case "warning":
+			hasWarning = true
+		}
+	}
+	if hasWarning {
+		return "WARNING"
+	}
+	return "CRITICAL"
+}
+
 func GenerateFilter(fields bson.M, operator string) bson.M {
 	conditions := make([]bson.M, 0, len(fields))
 
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"signalone/pkg/models"
+	"strings"
+	"testing"
+)
+
+func TestClassifyIssueSeverity(t *testing.T) {
+	warningLogs := []models.ParsedLogLine{{Level: "warning"}}
+	tests := []struct {
+		name        string
+		issueType   string
+		parsedLogs  []models.ParsedLogLine
+		termination *models.TerminationReport
+		want        string
+	}{
+		{"OOM killed", models.IssueTypeAnomaly, warningLogs, &models.TerminationReport{OOMKilled: true}, "CRITICAL"},
+		{"non-zero exit code", "", warningLogs, &models.TerminationReport{ExitCode: 1}, "CRITICAL"},
+		{"clean exit falls back to the logs", "", warningLogs, &models.TerminationReport{ExitCode: 0}, "WARNING"},
+		{"anomaly of a running container", models.IssueTypeAnomaly, nil, nil, "WARNING"},
+		{"anomaly of an exited container", models.IssueTypeAnomaly, nil, &models.TerminationReport{ExitCode: 137}, "CRITICAL"},
+		{"logs without termination", "", warningLogs, nil, "WARNING"},
+		{"logs without a level", "", []models.ParsedLogLine{{}}, nil, "CRITICAL"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ClassifyIssueSeverity(test.issueType, test.parsedLogs, test.termination); got != test.want {
+				t.Errorf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestBuildAnalysisInputDescribesTermination(t *testing.T) {
+	tests := []struct {
+		name        string
+		termination *models.TerminationReport
+		contains    []string
+		absent      []string
+	}{
+		{
+			name: "OOM kill with a memory limit",
+			termination: &models.TerminationReport{
+				ExitCode:             137,
+				ExitCodeMeaning:      "Killed by the kernel OOM killer after reaching the memory limit",
+				Signal:               "SIGKILL",
+				OOMKilled:            true,
+				MemoryLimitBytes:     512 << 20,
+				LastMemoryUsageBytes: 511 << 20,
+				RestartPolicy:        "on-failure",
+				RestartCount:         3,
+			},
+			contains: []string{
+				"Container exited with code 137: Killed by the kernel OOM killer after reaching the memory limit.",
+				"The process was terminated by SIGKILL.",
+				"The container was killed by the kernel OOM killer.",
+				"Memory limit: 512 MiB, last memory usage: 511 MiB.",
+				"Restart policy: on-failure, restarted 3 times.",
+			},
+		},
+		{
+			name: "crash without a memory limit",
+			termination: &models.TerminationReport{
+				ExitCode:             139,
+				ExitCodeMeaning:      "Segmentation fault (SIGSEGV), invalid memory access",
+				Signal:               "SIGSEGV",
+				LastMemoryUsageBytes: 64 << 20,
+				Error:                "exec format error",
+				RestartPolicy:        "no",
+			},
+			contains: []string{
+				"Container exited with code 139",
+				"The process was terminated by SIGSEGV.",
+				"No memory limit, last memory usage: 64 MiB.",
+				"Docker reported error: exec format error.",
+			},
+			absent: []string{"OOM killer", "Restart policy"},
+		},
+		{
+			name:        "application error",
+			termination: &models.TerminationReport{ExitCode: 1, ExitCodeMeaning: "Application error"},
+			contains:    []string{"Container exited with code 1: Application error."},
+			absent:      []string{"terminated by", "Memory limit", "No memory limit"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := BuildAnalysisInput("panic: boom", test.termination, nil, nil, nil)
+			summary, logs, found := strings.Cut(input, "\n")
+			if !found || logs != "panic: boom" {
+				t.Fatalf("expected the summary before the logs, got %q", input)
+			}
+			for _, text := range test.contains {
+				if !strings.Contains(summary, text) {
+					t.Errorf("expected %q in %q", text, summary)
+				}
+			}
+			for _, text := range test.absent {
+				if strings.Contains(summary, text) {
+					t.Errorf("expected no %q in %q", text, summary)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildAnalysisInputWithoutReportsIsTheLogs(t *testing.T) {
+	if input := BuildAnalysisInput("panic: boom", nil, nil, nil, nil); input != "panic: boom" {
+		t.Errorf("expected only the logs, got %q", input)
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"signalone/pkg/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// issueStateTransitions lists the states an issue can move to from each
+// state. Closed issues, resolved or ignored, can only be reopened.
+var issueStateTransitions = map[string][]string{
+	models.IssueStateOpen:         {models.IssueStateAcknowledged, models.IssueStateInProgress, models.IssueStateResolved, models.IssueStateIgnored},
+	models.IssueStateAcknowledged: {models.IssueStateInProgress, models.IssueStateResolved, models.IssueStateIgnored},
+	models.IssueStateInProgress:   {models.IssueStateAcknowledged, models.IssueStateResolved, models.IssueStateIgnored},
+	models.IssueStateResolved:     {models.IssueStateReopened},
+	models.IssueStateIgnored:      {models.IssueStateReopened},
+	models.IssueStateReopened:     {models.IssueStateAcknowledged, models.IssueStateInProgress, models.IssueStateResolved, models.IssueStateIgnored},
+}
+
+func IsValidIssueState(state string) bool {
+	_, exists := issueStateTransitions[state]
+	return exists
+}
+
+// IsClosedIssueState reports whether issues in the state count as resolved
+// for clients that only know the isResolved flag.
+func IsClosedIssueState(state string) bool {
+	return state == models.IssueStateResolved || state == models.IssueStateIgnored
+}
+
+// GetIssueState returns the state of an issue, deriving it from the resolved
+// flag for issues stored before issues had states.
+func GetIssueState(state string, isResolved bool) string {
+	if state != "" {
+		return state
+	}
+	if isResolved {
+		return models.IssueStateResolved
+	}
+	return models.IssueStateOpen
+}
+
+func ValidateIssueTransition(from string, to string) error {
+	if !IsValidIssueState(to) {
+		return fmt.Errorf("Unknown issue state: %s", to)
+	}
+	for _, state := range issueStateTransitions[from] {
+		if state == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("Issue cannot move from %s to %s", from, to)
+}
+
+// IssueStateFilter matches the issues in any of the states, including the
+// issues stored before issues had states.
+func IssueStateFilter(states []string) bson.M {
+	conditions := make([]bson.M, 0, len(states))
+	for _, state := range states {
+		conditions = append(conditions, bson.M{"state": state})
+		switch state {
+		case models.IssueStateOpen:
+			conditions = append(conditions, bson.M{"state": bson.M{"$in": bson.A{nil, ""}}, "isResolved": false})
+		case models.IssueStateResolved:
+			conditions = append(conditions, bson.M{"state": bson.M{"$in": bson.A{nil, ""}}, "isResolved": true})
+		}
+	}
+	return bson.M{"$or": conditions}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"signalone/pkg/models"
+	"testing"
+)
+
+func TestValidateIssueTransition(t *testing.T) {
+	states := []string{
+		models.IssueStateOpen,
+		models.IssueStateAcknowledged,
+		models.IssueStateInProgress,
+		models.IssueStateResolved,
+		models.IssueStateIgnored,
+		models.IssueStateReopened,
+	}
+	allowed := map[string][]string{
+		models.IssueStateOpen:         {models.IssueStateAcknowledged, models.IssueStateInProgress, models.IssueStateResolved, models.IssueStateIgnored},
+		models.IssueStateAcknowledged: {models.IssueStateInProgress, models.IssueStateResolved, models.IssueStateIgnored},
+		models.IssueStateInProgress:   {models.IssueStateAcknowledged, models.IssueStateResolved, models.IssueStateIgnored},
+		models.IssueStateResolved:     {models.IssueStateReopened},
+		models.IssueStateIgnored:      {models.IssueStateReopened},
+		models.IssueStateReopened:     {models.IssueStateAcknowledged, models.IssueStateInProgress, models.IssueStateResolved, models.IssueStateIgnored},
+	}
+
+	for _, from := range states {
+		for _, to := range states {
+			want := false
+			for _, state := range allowed[from] {
+				if state == to {
+					want = true
+				}
+			}
+			if err := ValidateIssueTransition(from, to); (err == nil) != want {
+				t.Errorf("%s -> %s: expected allowed %t, got error %v", from, to, want, err)
+			}
+		}
+	}
+	if err := ValidateIssueTransition(models.IssueStateOpen, "closed"); err == nil {
+		t.Error("expected unknown state to be rejected")
+	}
+}
+
+func TestGetIssueStateOfLegacyIssues(t *testing.T) {
+	tests := []struct {
+		state      string
+		isResolved bool
+		want       string
+	}{
+		{"", false, models.IssueStateOpen},
+		{"", true, models.IssueStateResolved},
+		{models.IssueStateIgnored, true, models.IssueStateIgnored},
+		{models.IssueStateAcknowledged, false, models.IssueStateAcknowledged},
+	}
+	for _, test := range tests {
+		if got := GetIssueState(test.state, test.isResolved); got != test.want {
+			t.Errorf("GetIssueState(%q, %t) = %q, want %q", test.state, test.isResolved, got, test.want)
+		}
+	}
+}
+
+func TestIsClosedIssueState(t *testing.T) {
+	for state, want := range map[string]bool{
+		models.IssueStateOpen:       false,
+		models.IssueStateInProgress: false,
+		models.IssueStateReopened:   false,
+		models.IssueStateResolved:   true,
+		models.IssueStateIgnored:    true,
+	} {
+		if got := IsClosedIssueState(state); got != want {
+			t.Errorf("IsClosedIssueState(%q) = %t, want %t", state, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"signalone/pkg/models"
+	"strings"
+	"time"
+)
+
+// ValidateMuteRule normalizes the severity and action of a rule and checks
+// that it sets at least one criterion and a valid pattern.
+func ValidateMuteRule(rule *models.MuteRule) error {
+	if rule.ContainerName == "" && rule.Fingerprint == "" && rule.Pattern == "" && rule.Severity == "" {
+		return errors.New("Mute rule needs a container, fingerprint, pattern or severity")
+	}
+	if rule.Pattern != "" {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("Invalid pattern: %v", err)
+		}
+	}
+	rule.Severity = strings.ToUpper(rule.Severity)
+	if rule.Severity != "" && rule.Severity != "CRITICAL" && rule.Severity != "WARNING" {
+		return fmt.Errorf("Unsupported severity: %s", rule.Severity)
+	}
+	if rule.Action == "" {
+		rule.Action = models.MuteActionDrop
+	}
+	if rule.Action != models.MuteActionDrop && rule.Action != models.MuteActionMark {
+		return fmt.Errorf("Unsupported mute action: %s", rule.Action)
+	}
+	if rule.ExpiresAt != nil && !rule.ExpiresAt.After(time.Now()) {
+		return errors.New("Mute rule expires in the past")
+	}
+	return nil
+}
+
+// MatchMuteRule returns the first rule matching a detection, preferring rules
+// that drop it over rules that only mark it, or nil when none matches.
+func MatchMuteRule(rules []models.MuteRule, containerName string, fingerprint string, severity string, logs string) *models.MuteRule {
+	var match *models.MuteRule
+	for i, rule := range rules {
+		if rule.ContainerName != "" && rule.ContainerName != containerName {
+			continue
+		}
+		if rule.Fingerprint != "" && rule.Fingerprint != fingerprint {
+			continue
+		}
+		if rule.Severity != "" && rule.Severity != severity {
+			continue
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil || !pattern.MatchString(logs) {
+				continue
+			}
+		}
+		if rule.Action == models.MuteActionDrop {
+			return &rules[i]
+		}
+		if match == nil {
+			match = &rules[i]
+		}
+	}
+	return match
+}
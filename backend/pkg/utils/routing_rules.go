@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"errors"
+	"signalone/pkg/models"
+)
+
+// ValidateRoutingRule checks that a rule sets at least one criterion, and a
+// label key along with a label value.
+func ValidateRoutingRule(rule *models.RoutingRule) error {
+	if rule.ContainerName == "" && rule.ComposeProject == "" && rule.LabelKey == "" {
+		return errors.New("Routing rule needs a container, Compose project or label")
+	}
+	if rule.LabelValue != "" && rule.LabelKey == "" {
+		return errors.New("Routing rule needs a label key for the label value")
+	}
+	if rule.Assignee == "" {
+		return errors.New("Routing rule needs an assignee")
+	}
+	return nil
+}
+
+// MatchRoutingRule returns the first rule matching a container, or nil when
+// none matches. A rule with a label key and no value matches any value.
+func MatchRoutingRule(rules []models.RoutingRule, containerName string, composeProject string, labels map[string]string) *models.RoutingRule {
+	for i, rule := range rules {
+		if rule.ContainerName != "" && rule.ContainerName != containerName {
+			continue
+		}
+		if rule.ComposeProject != "" && rule.ComposeProject != composeProject {
+			continue
+		}
+		if rule.LabelKey != "" {
+			value, exists := labels[rule.LabelKey]
+			if !exists || (rule.LabelValue != "" && rule.LabelValue != value) {
+				continue
+			}
+		}
+		return &rules[i]
+	}
+	return nil
+}
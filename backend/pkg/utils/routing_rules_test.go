@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"signalone/pkg/models"
+	"testing"
+)
+
+func TestMatchRoutingRule(t *testing.T) {
+	rules := []models.RoutingRule{
+		{Id: "payments", ComposeProject: "shop", LabelKey: "team", LabelValue: "payments"},
+		{Id: "team", LabelKey: "team"},
+		{Id: "proxy", ContainerName: "/proxy"},
+	}
+	tests := []struct {
+		name           string
+		containerName  string
+		composeProject string
+		labels         map[string]string
+		want           string
+	}{
+		{"every criterion", "/shop-api-1", "shop", map[string]string{"team": "payments"}, "payments"},
+		{"label of another project", "/blog-api-1", "blog", map[string]string{"team": "payments"}, "team"},
+		{"label key only", "/shop-api-1", "shop", map[string]string{"team": "search"}, "team"},
+		{"container name", "/proxy", "", nil, "proxy"},
+		{"no match", "/worker", "", map[string]string{"tier": "backend"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ""
+			if rule := MatchRoutingRule(rules, test.containerName, test.composeProject, test.labels); rule != nil {
+				got = rule.Id
+			}
+			if got != test.want {
+				t.Errorf("expected rule %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateRoutingRule(t *testing.T) {
+	tests := []struct {
+		rule  models.RoutingRule
+		valid bool
+	}{
+		{models.RoutingRule{ComposeProject: "shop", Assignee: "user-a"}, true},
+		{models.RoutingRule{LabelKey: "team", LabelValue: "payments", Assignee: "user-a"}, true},
+		{models.RoutingRule{Assignee: "user-a"}, false},
+		{models.RoutingRule{LabelValue: "payments", Assignee: "user-a"}, false},
+		{models.RoutingRule{ContainerName: "/proxy"}, false},
+	}
+	for _, test := range tests {
+		if err := ValidateRoutingRule(&test.rule); (err == nil) != test.valid {
+			t.Errorf("%+v: expected valid %t, got %v", test.rule, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package agenttest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"signal/models"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// FakeBackend is an HTTP server accepting batch uploads and serving mute
+// rules like the signalone backend. It records the accepted items and can be
+// told to fail whole requests or to reject single items.
+type FakeBackend struct {
+	Server *httptest.Server
+
+	mutex        sync.Mutex
+	requests     int
+	delay        time.Duration
+	failStatus   int
+	rejectStatus map[string]int
+	accepted     []models.BatchIngestionItem
+	encodings    []string
+	muteRules    []models.MuteRule
+}
+
+func NewFakeBackend() *FakeBackend {
+	b := &FakeBackend{
+		rejectStatus: make(map[string]int),
+		accepted:     make([]models.BatchIngestionItem, 0),
+		muteRules:    make([]models.MuteRule, 0),
+	}
+	b.Server = httptest.NewServer(http.HandlerFunc(b.handle))
+	return b
+}
+
+func (b *FakeBackend) URL() string {
+	return b.Server.URL
+}
+
+func (b *FakeBackend) Close() {
+	b.Server.Close()
+}
+
+// FailRequests makes every following request fail with the status code; 0
+// makes requests succeed again.
+func (b *FakeBackend) FailRequests(status int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failStatus = status
+}
+
+// Delay makes the backend wait before answering batch requests, or until the
+// client gave up; 0 answers right away again.
+func (b *FakeBackend) Delay(delay time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.delay = delay
+}
+
+// RejectKind makes the backend answer items of the kind with the status code
+// within an otherwise successful batch; 0 accepts them again.
+func (b *FakeBackend) RejectKind(kind string, status int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if status == 0 {
+		delete(b.rejectStatus, kind)
+		return
+	}
+	b.rejectStatus[kind] = status
+}
+
+// Accepted returns the items accepted so far, in the order they arrived.
+func (b *FakeBackend) Accepted() []models.BatchIngestionItem {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]models.BatchIngestionItem(nil), b.accepted...)
+}
+
+// Requests returns the number of batch requests received.
+func (b *FakeBackend) Requests() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.requests
+}
+
+// SetMuteRules sets the rules returned to agents fetching mute rules.
+func (b *FakeBackend) SetMuteRules(rules ...models.MuteRule) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.muteRules = append([]models.MuteRule(nil), rules...)
+}
+
+// ContentEncodings returns the Content-Encoding of every request received.
+func (b *FakeBackend) ContentEncodings() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]string(nil), b.encodings...)
+}
+
+func (b *FakeBackend) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/api/agent/mute-rules" {
+		b.handleMuteRules(w)
+		return
+	}
+	if r.Method != http.MethodPut || r.URL.Path != "/api/agent/issues/batch" {
+		http.NotFound(w, r)
+		return
+	}
+	// The body is read first, otherwise the server does not notice a client
+	// that gave up while the answer is delayed.
+	body, bodyErr := decodeBody(r)
+	b.mutex.Lock()
+	delay := b.delay
+	b.mutex.Unlock()
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.requests++
+	b.encodings = append(b.encodings, r.Header.Get("Content-Encoding"))
+	if b.failStatus != 0 {
+		w.WriteHeader(b.failStatus)
+		return
+	}
+	if bodyErr != nil {
+		http.Error(w, bodyErr.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	var payload models.BatchIngestionPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response := models.BatchIngestionResponse{
+		Results: make([]models.BatchIngestionResult, 0, len(payload.Items)),
+	}
+	for _, item := range payload.Items {
+		if status, exists := b.rejectStatus[item.Kind]; exists {
+			response.Rejected++
+			response.Results = append(response.Results, models.BatchIngestionResult{
+				Id:     item.Id,
+				Status: status,
+				Error:  http.StatusText(status),
+			})
+			continue
+		}
+		response.Accepted++
+		b.accepted = append(b.accepted, item)
+		response.Results = append(response.Results, models.BatchIngestionResult{
+			Id:     item.Id,
+			Status: http.StatusOK,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (b *FakeBackend) handleMuteRules(w http.ResponseWriter) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.failStatus != 0 {
+		w.WriteHeader(b.failStatus)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MuteRulesResponse{Rules: b.muteRules})
+}
+
+func decodeBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch encoding := r.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	case "zstd":
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		return decoder.DecodeAll(body, nil)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
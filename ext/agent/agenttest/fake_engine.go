@@ -0,0 +1,491 @@
+// Package agenttest provides scriptable fakes of the Docker engine and the
+// signalone backend for testing the agent without either of them.
+package agenttest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	timetypes "github.com/docker/docker/api/types/time"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+)
+
+const (
+	MethodContainerList         = "ContainerList"
+	MethodContainerInspect      = "ContainerInspect"
+	MethodContainerLogs         = "ContainerLogs"
+	MethodEvents                = "Events"
+	MethodContainerStatsOneShot = "ContainerStatsOneShot"
+	MethodImageInspectWithRaw   = "ImageInspectWithRaw"
+)
+
+// FakeContainer is the recorded state of a container in a FakeEngine.
+type FakeContainer struct {
+	ID           string
+	Name         string
+	Labels       map[string]string
+	Tty          bool
+	Image        string
+	ImageID      string
+	Cmd          []string
+	Entrypoint   []string
+	Env          []string
+	Mounts       []types.MountPoint
+	Ports        nat.PortMap
+	Networks     []string
+	State        types.ContainerState
+	RestartCount int
+	HostConfig   *container.HostConfig
+	Stats        *types.StatsJSON
+
+	logs   []fakeLogLine
+	events []events.Message
+}
+
+type fakeLogLine struct {
+	timestamp time.Time
+	stream    stdcopy.StdType
+	text      string
+}
+
+// FakeEngine is an in-memory Docker engine implementing the client calls
+// used by the agent. Tests record container states, logs and events and
+// change them between scans to replay what a real engine would report.
+// Every call can be made to fail or to hang until its context ends.
+type FakeEngine struct {
+	mutex      sync.Mutex
+	containers []*FakeContainer
+	images     map[string]types.ImageInspect
+	failures   map[string]error
+	hangs      map[string]bool
+	calls      map[string]int
+}
+
+func NewFakeEngine() *FakeEngine {
+	return &FakeEngine{
+		containers: make([]*FakeContainer, 0),
+		images:     make(map[string]types.ImageInspect),
+		failures:   make(map[string]error),
+		hangs:      make(map[string]bool),
+		calls:      make(map[string]int),
+	}
+}
+
+// AddContainer records a running container.
+func (e *FakeEngine) AddContainer(id string, name string, labels map[string]string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	e.containers = append(e.containers, &FakeContainer{
+		ID:      id,
+		Name:    name,
+		Labels:  labels,
+		Image:   "fake/" + name + ":latest",
+		ImageID: "sha256:" + id,
+		State: types.ContainerState{
+			Status:     "running",
+			Running:    true,
+			StartedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+			FinishedAt: "0001-01-01T00:00:00Z",
+		},
+		HostConfig: &container.HostConfig{},
+	})
+}
+
+// AddImage records an image with the registry digests it was pulled by.
+func (e *FakeEngine) AddImage(id string, repoDigests ...string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.images[id] = types.ImageInspect{
+		ID:          id,
+		RepoDigests: repoDigests,
+	}
+}
+
+// RemoveContainer drops a container from the engine.
+func (e *FakeEngine) RemoveContainer(id string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for i, c := range e.containers {
+		if c.ID == id {
+			e.containers = append(e.containers[:i], e.containers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Update changes the recorded state of a container.
+func (e *FakeEngine) Update(id string, update func(c *FakeContainer)) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	update(e.mustFind(id))
+}
+
+// Log records lines written by a container to stdout at the given time.
+func (e *FakeEngine) Log(id string, at time.Time, lines ...string) {
+	e.log(id, stdcopy.Stdout, at, lines)
+}
+
+// LogStderr records lines written by a container to stderr at the given time.
+func (e *FakeEngine) LogStderr(id string, at time.Time, lines ...string) {
+	e.log(id, stdcopy.Stderr, at, lines)
+}
+
+// Exit stops a container with the given exit code and emits a die event.
+func (e *FakeEngine) Exit(id string, exitCode int, at time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	c := e.mustFind(id)
+	c.State.Status = "exited"
+	c.State.Running = false
+	c.State.ExitCode = exitCode
+	c.State.FinishedAt = at.UTC().Format(time.RFC3339Nano)
+	c.events = append(c.events, events.Message{
+		Type:   events.ContainerEventType,
+		Action: "die",
+		Actor: events.Actor{
+			ID: id,
+			Attributes: map[string]string{
+				"name":     c.Name,
+				"exitCode": strconv.Itoa(exitCode),
+			},
+		},
+		Time:     at.Unix(),
+		TimeNano: at.UnixNano(),
+	})
+}
+
+// Restart starts an exited container again, as its restart policy would.
+func (e *FakeEngine) Restart(id string, at time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	c := e.mustFind(id)
+	c.State.Status = "running"
+	c.State.Running = true
+	c.State.StartedAt = at.UTC().Format(time.RFC3339Nano)
+	c.RestartCount++
+}
+
+// Fail makes every following call of the method return the error; a nil
+// error makes the method succeed again.
+func (e *FakeEngine) Fail(method string, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if err == nil {
+		delete(e.failures, method)
+		return
+	}
+	e.failures[method] = err
+}
+
+// Hang makes every following call of the method block until its context
+// ends, like an engine that stopped responding.
+func (e *FakeEngine) Hang(method string, hang bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.hangs[method] = hang
+}
+
+// Calls returns how often the method was called.
+func (e *FakeEngine) Calls(method string) int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.calls[method]
+}
+
+func (e *FakeEngine) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	if err := e.begin(ctx, MethodContainerList); err != nil {
+		return nil, err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	containers := make([]types.Container, 0, len(e.containers))
+	for _, c := range e.containers {
+		if !options.All && !c.State.Running {
+			continue
+		}
+		containers = append(containers, types.Container{
+			ID:     c.ID,
+			Names:  []string{"/" + c.Name},
+			Labels: copyLabels(c.Labels),
+			State:  c.State.Status,
+		})
+	}
+	return containers, nil
+}
+
+func (e *FakeEngine) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	if err := e.begin(ctx, MethodContainerInspect); err != nil {
+		return types.ContainerJSON{}, err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	c, err := e.find(containerID)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+	state := c.State
+	if state.Health != nil {
+		health := *state.Health
+		health.Log = append([]*types.HealthcheckResult(nil), health.Log...)
+		state.Health = &health
+	}
+	hostConfig := *c.HostConfig
+	networks := make(map[string]*network.EndpointSettings, len(c.Networks))
+	for _, name := range c.Networks {
+		networks[name] = &network.EndpointSettings{}
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:           c.ID,
+			Name:         "/" + c.Name,
+			Image:        c.ImageID,
+			State:        &state,
+			RestartCount: c.RestartCount,
+			HostConfig:   &hostConfig,
+		},
+		Mounts: append([]types.MountPoint(nil), c.Mounts...),
+		Config: &container.Config{
+			Tty:        c.Tty,
+			Image:      c.Image,
+			Cmd:        append([]string(nil), c.Cmd...),
+			Entrypoint: append([]string(nil), c.Entrypoint...),
+			Env:        append([]string(nil), c.Env...),
+			Labels:     copyLabels(c.Labels),
+		},
+		NetworkSettings: &types.NetworkSettings{
+			NetworkSettingsBase: types.NetworkSettingsBase{
+				Ports: c.Ports,
+			},
+			Networks: networks,
+		},
+	}, nil
+}
+
+// ContainerLogs returns the recorded lines within Since and Until, limited to
+// the last Tail lines. Without a TTY the output is multiplexed like the
+// engine does.
+func (e *FakeEngine) ContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	if err := e.begin(ctx, MethodContainerLogs); err != nil {
+		return nil, err
+	}
+	since, err := parseTimestamp(options.Since)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseTimestamp(options.Until)
+	if err != nil {
+		return nil, err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	c, err := e.find(containerID)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]fakeLogLine, 0, len(c.logs))
+	for _, line := range c.logs {
+		if !since.IsZero() && line.timestamp.Before(since) {
+			continue
+		}
+		if !until.IsZero() && line.timestamp.After(until) {
+			continue
+		}
+		if (line.stream == stdcopy.Stdout && !options.ShowStdout) || (line.stream == stdcopy.Stderr && !options.ShowStderr) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if options.Tail != "" && options.Tail != "all" {
+		tail, err := strconv.Atoi(options.Tail)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tail: %s", options.Tail)
+		}
+		if tail >= 0 && tail < len(lines) {
+			lines = lines[len(lines)-tail:]
+		}
+	}
+
+	var output bytes.Buffer
+	for _, line := range lines {
+		text := line.text + "\n"
+		if options.Timestamps {
+			text = line.timestamp.UTC().Format(time.RFC3339Nano) + " " + text
+		}
+		if c.Tty {
+			output.WriteString(text)
+			continue
+		}
+		stdcopy.NewStdWriter(&output, line.stream).Write([]byte(text))
+	}
+	return io.NopCloser(&output), nil
+}
+
+// Events replays the recorded events of the containers selected by the
+// filters within Since and Until, then ends the stream with io.EOF.
+func (e *FakeEngine) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	messages := make(chan events.Message)
+	errs := make(chan error, 1)
+	if err := e.begin(ctx, MethodEvents); err != nil {
+		errs <- err
+		return messages, errs
+	}
+	since, err := parseTimestamp(options.Since)
+	if err == nil {
+		var until time.Time
+		until, err = parseTimestamp(options.Until)
+		if err == nil {
+			replay := e.matchEvents(options, since, until)
+			go func() {
+				for _, message := range replay {
+					select {
+					case messages <- message:
+					case <-ctx.Done():
+						errs <- ctx.Err()
+						return
+					}
+				}
+				errs <- io.EOF
+			}()
+			return messages, errs
+		}
+	}
+	errs <- err
+	return messages, errs
+}
+
+func (e *FakeEngine) ContainerStatsOneShot(ctx context.Context, containerID string) (types.ContainerStats, error) {
+	if err := e.begin(ctx, MethodContainerStatsOneShot); err != nil {
+		return types.ContainerStats{}, err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	c, err := e.find(containerID)
+	if err != nil {
+		return types.ContainerStats{}, err
+	}
+	if c.Stats == nil || !c.State.Running {
+		return types.ContainerStats{}, fmt.Errorf("no stats recorded for container %s", containerID)
+	}
+	data, err := json.Marshal(c.Stats)
+	if err != nil {
+		return types.ContainerStats{}, err
+	}
+	return types.ContainerStats{
+		Body:   io.NopCloser(bytes.NewReader(data)),
+		OSType: "linux",
+	}, nil
+}
+
+func (e *FakeEngine) ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error) {
+	if err := e.begin(ctx, MethodImageInspectWithRaw); err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	inspect, exists := e.images[image]
+	if !exists {
+		return types.ImageInspect{}, nil, fmt.Errorf("No such image: %s", image)
+	}
+	data, err := json.Marshal(inspect)
+	return inspect, data, err
+}
+
+// begin counts the call and applies a scripted failure or hang.
+func (e *FakeEngine) begin(ctx context.Context, method string) error {
+	e.mutex.Lock()
+	e.calls[method]++
+	err, hang := e.failures[method], e.hangs[method]
+	e.mutex.Unlock()
+	if hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (e *FakeEngine) log(id string, stream stdcopy.StdType, at time.Time, lines []string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	c := e.mustFind(id)
+	for _, text := range lines {
+		c.logs = append(c.logs, fakeLogLine{timestamp: at, stream: stream, text: text})
+	}
+}
+
+func (e *FakeEngine) matchEvents(options types.EventsOptions, since time.Time, until time.Time) []events.Message {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	matched := make([]events.Message, 0)
+	for _, c := range e.containers {
+		if !options.Filters.ExactMatch("container", c.ID) {
+			continue
+		}
+		for _, message := range c.events {
+			at := time.Unix(0, message.TimeNano)
+			if !since.IsZero() && at.Before(since) {
+				continue
+			}
+			if !until.IsZero() && at.After(until) {
+				continue
+			}
+			if !options.Filters.ExactMatch("event", message.Action) {
+				continue
+			}
+			matched = append(matched, message)
+		}
+	}
+	return matched
+}
+
+func (e *FakeEngine) find(id string) (*FakeContainer, error) {
+	for _, c := range e.containers {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("No such container: %s", id)
+}
+
+func (e *FakeEngine) mustFind(id string) *FakeContainer {
+	c, err := e.find(id)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	seconds, nanoseconds, err := timetypes.ParseTimestamps(value, 0)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, nanoseconds), nil
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
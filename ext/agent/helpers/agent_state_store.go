@@ -0,0 +1,141 @@
+package helpers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"signal/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+const agentStateKeySize = 32
+
+// AgentStateStore keeps the agent state in a file encrypted with AES-GCM.
+// The key is derived from the configured secret or, when none is set, read
+// from a key file generated next to the state file on first use. Such a key
+// only obscures the state from anyone who can read the state file.
+type AgentStateStore struct {
+	mutex sync.Mutex
+	path  string
+	aead  cipher.AEAD
+}
+
+func NewAgentStateStore(path string, secret string) (*AgentStateStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create agent state directory: %v", err)
+	}
+	key, err := loadAgentStateKey(path+".key", secret)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentStateStore{
+		path: path,
+		aead: aead,
+	}, nil
+}
+
+// Load returns the persisted state, or the zero state if none was saved yet.
+func (s *AgentStateStore) Load() (models.PersistedAgentState, error) {
+	var state models.PersistedAgentState
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	nonceSize := s.aead.NonceSize()
+	if len(data) < nonceSize {
+		return state, errors.New("agent state file is truncated")
+	}
+	plaintext, err := s.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return state, fmt.Errorf("failed to decrypt agent state: %v", err)
+	}
+	err = json.Unmarshal(plaintext, &state)
+	return state, err
+}
+
+func (s *AgentStateStore) Save(state models.PersistedAgentState) error {
+	state.UpdatedAt = time.Now()
+	plaintext, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	data := s.aead.Seal(nonce, nonce, plaintext, nil)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := os.WriteFile(s.path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(s.path+".tmp", s.path)
+}
+
+// ReadAgentStateSecret returns the configured secret, or else the secret
+// mounted at path, e.g. a Docker secret. It returns an empty string when
+// neither is set.
+func ReadAgentStateSecret(secret string, path string) (string, error) {
+	if secret != "" || path == "" {
+		return secret, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	secret = strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("agent state secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+func loadAgentStateKey(keyPath string, secret string) ([]byte, error) {
+	if secret != "" {
+		key := sha256.Sum256([]byte(secret))
+		return key[:], nil
+	}
+	key, err := os.ReadFile(keyPath)
+	if err == nil {
+		if len(key) != agentStateKeySize {
+			return nil, fmt.Errorf("agent state key %s has an invalid size", keyPath)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	key = make([]byte, agentStateKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyPath, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write agent state key: %v", err)
+	}
+	return key, nil
+}
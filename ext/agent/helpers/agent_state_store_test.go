@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"signal/models"
+	"testing"
+)
+
+func TestReadAgentStateSecret(t *testing.T) {
+	dir := t.TempDir()
+	mounted := filepath.Join(dir, "agent_state_secret")
+	if err := os.WriteFile(mounted, []byte("mounted-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if secret, err := ReadAgentStateSecret("configured", mounted); err != nil || secret != "configured" {
+		t.Errorf("expected the configured secret to win, got %q: %v", secret, err)
+	}
+	if secret, err := ReadAgentStateSecret("", mounted); err != nil || secret != "mounted-secret" {
+		t.Errorf("expected the mounted secret, got %q: %v", secret, err)
+	}
+	if secret, err := ReadAgentStateSecret("", filepath.Join(dir, "missing")); err != nil || secret != "" {
+		t.Errorf("expected no secret, got %q: %v", secret, err)
+	}
+	if _, err := ReadAgentStateSecret("", empty); err == nil {
+		t.Error("expected an empty secret file to be rejected")
+	}
+}
+
+func TestAgentStateStoreWithSecretWritesNoKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent_state")
+	store, err := NewAgentStateStore(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(models.PersistedAgentState{UserId: "user-a", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".key"); !os.IsNotExist(err) {
+		t.Errorf("expected no key file next to the state, got %v", err)
+	}
+
+	reopened, err := NewAgentStateStore(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := reopened.Load()
+	if err != nil || state.UserId != "user-a" || !state.Enabled {
+		t.Errorf("expected the saved state, got %+v: %v", state, err)
+	}
+	other, err := NewAgentStateStore(path, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Load(); err == nil {
+		t.Error("expected the state not to decrypt with another secret")
+	}
+}
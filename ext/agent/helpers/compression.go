@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = "identity"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// CompressPayload encodes a request body with the given Content-Encoding.
+func CompressPayload(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case CompressionNone, "":
+		return data, nil
+	case CompressionGzip:
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	case CompressionZstd:
+		encoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Close()
+		return encoder.EncodeAll(data, make([]byte, 0, len(data)/4)), nil
+	}
+	return nil, fmt.Errorf("unsupported compression: %s", encoding)
+}
@@ -0,0 +1,127 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"signal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ScanModeOptIn  = "opt-in"
+	ScanModeOptOut = "opt-out"
+)
+
+const (
+	LabelEnable         = "signalone.enable"
+	LabelIgnorePatterns = "signalone.ignore-patterns"
+	LabelSeverityFloor  = "signalone.severity-floor"
+	LabelScanInterval   = "signalone.scan-interval"
+)
+
+const (
+	LabelComposeProject         = "com.docker.compose.project"
+	LabelComposeService         = "com.docker.compose.service"
+	LabelComposeContainerNumber = "com.docker.compose.container-number"
+)
+
+// ValidateScanMode reports an error for a SCAN_MODE other than opt-in or
+// opt-out, as an unknown mode would silently scan every container.
+func ValidateScanMode(scanMode string) error {
+	if scanMode != ScanModeOptIn && scanMode != ScanModeOptOut {
+		return fmt.Errorf("invalid scan mode %q, expected %q or %q", scanMode, ScanModeOptIn, ScanModeOptOut)
+	}
+	return nil
+}
+
+// GetContainerScanSettings resolves the scan settings of a container from its
+// labels. In opt-in mode only containers labelled signalone.enable=true are
+// scanned, in opt-out mode every container is scanned unless it is labelled
+// signalone.enable=false. Invalid label values are skipped and reported in the
+// returned error while the remaining settings stay usable.
+func GetContainerScanSettings(labels map[string]string, scanMode string) (models.ContainerScanSettings, error) {
+	invalidLabels := make([]string, 0)
+	settings := models.ContainerScanSettings{
+		Enabled:        scanMode != ScanModeOptIn,
+		IgnorePatterns: make([]*regexp.Regexp, 0),
+		SeverityFloor:  LogLevelWarning,
+	}
+
+	if value, exists := labels[LabelEnable]; exists {
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			invalidLabels = append(invalidLabels, LabelEnable)
+		} else {
+			settings.Enabled = enabled
+		}
+	}
+
+	if value, exists := labels[LabelIgnorePatterns]; exists {
+		for _, pattern := range strings.Split(value, ";") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				invalidLabels = append(invalidLabels, LabelIgnorePatterns)
+				continue
+			}
+			settings.IgnorePatterns = append(settings.IgnorePatterns, compiled)
+		}
+	}
+
+	if value, exists := labels[LabelSeverityFloor]; exists {
+		level := NormalizeLogLevel(value)
+		if !IsLogLevelErrorOrWarning(level) {
+			invalidLabels = append(invalidLabels, LabelSeverityFloor)
+		} else {
+			settings.SeverityFloor = level
+		}
+	}
+
+	if value, exists := labels[LabelScanInterval]; exists {
+		interval, err := parseScanInterval(value)
+		if err != nil {
+			invalidLabels = append(invalidLabels, LabelScanInterval)
+		} else {
+			settings.ScanInterval = interval
+		}
+	}
+
+	if len(invalidLabels) > 0 {
+		return settings, fmt.Errorf("invalid values for labels: %s", strings.Join(invalidLabels, ", "))
+	}
+	return settings, nil
+}
+
+// SetContainerLabels copies the Compose project, service and replica number of
+// a container into a detection, so the backend can group the issues of all
+// replicas and recreated containers of a service. Containers not started by
+// Compose leave the fields empty. The redacted labels are sent as well, so
+// the backend can route the issue by label even without a configuration
+// snapshot.
+func SetContainerLabels(payload *models.LogAnalysisPayload, labels map[string]string) {
+	payload.Labels = RedactLabels(labels)
+	payload.ComposeProject = labels[LabelComposeProject]
+	payload.ComposeService = labels[LabelComposeService]
+	payload.ComposeContainerNumber, _ = strconv.Atoi(labels[LabelComposeContainerNumber])
+}
+
+// parseScanInterval accepts Go durations ("2m") as well as plain seconds ("120").
+func parseScanInterval(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		value = fmt.Sprintf("%ds", seconds)
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("scan interval must be positive")
+	}
+	return interval, nil
+}
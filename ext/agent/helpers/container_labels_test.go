@@ -0,0 +1,16 @@
+package helpers
+
+import "testing"
+
+func TestValidateScanMode(t *testing.T) {
+	for _, scanMode := range []string{ScanModeOptIn, ScanModeOptOut} {
+		if err := ValidateScanMode(scanMode); err != nil {
+			t.Errorf("expected %q to be valid, got %v", scanMode, err)
+		}
+	}
+	for _, scanMode := range []string{"", "optin", "Opt-In", "all"} {
+		if err := ValidateScanMode(scanMode); err == nil {
+			t.Errorf("expected %q to be rejected", scanMode)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"signal/models"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+const redactedValue = "[REDACTED]"
+
+var (
+	secretNamePattern     = regexp.MustCompile(`(?i)(pass|secret|token|key|credential|auth)`)
+	secretFlagPattern     = regexp.MustCompile(`(?i)^--?[\w.-]*(pass|secret|token|key|credential|auth)[\w.-]*$`)
+	inlineSecretPattern   = regexp.MustCompile(`(?i)(--?[\w.-]*(?:pass|secret|token|key|credential|auth)[\w.-]*[= ])[^\s"']+`)
+	urlCredentialsPattern = regexp.MustCompile(`(\w+://[^:/@\s]+:)[^@/\s]+@`)
+)
+
+// BuildContainerSnapshot captures the configuration of an inspected container
+// for a detection. Only the names of environment variables are kept, and
+// command arguments, label values and URLs that look like they carry
+// credentials are masked.
+func BuildContainerSnapshot(container types.ContainerJSON, imageDigest string) models.ContainerSnapshot {
+	snapshot := models.ContainerSnapshot{
+		ImageDigest: imageDigest,
+		Command:     make([]string, 0),
+		Entrypoint:  make([]string, 0),
+		Env:         make([]string, 0),
+		Mounts:      make([]models.ContainerMount, 0, len(container.Mounts)),
+		Ports:       make([]string, 0),
+		Networks:    make([]string, 0),
+		Labels:      make(map[string]string),
+	}
+	if container.ContainerJSONBase != nil {
+		snapshot.ImageId = container.Image
+	}
+	if container.Config != nil {
+		snapshot.Image = container.Config.Image
+		snapshot.Command = redactArgs(container.Config.Cmd)
+		snapshot.Entrypoint = redactArgs(container.Config.Entrypoint)
+		for _, env := range container.Config.Env {
+			name, _, _ := strings.Cut(env, "=")
+			snapshot.Env = append(snapshot.Env, name+"="+redactedValue)
+		}
+		snapshot.Labels = RedactLabels(container.Config.Labels)
+	}
+	for _, mount := range container.Mounts {
+		source := mount.Source
+		if mount.Name != "" {
+			source = mount.Name
+		}
+		snapshot.Mounts = append(snapshot.Mounts, models.ContainerMount{
+			Type:        string(mount.Type),
+			Source:      source,
+			Destination: mount.Destination,
+			ReadWrite:   mount.RW,
+		})
+	}
+	if container.HostConfig != nil {
+		snapshot.RestartPolicy = container.HostConfig.RestartPolicy.Name
+		snapshot.MaximumRetryCount = container.HostConfig.RestartPolicy.MaximumRetryCount
+		snapshot.Resources = models.ContainerResources{
+			MemoryBytes:            container.HostConfig.Memory,
+			MemoryReservationBytes: container.HostConfig.MemoryReservation,
+			NanoCpus:               container.HostConfig.NanoCPUs,
+			CpuShares:              container.HostConfig.CPUShares,
+		}
+		if container.HostConfig.PidsLimit != nil {
+			snapshot.Resources.PidsLimit = *container.HostConfig.PidsLimit
+		}
+	}
+	if container.NetworkSettings != nil {
+		for port, bindings := range container.NetworkSettings.Ports {
+			if len(bindings) == 0 {
+				snapshot.Ports = append(snapshot.Ports, string(port))
+			}
+			for _, binding := range bindings {
+				snapshot.Ports = append(snapshot.Ports, fmt.Sprintf("%s:%s->%s", binding.HostIP, binding.HostPort, port))
+			}
+		}
+		for name := range container.NetworkSettings.Networks {
+			snapshot.Networks = append(snapshot.Networks, name)
+		}
+	}
+	if len(snapshot.Networks) == 0 && container.HostConfig != nil && container.HostConfig.NetworkMode != "" {
+		snapshot.Networks = append(snapshot.Networks, string(container.HostConfig.NetworkMode))
+	}
+	sort.Strings(snapshot.Ports)
+	sort.Strings(snapshot.Networks)
+	return snapshot
+}
+
+// GetImageDigest returns the registry digest of an image, which is empty for
+// images that were built locally and never pushed.
+func GetImageDigest(ctx context.Context, cli DockerClient, image string) (string, error) {
+	inspect, _, err := cli.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return "", err
+	}
+	if len(inspect.RepoDigests) == 0 {
+		return "", nil
+	}
+	return inspect.RepoDigests[0], nil
+}
+
+// redactArgs masks the values of secret flags, given either as
+// --password=value, as --password value or inline in a shell command, and
+// passwords in URLs.
+func redactArgs(args []string) []string {
+	redacted := make([]string, 0, len(args))
+	maskNext := false
+	for _, arg := range args {
+		switch {
+		case maskNext:
+			arg = redactedValue
+			maskNext = false
+		case secretFlagPattern.MatchString(arg):
+			maskNext = true
+		default:
+			arg = inlineSecretPattern.ReplaceAllString(arg, "${1}"+redactedValue)
+			arg = urlCredentialsPattern.ReplaceAllString(arg, "${1}"+redactedValue+"@")
+		}
+		redacted = append(redacted, arg)
+	}
+	return redacted
+}
+
+// RedactLabels copies container labels, masking the values of labels named
+// like secrets and credentials in URLs.
+func RedactLabels(labels map[string]string) map[string]string {
+	redacted := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if secretNamePattern.MatchString(key) {
+			value = redactedValue
+		}
+		redacted[key] = urlCredentialsPattern.ReplaceAllString(value, "${1}"+redactedValue+"@")
+	}
+	return redacted
+}
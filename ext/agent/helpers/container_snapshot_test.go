@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"context"
+	"signal/agenttest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestBuildContainerSnapshotRedactsSecrets(t *testing.T) {
+	engine := agenttest.NewFakeEngine()
+	engine.AddContainer("c1", "api", map[string]string{
+		LabelComposeService:                             "api",
+		"traefik.http.middlewares.auth.basicauth.users": "admin:$apr1$hash",
+		"backup.target":                                 "s3://backup:hunter2@example.com/bucket",
+	})
+	engine.AddImage("sha256:c1", "fake/api@sha256:0123")
+	engine.Update("c1", func(c *agenttest.FakeContainer) {
+		c.Entrypoint = []string{"/bin/sh", "-c", "server --db-password=hunter2 --port 8080"}
+		c.Cmd = []string{"--api-token", "hunter2", "--database", "postgres://app:hunter2@db:5432/app", "--verbose"}
+		c.Env = []string{"DATABASE_PASSWORD=hunter2", "LOG_LEVEL=debug"}
+		c.Mounts = []types.MountPoint{{Type: "volume", Name: "data", Destination: "/var/lib/data", RW: true}}
+		c.Ports = nat.PortMap{"8080/tcp": {{HostIP: "0.0.0.0", HostPort: "80"}}}
+		c.Networks = []string{"shop_default"}
+		c.HostConfig = &container.HostConfig{
+			RestartPolicy: container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+			Resources:     container.Resources{Memory: 256 << 20},
+		}
+	})
+	inspect, err := engine.ContainerInspect(context.Background(), "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := GetImageDigest(context.Background(), engine, inspect.Image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := BuildContainerSnapshot(inspect, digest)
+
+	rendered := strings.Join(append(append(append([]string(nil), snapshot.Entrypoint...), snapshot.Command...), snapshot.Env...), " ")
+	for _, value := range snapshot.Labels {
+		rendered += " " + value
+	}
+	if strings.Contains(rendered, "hunter2") || strings.Contains(rendered, "apr1") {
+		t.Errorf("expected secrets to be masked, got %q", rendered)
+	}
+	expectedCommand := []string{"--api-token", redactedValue, "--database", "postgres://app:" + redactedValue + "@db:5432/app", "--verbose"}
+	if strings.Join(snapshot.Command, " ") != strings.Join(expectedCommand, " ") {
+		t.Errorf("unexpected command %q", snapshot.Command)
+	}
+	if snapshot.Entrypoint[2] != "server --db-password="+redactedValue+" --port 8080" {
+		t.Errorf("unexpected entrypoint %q", snapshot.Entrypoint)
+	}
+	if snapshot.Env[1] != "LOG_LEVEL="+redactedValue || snapshot.Labels[LabelComposeService] != "api" {
+		t.Errorf("expected env values masked and labels kept, got %q and %v", snapshot.Env, snapshot.Labels)
+	}
+	if snapshot.ImageDigest != "fake/api@sha256:0123" || snapshot.Image != "fake/api:latest" {
+		t.Errorf("unexpected image %q with digest %q", snapshot.Image, snapshot.ImageDigest)
+	}
+	if len(snapshot.Mounts) != 1 || snapshot.Mounts[0].Source != "data" || len(snapshot.Ports) != 1 || snapshot.Ports[0] != "0.0.0.0:80->8080/tcp" {
+		t.Errorf("unexpected mounts %+v or ports %q", snapshot.Mounts, snapshot.Ports)
+	}
+	if snapshot.RestartPolicy != "on-failure" || snapshot.Resources.MemoryBytes != 256<<20 || snapshot.Networks[0] != "shop_default" {
+		t.Errorf("unexpected host config in %+v", snapshot)
+	}
+}
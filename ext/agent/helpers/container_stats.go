@@ -0,0 +1,35 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/docker/docker/api/types"
+)
+
+// CollectContainerStats takes a single resource usage sample of a running
+// container.
+func CollectContainerStats(ctx context.Context, containerID string, cli DockerClient) (types.StatsJSON, error) {
+	var stats types.StatsJSON
+	response, err := cli.ContainerStatsOneShot(ctx, containerID)
+	if err != nil {
+		return stats, err
+	}
+	defer response.Body.Close()
+	err = json.NewDecoder(response.Body).Decode(&stats)
+	return stats, err
+}
+
+// GetMemoryUsage returns the memory used by a container without the page
+// cache, matching the value reported by `docker stats`.
+func GetMemoryUsage(stats types.StatsJSON) uint64 {
+	usage := stats.MemoryStats.Usage
+	cache, exists := stats.MemoryStats.Stats["inactive_file"]
+	if !exists {
+		cache = stats.MemoryStats.Stats["total_inactive_file"]
+	}
+	if cache < usage {
+		usage -= cache
+	}
+	return usage
+}
@@ -0,0 +1,149 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	ControlApiSecretHeader = "X-Signalone-Agent-Secret"
+	controlApiSecretSize   = 32
+	maxAuthTokenLength     = 8192
+	maxUserIdLength        = 128
+)
+
+var (
+	userIdPattern    = regexp.MustCompile(`^[A-Za-z0-9_.@|-]+$`)
+	authTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`)
+)
+
+// ListenControlApi opens the listener of the control API. A Unix socket is
+// used when a socket path is configured, so only processes with access to
+// the socket file can reach the API; the TCP address is used otherwise.
+func ListenControlApi(socketPath string, address string) (net.Listener, error) {
+	if socketPath == "" {
+		return net.Listen("tcp", address)
+	}
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketPath, 0660); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// LoadOrCreateControlApiSecret returns the per-install shared secret of the
+// control API, generating it into the given file on first use.
+func LoadOrCreateControlApiSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return "", fmt.Errorf("control API secret file %s is empty", path)
+		}
+		return secret, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	random := make([]byte, controlApiSecretSize)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(random)
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// RequireAllowedOrigin rejects requests sent by web pages of any origin that
+// is not allowed. CORS alone only hides the response from such pages, while
+// the request itself would still switch monitoring or credentials. Requests
+// without an Origin are let through: browsers send it with every request that
+// could change state, so such requests come from local tools like the
+// frontend proxy or curl, and these still need the shared secret, or access
+// to the Unix socket when no secret is used.
+func RequireAllowedOrigin(allowedOrigins []string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			origin := c.Request().Header.Get(echo.HeaderOrigin)
+			if origin == "" {
+				return next(c)
+			}
+			for _, allowedOrigin := range allowedOrigins {
+				if origin == allowedOrigin {
+					return next(c)
+				}
+			}
+			return c.JSON(http.StatusForbidden, "Origin not allowed")
+		}
+	}
+}
+
+// RequireSharedSecret rejects requests that do not carry the shared secret
+// of the control API.
+func RequireSharedSecret(secret string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			provided := c.Request().Header.Get(ControlApiSecretHeader)
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+				return c.JSON(http.StatusUnauthorized, "Unauthorized")
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireJSONBody rejects requests with a body that is not JSON, which also
+// forces browsers to send a CORS preflight for every state change.
+func RequireJSONBody() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			method := c.Request().Method
+			if method != echo.POST && method != echo.PUT {
+				return next(c)
+			}
+			if !strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+				return c.JSON(http.StatusUnsupportedMediaType, "Content type must be application/json")
+			}
+			return next(c)
+		}
+	}
+}
+
+// ValidateAuthData checks the credentials sent by the extension UI before
+// they are used for calls to the backend.
+func ValidateAuthData(userId string, token string) error {
+	if userId == "" || len(userId) > maxUserIdLength || !userIdPattern.MatchString(userId) {
+		return errors.New("invalid user_id")
+	}
+	if len(token) > maxAuthTokenLength || !authTokenPattern.MatchString(token) {
+		return errors.New("invalid token")
+	}
+	return nil
+}
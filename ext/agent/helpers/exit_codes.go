@@ -0,0 +1,56 @@
+package helpers
+
+import "fmt"
+
+var signalNames = map[int]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	10: "SIGUSR1",
+	11: "SIGSEGV",
+	12: "SIGUSR2",
+	13: "SIGPIPE",
+	14: "SIGALRM",
+	15: "SIGTERM",
+}
+
+var exitCodeMeanings = map[int]string{
+	0:   "Exited successfully",
+	1:   "Application error",
+	2:   "Misuse of shell builtin or invalid arguments",
+	125: "Container failed to run, error in the Docker daemon",
+	126: "Command cannot be invoked, e.g. permission problem or not executable",
+	127: "Command not found, e.g. invalid entrypoint or missing binary",
+	130: "Terminated by Ctrl+C (SIGINT)",
+	134: "Aborted (SIGABRT), e.g. failed assertion or abort() call",
+	137: "Killed (SIGKILL), e.g. out of memory or docker kill/stop timeout",
+	139: "Segmentation fault (SIGSEGV), invalid memory access",
+	143: "Terminated gracefully (SIGTERM), e.g. docker stop",
+	255: "Exit status out of range",
+}
+
+// DescribeExitCode interprets a container exit code. Codes above 128 mean the
+// process was terminated by signal (code - 128), whose name is returned too.
+func DescribeExitCode(exitCode int) (meaning string, signal string) {
+	if exitCode > 128 && exitCode < 128+65 {
+		signal = signalNames[exitCode-128]
+		if signal == "" {
+			signal = fmt.Sprintf("signal %d", exitCode-128)
+		}
+	}
+	meaning, exists := exitCodeMeanings[exitCode]
+	if !exists {
+		if signal != "" {
+			meaning = fmt.Sprintf("Terminated by %s", signal)
+		} else {
+			meaning = "Application specific exit code"
+		}
+	}
+	return meaning, signal
+}
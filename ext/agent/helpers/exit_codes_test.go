@@ -0,0 +1,29 @@
+package helpers
+
+import "testing"
+
+func TestDescribeExitCode(t *testing.T) {
+	tests := []struct {
+		exitCode int
+		meaning  string
+		signal   string
+	}{
+		{0, "Exited successfully", ""},
+		{1, "Application error", ""},
+		{127, "Command not found, e.g. invalid entrypoint or missing binary", ""},
+		{137, "Killed (SIGKILL), e.g. out of memory or docker kill/stop timeout", "SIGKILL"},
+		{139, "Segmentation fault (SIGSEGV), invalid memory access", "SIGSEGV"},
+		{143, "Terminated gracefully (SIGTERM), e.g. docker stop", "SIGTERM"},
+		{129, "Terminated by SIGHUP", "SIGHUP"},
+		{159, "Terminated by signal 31", "signal 31"},
+		{3, "Application specific exit code", ""},
+		{128, "Application specific exit code", ""},
+		{255, "Exit status out of range", ""},
+	}
+	for _, test := range tests {
+		meaning, signal := DescribeExitCode(test.exitCode)
+		if meaning != test.meaning || signal != test.signal {
+			t.Errorf("expected exit code %d to be %q by %q, got %q by %q", test.exitCode, test.meaning, test.signal, meaning, signal)
+		}
+	}
+}
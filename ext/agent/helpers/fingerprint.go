@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"signal/models"
+	"strings"
+)
+
+// The fingerprint and severity of a detection are derived the same way the
+// backend derives them for issues, so mute rules match on both sides. Both
+// implementations are checked against testdata/fingerprint_vectors.json at
+// the repository root.
+
+var (
+	fingerprintUuidPattern   = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	fingerprintHexPattern    = regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]{8,}\b`)
+	fingerprintNumberPattern = regexp.MustCompile(`[\w.:/-]*\d[\w.:/-]*`)
+)
+
+// IssueFingerprint identifies the problem a detection reports across
+// detections of the container, or of its Compose replica.
+func IssueFingerprint(userId string, payload models.LogAnalysisPayload) string {
+	scope := payload.ContainerName
+	if payload.ComposeService != "" {
+		scope = fmt.Sprintf("%s/%s/%d", payload.ComposeProject, payload.ComposeService, payload.ComposeContainerNumber)
+	}
+	var signature string
+	switch {
+	case payload.CrashLoop != nil:
+		signature = "crash-loop"
+	case payload.Anomaly != nil:
+		signature = "anomaly:" + payload.Anomaly.Kind
+	case payload.Health != nil:
+		signature = "unhealthy"
+	case payload.Termination != nil:
+		signature = fmt.Sprintf("exit:%d:%t", payload.Termination.ExitCode, payload.Termination.OOMKilled)
+	default:
+		signature = "log:" + normalizeLogMessage(mostSevereMessage(payload.ParsedLogs))
+	}
+	hash := sha256.Sum256([]byte(strings.Join([]string{userId, scope, detectionIssueType(payload), signature}, "\n")))
+	return hex.EncodeToString(hash[:16])
+}
+
+// IssueSeverity returns the severity the backend gives the issue of a
+// detection.
+func IssueSeverity(payload models.LogAnalysisPayload) string {
+	termination := payload.Termination
+	if termination != nil && (termination.OOMKilled || termination.ExitCode != 0) {
+		return "CRITICAL"
+	}
+	if detectionIssueType(payload) == models.IssueTypeAnomaly {
+		return "WARNING"
+	}
+	hasWarning := false
+	for _, parsed := range payload.ParsedLogs {
+		switch parsed.Level {
+		case LogLevelError, LogLevelCritical:
+			return "CRITICAL"
+		case LogLevelWarning:
+			hasWarning = true
+		}
+	}
+	if hasWarning {
+		return "WARNING"
+	}
+	return "CRITICAL"
+}
+
+func detectionIssueType(payload models.LogAnalysisPayload) string {
+	if payload.IssueType == "" {
+		return models.IssueTypeError
+	}
+	return strings.ToUpper(payload.IssueType)
+}
+
+// normalizeLogMessage masks uuids, hex ids and any word with a digit in it,
+// like timestamps and request ids, so recurrences share a fingerprint.
+func normalizeLogMessage(message string) string {
+	message = fingerprintUuidPattern.ReplaceAllString(message, "<uuid>")
+	message = fingerprintHexPattern.ReplaceAllString(message, "<hex>")
+	message = fingerprintNumberPattern.ReplaceAllString(message, "<n>")
+	return strings.ToLower(strings.Join(strings.Fields(message), " "))
+}
+
+// mostSevereMessage returns the message of the line reporting the most
+// severe problem, judging lines without a level by their keywords, so the
+// lines logged around a problem do not change its fingerprint.
+func mostSevereMessage(parsedLogs []models.ParsedLogLine) string {
+	levels := make([]string, len(parsedLogs))
+	for i, parsed := range parsedLogs {
+		levels[i] = LogLineProblemLevel(parsed)
+	}
+	for _, level := range []string{LogLevelCritical, LogLevelError, LogLevelWarning} {
+		for i, parsed := range parsedLogs {
+			if levels[i] == level {
+				return parsed.Message
+			}
+		}
+	}
+	if len(parsedLogs) == 0 {
+		return ""
+	}
+	return parsedLogs[0].Message
+}
@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"encoding/json"
+	"os"
+	"signal/models"
+	"testing"
+)
+
+// fingerprintVectorsFile is shared with the backend tests, so the agent and
+// the backend fail together when their fingerprints drift apart.
+const fingerprintVectorsFile = "../../../testdata/fingerprint_vectors.json"
+
+type fingerprintVector struct {
+	Name        string                    `json:"name"`
+	UserId      string                    `json:"userId"`
+	Payload     models.LogAnalysisPayload `json:"payload"`
+	Fingerprint string                    `json:"fingerprint"`
+	Severity    string                    `json:"severity"`
+}
+
+func TestIssueFingerprintMatchesSharedVectors(t *testing.T) {
+	data, err := os.ReadFile(fingerprintVectorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []fingerprintVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	for _, vector := range vectors {
+		t.Run(vector.Name, func(t *testing.T) {
+			if got := IssueFingerprint(vector.UserId, vector.Payload); got != vector.Fingerprint {
+				t.Errorf("expected fingerprint %s, got %s", vector.Fingerprint, got)
+			}
+			if got := IssueSeverity(vector.Payload); got != vector.Severity {
+				t.Errorf("expected severity %s, got %s", vector.Severity, got)
+			}
+		})
+	}
+}
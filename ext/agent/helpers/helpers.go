@@ -5,23 +5,83 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"reflect"
 	"signal/models"
+	"strconv"
+	"time"
 
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/spf13/viper"
 )
 
 type ConfigServer struct {
 	BackendApiKey     string `mapstructure:"BACKEND_API_KEY"`
 	BackendApiAddress string `mapstructure:"BACKEND_API_ADDRESS"`
+	ScanMode          string `mapstructure:"SCAN_MODE"`
+
+	ScanInterval         time.Duration `mapstructure:"SCAN_INTERVAL"`
+	ScanTimeout          time.Duration `mapstructure:"SCAN_TIMEOUT"`
+	ContainerScanTimeout time.Duration `mapstructure:"CONTAINER_SCAN_TIMEOUT"`
+	ScanWorkers          int           `mapstructure:"SCAN_WORKERS"`
+
+	CrashLoopRestartThreshold int           `mapstructure:"CRASH_LOOP_RESTART_THRESHOLD"`
+	CrashLoopWindow           time.Duration `mapstructure:"CRASH_LOOP_WINDOW"`
+
+	RecoveryStabilityWindow time.Duration `mapstructure:"RECOVERY_STABILITY_WINDOW"`
+
+	LogContextLinesBefore int           `mapstructure:"LOG_CONTEXT_LINES_BEFORE"`
+	LogContextTimeBefore  time.Duration `mapstructure:"LOG_CONTEXT_TIME_BEFORE"`
+	LogContextLinesAfter  int           `mapstructure:"LOG_CONTEXT_LINES_AFTER"`
+
+	OutboundQueueDir     string        `mapstructure:"OUTBOUND_QUEUE_DIR"`
+	OutboundQueueMaxSize int           `mapstructure:"OUTBOUND_QUEUE_MAX_SIZE"`
+	OutboundQueueMaxAge  time.Duration `mapstructure:"OUTBOUND_QUEUE_MAX_AGE"`
+
+	UploadBatchSize     int           `mapstructure:"UPLOAD_BATCH_SIZE"`
+	UploadFlushInterval time.Duration `mapstructure:"UPLOAD_FLUSH_INTERVAL"`
+	UploadCompression   string        `mapstructure:"UPLOAD_COMPRESSION"`
+
+	AgentStateFile       string `mapstructure:"AGENT_STATE_FILE"`
+	AgentStateSecret     string `mapstructure:"AGENT_STATE_SECRET"`
+	AgentStateSecretFile string `mapstructure:"AGENT_STATE_SECRET_FILE"`
+
+	ControlApiAddress        string   `mapstructure:"CONTROL_API_ADDRESS"`
+	ControlApiSocket         string   `mapstructure:"CONTROL_API_SOCKET"`
+	ControlApiSecret         string   `mapstructure:"CONTROL_API_SECRET"`
+	ControlApiSecretFile     string   `mapstructure:"CONTROL_API_SECRET_FILE"`
+	ControlApiAllowedOrigins []string `mapstructure:"CONTROL_API_ALLOWED_ORIGINS"`
+}
+
+var secretConfigKeys = map[string]bool{
+	"BACKEND_API_KEY":    true,
+	"AGENT_STATE_SECRET": true,
+	"CONTROL_API_SECRET": true,
+}
+
+// RedactedConfig returns the configuration keyed by variable name with
+// secrets masked, for the diagnostics bundle.
+func RedactedConfig(cfs ConfigServer) map[string]string {
+	config := make(map[string]string)
+	value := reflect.ValueOf(cfs)
+	for i := 0; i < value.NumField(); i++ {
+		key := value.Type().Field(i).Tag.Get("mapstructure")
+		config[key] = fmt.Sprint(value.Field(i).Interface())
+		if secretConfigKeys[key] && config[key] != "" {
+			config[key] = redactedValue
+		}
+	}
+	return config
 }
 
-func ListContainers(cli *client.Client) ([]types.Container, error) {
+func ListContainers(ctx context.Context, cli DockerClient) ([]types.Container, error) {
 	filteredContainers := make([]types.Container, 0)
-	containers, err := cli.ContainerList(context.Background(),
+	containers, err := cli.ContainerList(ctx,
 		types.ContainerListOptions{
 			All: true,
 		},
@@ -39,29 +99,108 @@ func ListContainers(cli *client.Client) ([]types.Container, error) {
 	return filteredContainers, nil
 }
 
-func CollectLogsForAnalysis(containerID string, cli *client.Client, logTimeTail string) (string, error) {
-	logs, err := cli.ContainerLogs(context.Background(),
-		containerID,
-		types.ContainerLogsOptions{
-			Since:      logTimeTail,
-			ShowStdout: true,
-			ShowStderr: true,
-		})
+// CollectLogsForAnalysis returns the lines logged by a container since the
+// given time, each prefixed with its timestamp.
+func CollectLogsForAnalysis(ctx context.Context, containerID string, cli DockerClient, logTimeTail string, tty bool) (string, error) {
+	return collectLogs(ctx, containerID, cli, types.ContainerLogsOptions{
+		Since:      logTimeTail,
+		Timestamps: true,
+		ShowStdout: true,
+		ShowStderr: true,
+	}, tty)
+}
+
+// CollectRunLogs returns the last lines logged by a container before the
+// given time, e.g. the moment one of its runs exited.
+func CollectRunLogs(ctx context.Context, containerID string, cli DockerClient, until time.Time, tail int, tty bool) (string, error) {
+	return collectLogs(ctx, containerID, cli, types.ContainerLogsOptions{
+		Until:      fmt.Sprintf("%d.%09d", until.Unix(), until.Nanosecond()),
+		Tail:       strconv.Itoa(tail),
+		ShowStdout: true,
+		ShowStderr: true,
+	}, tty)
+}
+
+func collectLogs(ctx context.Context, containerID string, cli DockerClient, options types.ContainerLogsOptions, tty bool) (string, error) {
+	logs, err := cli.ContainerLogs(ctx, containerID, options)
 	if err != nil {
 		return "", err
 	}
 	defer logs.Close()
-	logBytes, err := ioutil.ReadAll(logs)
+	if tty {
+		logBytes, err := ioutil.ReadAll(logs)
+		if err != nil {
+			return "", err
+		}
+		return string(logBytes), nil
+	}
+	// Without a TTY the stdout and stderr streams are multiplexed with frame
+	// headers that would otherwise end up in the middle of log lines.
+	var logBuffer bytes.Buffer
+	_, err = stdcopy.StdCopy(&logBuffer, &logBuffer, logs)
 	if err != nil {
 		return "", err
 	}
-	return string(logBytes), nil
+	return logBuffer.String(), nil
+}
+
+// CollectDieEvents returns the "die" events emitted for a container within
+// the given time range.
+func CollectDieEvents(ctx context.Context, containerID string, cli DockerClient, since time.Time, until time.Time) ([]events.Message, error) {
+	dieEvents := make([]events.Message, 0)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	messages, errs := cli.Events(ctx, types.EventsOptions{
+		Since: fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+		Until: fmt.Sprintf("%d.%09d", until.Unix(), until.Nanosecond()),
+		Filters: filters.NewArgs(
+			filters.Arg("type", "container"),
+			filters.Arg("container", containerID),
+			filters.Arg("event", "die"),
+		),
+	})
+	for {
+		select {
+		case message := <-messages:
+			dieEvents = append(dieEvents, message)
+		case err := <-errs:
+			if err == io.EOF {
+				return dieEvents, nil
+			}
+			return nil, err
+		}
+	}
 }
 
 func GetEnvVariables() (cfs ConfigServer) {
 	viper.SetConfigName(".default")
 	viper.AddConfigPath(".")
 	viper.SetConfigType("env")
+	viper.SetDefault("SCAN_MODE", ScanModeOptOut)
+	viper.SetDefault("SCAN_INTERVAL", "15s")
+	viper.SetDefault("SCAN_TIMEOUT", "2m")
+	viper.SetDefault("CONTAINER_SCAN_TIMEOUT", "30s")
+	viper.SetDefault("SCAN_WORKERS", 4)
+	viper.SetDefault("CRASH_LOOP_RESTART_THRESHOLD", 3)
+	viper.SetDefault("CRASH_LOOP_WINDOW", "5m")
+	viper.SetDefault("RECOVERY_STABILITY_WINDOW", "10m")
+	viper.SetDefault("LOG_CONTEXT_LINES_BEFORE", 20)
+	viper.SetDefault("LOG_CONTEXT_TIME_BEFORE", "30s")
+	viper.SetDefault("LOG_CONTEXT_LINES_AFTER", 5)
+	viper.SetDefault("OUTBOUND_QUEUE_DIR", "data/outbound")
+	viper.SetDefault("OUTBOUND_QUEUE_MAX_SIZE", 1000)
+	viper.SetDefault("OUTBOUND_QUEUE_MAX_AGE", "24h")
+	viper.SetDefault("UPLOAD_BATCH_SIZE", 20)
+	viper.SetDefault("UPLOAD_FLUSH_INTERVAL", "5s")
+	viper.SetDefault("UPLOAD_COMPRESSION", CompressionZstd)
+	viper.SetDefault("AGENT_STATE_FILE", "data/agent_state")
+	viper.SetDefault("AGENT_STATE_SECRET", "")
+	viper.SetDefault("AGENT_STATE_SECRET_FILE", "/run/secrets/agent_state_secret")
+	viper.SetDefault("CONTROL_API_ADDRESS", ":37002")
+	viper.SetDefault("CONTROL_API_SOCKET", "")
+	viper.SetDefault("CONTROL_API_SECRET", "")
+	viper.SetDefault("CONTROL_API_SECRET_FILE", "data/control_api_secret")
+	viper.SetDefault("CONTROL_API_ALLOWED_ORIGINS", "http://localhost:37001")
 	err := viper.ReadInConfig()
 	if err != nil {
 		panic(fmt.Errorf("fatal error config file: %s", err))
@@ -73,24 +212,66 @@ func GetEnvVariables() (cfs ConfigServer) {
 	return
 }
 
-func CallLogAnalysis(logs string, containerName string, taskPayload models.TaskPayload) (err error) {
-	data := map[string]string{
-		"logs":          logs,
-		"containerName": containerName,
-		"userId":        taskPayload.UserId,
+// BackendStatusError is returned when the backend answered a request with a
+// status other than 200 OK.
+type BackendStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *BackendStatusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %v", e.Status)
+}
+
+// CallBatchIngestion uploads queued items to the backend in one compressed
+// request and returns the outcome of every item.
+func CallBatchIngestion(ctx context.Context, items []*models.OutboundItem, compression string, taskPayload models.TaskPayload) ([]models.BatchIngestionResult, error) {
+	batchPayload := models.BatchIngestionPayload{
+		Items: make([]models.BatchIngestionItem, 0, len(items)),
+	}
+	for _, item := range items {
+		batchPayload.Items = append(batchPayload.Items, models.BatchIngestionItem{
+			Id:      item.Id,
+			Kind:    item.Kind,
+			Payload: item.Payload,
+		})
 	}
-	jsonData, err := json.Marshal(data)
+	jsonData, err := json.Marshal(batchPayload)
 	if err != nil {
-		return
+		return nil, err
 	}
-	issueAnalysisReq, err := http.NewRequest("PUT", taskPayload.BackendUrl+"/api/agent/issues/analysis", bytes.NewBuffer(jsonData))
+	body, err := CompressPayload(jsonData, compression)
+	if err != nil {
+		return nil, err
+	}
+	var response models.BatchIngestionResponse
+	err = callBackend(ctx, "PUT", "/api/agent/issues/batch", body, compression, taskPayload, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload batch: %w", err)
+	}
+	return response.Results, nil
+}
+
+// BackendRequestTimeout bounds every request to the backend, so a hung
+// connection cannot stall uploads or scans. The backend answers batch
+// uploads within a shorter time budget and leaves the items it did not get
+// to for the next attempt.
+const BackendRequestTimeout = 60 * time.Second
+
+var backendClient = &http.Client{Timeout: BackendRequestTimeout}
+
+func callBackend(ctx context.Context, method string, path string, body []byte, contentEncoding string, taskPayload models.TaskPayload,
+	response interface{}) (err error) {
+	backendReq, err := http.NewRequestWithContext(ctx, method, taskPayload.BackendUrl+path, bytes.NewBuffer(body))
 	if err != nil {
 		return
 	}
-	issueAnalysisReq.Header.Set("Content-Type", "application/json")
-	issueAnalysisReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", taskPayload.BearerToken))
-	client := &http.Client{}
-	resp, err := client.Do(issueAnalysisReq)
+	backendReq.Header.Set("Content-Type", "application/json")
+	if contentEncoding != "" && contentEncoding != CompressionNone {
+		backendReq.Header.Set("Content-Encoding", contentEncoding)
+	}
+	backendReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", taskPayload.BearerToken))
+	resp, err := backendClient.Do(backendReq)
 	if err != nil {
 		return
 	}
@@ -98,7 +279,10 @@ func CallLogAnalysis(logs string, containerName string, taskPayload models.TaskP
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed to call log analysis: %v", resp.Status)
+		return &BackendStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if response != nil {
+		err = json.NewDecoder(resp.Body).Decode(response)
 	}
 	return
 }
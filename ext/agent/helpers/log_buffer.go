@@ -0,0 +1,50 @@
+package helpers
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogBuffer is a logrus hook keeping the most recent agent log lines for
+// the diagnostics bundle.
+type LogBuffer struct {
+	mutex     sync.Mutex
+	size      int
+	lines     []string
+	formatter logrus.Formatter
+}
+
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{
+		size:      size,
+		lines:     make([]string, 0, size),
+		formatter: &logrus.TextFormatter{DisableColors: true, FullTimestamp: true},
+	}
+}
+
+func (b *LogBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (b *LogBuffer) Fire(entry *logrus.Entry) error {
+	line, err := b.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.lines) == b.size {
+		b.lines = append(b.lines[:0], b.lines[1:]...)
+	}
+	b.lines = append(b.lines, strings.TrimRight(string(line), "\n"))
+	return nil
+}
+
+// Lines returns a copy of the buffered log lines, oldest first.
+func (b *LogBuffer) Lines() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]string(nil), b.lines...)
+}
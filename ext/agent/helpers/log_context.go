@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"signal/models"
+	"strings"
+	"time"
+)
+
+// LogContextBuffer keeps the most recent log lines of a container across
+// scans, so a detection can include the lines leading up to a failure even
+// when they were logged in an earlier scan window.
+type LogContextBuffer struct {
+	capacity int
+	lines    []models.LogContextLine
+}
+
+func NewLogContextBuffer(capacity int) *LogContextBuffer {
+	return &LogContextBuffer{
+		capacity: capacity,
+		lines:    make([]models.LogContextLine, 0),
+	}
+}
+
+func (b *LogContextBuffer) Push(lines []models.LogContextLine) {
+	b.lines = append(b.lines, lines...)
+	if overflow := len(b.lines) - b.capacity; overflow > 0 {
+		b.lines = append(b.lines[:0], b.lines[overflow:]...)
+	}
+}
+
+// Window returns the log context of a detection. Triggering lines are looked
+// up among the latest recentLines lines. The window reaches linesBefore lines
+// or timeBefore back from the first trigger, whichever goes further, and
+// linesAfter lines past the last trigger. Without a trigger it covers the
+// lines leading up to the latest one.
+func (b *LogContextBuffer) Window(triggers map[string]bool, recentLines int, linesBefore int, timeBefore time.Duration, linesAfter int) []models.LogContextLine {
+	if len(b.lines) == 0 {
+		return nil
+	}
+	searchFrom := len(b.lines) - recentLines
+	if searchFrom < 0 {
+		searchFrom = 0
+	}
+	first, last := -1, -1
+	for i := searchFrom; i < len(b.lines); i++ {
+		if triggers[b.lines[i].Line] {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	anchor := time.Time{}
+	if first < 0 {
+		first, last = len(b.lines), len(b.lines)-1
+		anchor = b.lines[last].Timestamp
+	} else {
+		anchor = b.lines[first].Timestamp
+	}
+
+	from := first
+	for from > 0 {
+		withinLines := first-from < linesBefore
+		withinTime := !anchor.IsZero() && !b.lines[from-1].Timestamp.IsZero() && anchor.Sub(b.lines[from-1].Timestamp) <= timeBefore
+		if !withinLines && !withinTime {
+			break
+		}
+		from--
+	}
+	to := last + 1 + linesAfter
+	if to > len(b.lines) {
+		to = len(b.lines)
+	}
+
+	window := make([]models.LogContextLine, 0, to-from)
+	for i := from; i < to; i++ {
+		line := b.lines[i]
+		line.IsTrigger = i >= searchFrom && triggers[line.Line]
+		window = append(window, line)
+	}
+	return window
+}
+
+// SplitTimestampedLogLines splits logs collected with timestamps into lines
+// and their timestamps. Lines without a valid timestamp keep a zero one.
+func SplitTimestampedLogLines(logs string) []models.LogContextLine {
+	lines := SplitLogLines(logs)
+	timestampedLines := make([]models.LogContextLine, 0, len(lines))
+	for _, line := range lines {
+		entry := models.LogContextLine{Line: strings.TrimRight(line, "\r")}
+		if prefix, text, found := strings.Cut(entry.Line, " "); found {
+			if timestamp, err := time.Parse(time.RFC3339Nano, prefix); err == nil {
+				entry.Timestamp = timestamp
+				entry.Line = text
+			}
+		}
+		timestampedLines = append(timestampedLines, entry)
+	}
+	return timestampedLines
+}
+
+// LogContextText returns the text of the lines.
+func LogContextText(lines []models.LogContextLine) []string {
+	texts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		texts = append(texts, line.Line)
+	}
+	return texts
+}
@@ -0,0 +1,358 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"signal/models"
+	"strconv"
+	"strings"
+)
+
+const (
+	LogFormatJSON        = "json"
+	LogFormatLogfmt      = "logfmt"
+	LogFormatPython      = "python"
+	LogFormatSpring      = "spring"
+	LogFormatNginxAccess = "nginx_access"
+	LogFormatNginxError  = "nginx_error"
+	LogFormatPlain       = "plain"
+)
+
+const (
+	LogLevelTrace    = "trace"
+	LogLevelDebug    = "debug"
+	LogLevelInfo     = "info"
+	LogLevelWarning  = "warning"
+	LogLevelError    = "error"
+	LogLevelCritical = "critical"
+)
+
+var (
+	levelKeys     = []string{"level", "lvl", "severity", "levelname", "loglevel", "log.level"}
+	messageKeys   = []string{"msg", "message", "log", "event"}
+	timestampKeys = []string{"time", "ts", "timestamp", "@timestamp", "t", "asctime"}
+	errorKeys     = []string{"error", "err", "exception", "exc_info", "stack", "stacktrace", "error.message"}
+
+	logfmtPairPattern      = regexp.MustCompile(`([\w][\w.\-]*)=("(?:[^"\\]|\\.)*"|\S*)`)
+	pythonLogPattern       = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2},\d{3}) - (\S+) - (DEBUG|INFO|WARNING|ERROR|CRITICAL) - (.*)$`)
+	pythonBasicLogPattern  = regexp.MustCompile(`^(DEBUG|INFO|WARNING|ERROR|CRITICAL):([^:]+):(.*)$`)
+	springLogPattern       = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}[.,]\d{3}\S*)\s+(TRACE|DEBUG|INFO|WARN|ERROR|FATAL)\s+\d+\s+---\s+\[\s*([^\]]*)\]\s+(\S+)\s*:\s(.*)$`)
+	nginxAccessLogPattern  = regexp.MustCompile(`^(\S+) \S+ (\S+) \[([^\]]+)\] "([^"]*)" (\d{3}) (\d+|-)(?: "([^"]*)" "([^"]*)")?`)
+	errorOrWarningPattern  = regexp.MustCompile(`(?i)(abort|blocked|corrupt|crash|critical|deadlock|denied|err|error|exception|fatal|forbidden|freeze|hang|illegal|invalid|issue|missing|panic|rejected|refused|stacktrace|timeout|traceback|unauthorized|uncaught|unexpected|unhandled|unimplemented|unsupported|warn|warning)`)
+	criticalPattern        = regexp.MustCompile(`(?i)(crash|critical|deadlock|fatal|panic)`)
+	errorPattern           = regexp.MustCompile(`(?i)(abort|corrupt|denied|err|error|exception|forbidden|illegal|refused|rejected|traceback|unauthorized|uncaught|unhandled)`)
+	nginxErrorLogPattern   = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[(\w+)\] (\d+)#(\d+): (?:\*\d+ )?(.*)$`)
+	numericLevelThresholds = []struct {
+		min   float64
+		level string
+	}{
+		{60, LogLevelCritical},
+		{50, LogLevelError},
+		{40, LogLevelWarning},
+		{30, LogLevelInfo},
+		{20, LogLevelDebug},
+		{0, LogLevelTrace},
+	}
+)
+
+// ParseLogLine detects the format of a single log line and extracts its
+// level, message, timestamp and error fields. Lines in an unknown format are
+// returned as plain text with no level.
+func ParseLogLine(line string) models.ParsedLogLine {
+	parsers := []func(string) (models.ParsedLogLine, bool){
+		parseJSONLogLine,
+		parseSpringLogLine,
+		parsePythonLogLine,
+		parseNginxErrorLogLine,
+		parseNginxAccessLogLine,
+		parseLogfmtLogLine,
+	}
+	for _, parse := range parsers {
+		if parsed, ok := parse(line); ok {
+			return parsed
+		}
+	}
+	return models.ParsedLogLine{
+		Format:  LogFormatPlain,
+		Message: line,
+	}
+}
+
+// ParseLogEvent parses a log event by its first line only. That line holds
+// the level and message of a stack trace, while the frames that follow are
+// reported as raw logs. JSON loggers escape the newlines of a trace, so a
+// JSON log line is never split across the lines of an event.
+func ParseLogEvent(event models.LogEvent) models.ParsedLogLine {
+	if len(event.Lines) == 0 {
+		return models.ParsedLogLine{Format: LogFormatPlain}
+	}
+	return ParseLogLine(event.Lines[0])
+}
+
+// NormalizeLogLevel maps the level names used by common logging frameworks
+// onto the agent's level set. Unknown levels yield an empty string.
+func NormalizeLogLevel(level string) string {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "trace", "trc", "finest", "finer":
+		return LogLevelTrace
+	case "debug", "dbg", "fine", "verbose":
+		return LogLevelDebug
+	case "info", "inf", "information", "notice", "config":
+		return LogLevelInfo
+	case "warn", "warning", "wrn":
+		return LogLevelWarning
+	case "error", "err", "eror", "severe":
+		return LogLevelError
+	case "critical", "crit", "fatal", "ftl", "panic", "alert", "emerg", "emergency":
+		return LogLevelCritical
+	}
+	return ""
+}
+
+// IsLogLevelErrorOrWarning reports whether a normalized level should be
+// treated as a problem.
+func IsLogLevelErrorOrWarning(level string) bool {
+	return level == LogLevelWarning || level == LogLevelError || level == LogLevelCritical
+}
+
+// LogLineProblemLevel returns the level of the problem a parsed log line
+// reports, or an empty string if it reports none. Lines without a level are
+// judged by their error field and the keywords of their message.
+func LogLineProblemLevel(parsed models.ParsedLogLine) string {
+	if parsed.Level != "" {
+		if IsLogLevelErrorOrWarning(parsed.Level) {
+			return parsed.Level
+		}
+		return ""
+	}
+	if parsed.Error != "" {
+		return LogLevelError
+	}
+	return InferLogLevel(parsed.Message)
+}
+
+// InferLogLevel guesses the level of a log message without one from the
+// keywords it contains.
+func InferLogLevel(message string) string {
+	switch {
+	case !errorOrWarningPattern.MatchString(message):
+		return ""
+	case criticalPattern.MatchString(message):
+		return LogLevelCritical
+	case errorPattern.MatchString(message):
+		return LogLevelError
+	}
+	return LogLevelWarning
+}
+
+// LogLevelRank orders normalized log levels by severity.
+func LogLevelRank(level string) int {
+	switch level {
+	case LogLevelTrace:
+		return 1
+	case LogLevelDebug:
+		return 2
+	case LogLevelInfo:
+		return 3
+	case LogLevelWarning:
+		return 4
+	case LogLevelError:
+		return 5
+	case LogLevelCritical:
+		return 6
+	}
+	return 0
+}
+
+func parseJSONLogLine(line string) (models.ParsedLogLine, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return models.ParsedLogLine{}, false
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &raw); err != nil {
+		return models.ParsedLogLine{}, false
+	}
+	fields := make(map[string]string, len(raw))
+	for key, value := range raw {
+		fields[key] = stringifyLogField(value)
+	}
+	parsed := models.ParsedLogLine{
+		Format:    LogFormatJSON,
+		Message:   firstLogField(fields, messageKeys),
+		Timestamp: firstLogField(fields, timestampKeys),
+		Error:     firstLogField(fields, errorKeys),
+	}
+	for _, key := range levelKeys {
+		value, exists := raw[key]
+		if !exists {
+			continue
+		}
+		if number, isNumber := value.(float64); isNumber {
+			parsed.Level = numericLogLevel(number)
+		} else {
+			parsed.Level = NormalizeLogLevel(fields[key])
+		}
+		break
+	}
+	parsed.Fields = withoutLogFields(fields, levelKeys, messageKeys, timestampKeys, errorKeys)
+	return parsed, true
+}
+
+func parseLogfmtLogLine(line string) (models.ParsedLogLine, bool) {
+	matches := logfmtPairPattern.FindAllStringSubmatch(line, -1)
+	if len(matches) < 2 {
+		return models.ParsedLogLine{}, false
+	}
+	fields := make(map[string]string, len(matches))
+	for _, match := range matches {
+		value := match[2]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		fields[match[1]] = value
+	}
+	level := firstLogField(fields, levelKeys)
+	message := firstLogField(fields, messageKeys)
+	if level == "" && message == "" {
+		return models.ParsedLogLine{}, false
+	}
+	return models.ParsedLogLine{
+		Format:    LogFormatLogfmt,
+		Level:     NormalizeLogLevel(level),
+		Message:   message,
+		Timestamp: firstLogField(fields, timestampKeys),
+		Error:     firstLogField(fields, errorKeys),
+		Fields:    withoutLogFields(fields, levelKeys, messageKeys, timestampKeys, errorKeys),
+	}, true
+}
+
+func parsePythonLogLine(line string) (models.ParsedLogLine, bool) {
+	if match := pythonLogPattern.FindStringSubmatch(line); match != nil {
+		return models.ParsedLogLine{
+			Format:    LogFormatPython,
+			Level:     NormalizeLogLevel(match[3]),
+			Message:   match[4],
+			Timestamp: match[1],
+			Fields:    map[string]string{"logger": match[2]},
+		}, true
+	}
+	if match := pythonBasicLogPattern.FindStringSubmatch(line); match != nil {
+		return models.ParsedLogLine{
+			Format:  LogFormatPython,
+			Level:   NormalizeLogLevel(match[1]),
+			Message: match[3],
+			Fields:  map[string]string{"logger": match[2]},
+		}, true
+	}
+	return models.ParsedLogLine{}, false
+}
+
+func parseSpringLogLine(line string) (models.ParsedLogLine, bool) {
+	match := springLogPattern.FindStringSubmatch(line)
+	if match == nil {
+		return models.ParsedLogLine{}, false
+	}
+	return models.ParsedLogLine{
+		Format:    LogFormatSpring,
+		Level:     NormalizeLogLevel(match[2]),
+		Message:   match[5],
+		Timestamp: match[1],
+		Fields: map[string]string{
+			"thread": match[3],
+			"logger": match[4],
+		},
+	}, true
+}
+
+func parseNginxAccessLogLine(line string) (models.ParsedLogLine, bool) {
+	match := nginxAccessLogPattern.FindStringSubmatch(line)
+	if match == nil {
+		return models.ParsedLogLine{}, false
+	}
+	status, _ := strconv.Atoi(match[5])
+	level := LogLevelInfo
+	if status >= 500 {
+		level = LogLevelError
+	}
+	return models.ParsedLogLine{
+		Format:    LogFormatNginxAccess,
+		Level:     level,
+		Message:   fmt.Sprintf("%s %s", match[4], match[5]),
+		Timestamp: match[3],
+		Fields: map[string]string{
+			"remoteAddr": match[1],
+			"status":     match[5],
+			"bytesSent":  match[6],
+			"referer":    match[7],
+			"userAgent":  match[8],
+		},
+	}, true
+}
+
+func parseNginxErrorLogLine(line string) (models.ParsedLogLine, bool) {
+	match := nginxErrorLogPattern.FindStringSubmatch(line)
+	if match == nil {
+		return models.ParsedLogLine{}, false
+	}
+	return models.ParsedLogLine{
+		Format:    LogFormatNginxError,
+		Level:     NormalizeLogLevel(match[2]),
+		Message:   match[5],
+		Timestamp: match[1],
+		Fields:    map[string]string{"pid": match[3]},
+	}, true
+}
+
+func numericLogLevel(number float64) string {
+	for _, threshold := range numericLevelThresholds {
+		if number >= threshold.min {
+			return threshold.level
+		}
+	}
+	return ""
+}
+
+func stringifyLogField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+func firstLogField(fields map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value, exists := fields[key]; exists && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func withoutLogFields(fields map[string]string, keySets ...[]string) map[string]string {
+	remaining := make(map[string]string, len(fields))
+	for key, value := range fields {
+		remaining[key] = value
+	}
+	for _, keys := range keySets {
+		for _, key := range keys {
+			delete(remaining, key)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil
+	}
+	return remaining
+}
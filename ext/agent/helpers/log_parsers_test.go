@@ -0,0 +1,196 @@
+package helpers
+
+import (
+	"reflect"
+	"signal/models"
+	"testing"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected models.ParsedLogLine
+	}{
+		{
+			name: "json",
+			line: `{"level":"error","msg":"query failed","time":"2024-05-01T10:00:00Z","error":"timeout","db":"orders"}`,
+			expected: models.ParsedLogLine{
+				Format:    LogFormatJSON,
+				Level:     LogLevelError,
+				Message:   "query failed",
+				Timestamp: "2024-05-01T10:00:00Z",
+				Error:     "timeout",
+				Fields:    map[string]string{"db": "orders"},
+			},
+		},
+		{
+			name: "json with numeric level",
+			line: `{"level":40,"msg":"slow response","latency":1.5,"cached":false}`,
+			expected: models.ParsedLogLine{
+				Format:  LogFormatJSON,
+				Level:   LogLevelWarning,
+				Message: "slow response",
+				Fields:  map[string]string{"latency": "1.5", "cached": "false"},
+			},
+		},
+		{
+			name: "json with nested field",
+			line: `{"severity":"CRITICAL","message":"out of memory","context":{"pod":"api-1"}}`,
+			expected: models.ParsedLogLine{
+				Format:  LogFormatJSON,
+				Level:   LogLevelCritical,
+				Message: "out of memory",
+				Fields:  map[string]string{"context": `{"pod":"api-1"}`},
+			},
+		},
+		{
+			name: "logfmt",
+			line: `time=2024-05-01T10:00:00Z level=warn msg="disk almost full" path=/data`,
+			expected: models.ParsedLogLine{
+				Format:    LogFormatLogfmt,
+				Level:     LogLevelWarning,
+				Message:   "disk almost full",
+				Timestamp: "2024-05-01T10:00:00Z",
+				Fields:    map[string]string{"path": "/data"},
+			},
+		},
+		{
+			name: "logfmt with error",
+			line: `lvl=eror msg=failed err="connection refused"`,
+			expected: models.ParsedLogLine{
+				Format:  LogFormatLogfmt,
+				Level:   LogLevelError,
+				Message: "failed",
+				Error:   "connection refused",
+			},
+		},
+		{
+			name: "python",
+			line: "2024-05-01 10:00:00,123 - app.worker - ERROR - job 7 failed",
+			expected: models.ParsedLogLine{
+				Format:    LogFormatPython,
+				Level:     LogLevelError,
+				Message:   "job 7 failed",
+				Timestamp: "2024-05-01 10:00:00,123",
+				Fields:    map[string]string{"logger": "app.worker"},
+			},
+		},
+		{
+			name: "python basic config",
+			line: "WARNING:root:retrying in 5s",
+			expected: models.ParsedLogLine{
+				Format:  LogFormatPython,
+				Level:   LogLevelWarning,
+				Message: "retrying in 5s",
+				Fields:  map[string]string{"logger": "root"},
+			},
+		},
+		{
+			name: "spring",
+			line: "2024-05-01 10:00:00.123  ERROR 1 --- [nio-8080-exec-1] o.a.c.c.C.[dispatcherServlet]            : Servlet.service() failed",
+			expected: models.ParsedLogLine{
+				Format:    LogFormatSpring,
+				Level:     LogLevelError,
+				Message:   "Servlet.service() failed",
+				Timestamp: "2024-05-01 10:00:00.123",
+				Fields:    map[string]string{"thread": "nio-8080-exec-1", "logger": "o.a.c.c.C.[dispatcherServlet]"},
+			},
+		},
+		{
+			name: "nginx access",
+			line: `172.18.0.1 - - [01/May/2024:10:00:00 +0000] "GET /api/orders HTTP/1.1" 502 157 "-" "curl/8.5.0"`,
+			expected: models.ParsedLogLine{
+				Format:    LogFormatNginxAccess,
+				Level:     LogLevelError,
+				Message:   "GET /api/orders HTTP/1.1 502",
+				Timestamp: "01/May/2024:10:00:00 +0000",
+				Fields: map[string]string{
+					"remoteAddr": "172.18.0.1",
+					"status":     "502",
+					"bytesSent":  "157",
+					"referer":    "-",
+					"userAgent":  "curl/8.5.0",
+				},
+			},
+		},
+		{
+			name: "nginx access success",
+			line: `172.18.0.1 - - [01/May/2024:10:00:00 +0000] "GET / HTTP/1.1" 200 615`,
+			expected: models.ParsedLogLine{
+				Format:    LogFormatNginxAccess,
+				Level:     LogLevelInfo,
+				Message:   "GET / HTTP/1.1 200",
+				Timestamp: "01/May/2024:10:00:00 +0000",
+				Fields:    map[string]string{"remoteAddr": "172.18.0.1", "status": "200", "bytesSent": "615", "referer": "", "userAgent": ""},
+			},
+		},
+		{
+			name: "nginx error",
+			line: "2024/05/01 10:00:00 [crit] 29#29: *1 connect() to 172.18.0.3:8080 failed",
+			expected: models.ParsedLogLine{
+				Format:    LogFormatNginxError,
+				Level:     LogLevelCritical,
+				Message:   "connect() to 172.18.0.3:8080 failed",
+				Timestamp: "2024/05/01 10:00:00",
+				Fields:    map[string]string{"pid": "29"},
+			},
+		},
+		{
+			name:     "plain",
+			line:     "Exception in thread \"main\" java.lang.IllegalStateException: boom",
+			expected: models.ParsedLogLine{Format: LogFormatPlain, Message: "Exception in thread \"main\" java.lang.IllegalStateException: boom"},
+		},
+		{
+			name:     "invalid json",
+			line:     `{"level":"error"`,
+			expected: models.ParsedLogLine{Format: LogFormatPlain, Message: `{"level":"error"`},
+		},
+		{
+			name:     "key value pair without level or message",
+			line:     "user=alice role=admin",
+			expected: models.ParsedLogLine{Format: LogFormatPlain, Message: "user=alice role=admin"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if parsed := ParseLogLine(test.line); !reflect.DeepEqual(parsed, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, parsed)
+			}
+		})
+	}
+}
+
+func TestParseLogEventUsesFirstLine(t *testing.T) {
+	parsed := ParseLogEvent(models.LogEvent{
+		Lines: []string{
+			"2024-05-01 10:00:00,123 - app.worker - CRITICAL - unhandled error",
+			"Traceback (most recent call last):",
+			"  File \"worker.py\", line 9, in run",
+		},
+		IsStackTrace: true,
+	})
+	if parsed.Format != LogFormatPython || parsed.Level != LogLevelCritical || parsed.Message != "unhandled error" {
+		t.Errorf("expected the first line to be parsed, got %+v", parsed)
+	}
+	if parsed := ParseLogEvent(models.LogEvent{}); parsed.Format != LogFormatPlain {
+		t.Errorf("expected an empty event to be plain, got %+v", parsed)
+	}
+}
+
+func TestNormalizeLogLevel(t *testing.T) {
+	tests := map[string]string{
+		"TRACE":     LogLevelTrace,
+		"fine":      LogLevelDebug,
+		" Info ":    LogLevelInfo,
+		"WARNING":   LogLevelWarning,
+		"severe":    LogLevelError,
+		"emerg":     LogLevelCritical,
+		"something": "",
+	}
+	for level, expected := range tests {
+		if normalized := NormalizeLogLevel(level); normalized != expected {
+			t.Errorf("expected %q to be %q, got %q", level, expected, normalized)
+		}
+	}
+}
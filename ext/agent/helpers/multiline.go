@@ -0,0 +1,185 @@
+package helpers
+
+import (
+	"regexp"
+	"signal/models"
+	"strings"
+)
+
+const maxStackTraceLines = 500
+
+type traceKind int
+
+const (
+	traceNone traceKind = iota
+	traceGeneric
+	tracePython
+	traceGo
+)
+
+var (
+	pythonTraceStartPattern   = regexp.MustCompile(`^Traceback \(most recent call last\):`)
+	pythonTraceChainPattern   = regexp.MustCompile(`^(Traceback \(most recent call last\):|During handling of the above exception|The above exception was the direct cause)`)
+	goTraceStartPattern       = regexp.MustCompile(`^(panic: |fatal error: )`)
+	goFramePattern            = regexp.MustCompile(`^(goroutine \d+ \[|created by |\[signal |exit status \d+|[\w./*()\[\]{}-]+\(.*\)$)`)
+	genericTraceStartPattern  = regexp.MustCompile(`^(Exception in thread |(Uncaught )?([\w$]+\.)*[\w$]*(Exception|Error)(: |:?$))`)
+	traceContinuationPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^\s+\S`),                                      // indented frames: Java/Node "at ...", Python "File ...", Go file:line
+		regexp.MustCompile(`^\s*(Caused by|Suppressed): `),                // Java nested causes
+		regexp.MustCompile(`^\s*\.\.\. \d+ (more|common frames omitted)`), // Java truncated frames
+	}
+)
+
+// LogEventAggregator groups raw log lines into events so that a multi-line
+// stack trace is evaluated as a single occurrence instead of line fragments.
+type LogEventAggregator struct {
+	contextSize int
+	recent      []string
+	trace       *models.LogEvent
+	kind        traceKind
+	terminated  bool
+	blanks      int
+}
+
+func NewLogEventAggregator(contextSize int) *LogEventAggregator {
+	return &LogEventAggregator{
+		contextSize: contextSize,
+		recent:      make([]string, 0, contextSize),
+	}
+}
+
+// Push consumes the next batch of lines and returns the events it completed.
+// A stack trace still in progress at the end of the batch is held back until
+// the next call, so traces split across scan windows stay whole; an empty
+// batch completes it.
+func (a *LogEventAggregator) Push(lines []string) []models.LogEvent {
+	events := make([]models.LogEvent, 0)
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		isBlank := strings.TrimSpace(line) == ""
+		if a.trace != nil {
+			if isBlank {
+				a.blanks++
+				continue
+			}
+			if a.continuesTrace(line) && len(a.trace.Lines) < maxStackTraceLines {
+				for ; a.blanks > 0; a.blanks-- {
+					a.trace.Lines = append(a.trace.Lines, "")
+				}
+				a.trace.Lines = append(a.trace.Lines, line)
+				a.remember(line)
+				continue
+			}
+			events = a.closeTrace(events)
+		}
+		if isBlank {
+			continue
+		}
+		if kind := traceStartKind(line); kind != traceNone {
+			a.trace = &models.LogEvent{Context: a.context(), Lines: []string{line}}
+			a.kind = kind
+			a.terminated = false
+		} else {
+			events = append(events, models.LogEvent{Context: a.context(), Lines: []string{line}})
+		}
+		a.remember(line)
+	}
+	if len(lines) == 0 {
+		events = a.closeTrace(events)
+	}
+	return events
+}
+
+// PendingLines returns the number of lines of a stack trace held back until
+// the next batch.
+func (a *LogEventAggregator) PendingLines() int {
+	if a.trace == nil {
+		return 0
+	}
+	return len(a.trace.Lines) + a.blanks
+}
+
+func (a *LogEventAggregator) continuesTrace(line string) bool {
+	if a.kind == tracePython && a.terminated {
+		if pythonTraceChainPattern.MatchString(line) {
+			a.terminated = !pythonTraceStartPattern.MatchString(line)
+			return true
+		}
+		return false
+	}
+	for _, pattern := range traceContinuationPatterns {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+	switch a.kind {
+	case traceGo:
+		return goFramePattern.MatchString(line)
+	case tracePython:
+		// The first unindented line of a traceback is the exception message
+		// and closes it, unless another chained traceback follows.
+		a.terminated = true
+		return true
+	}
+	return false
+}
+
+func (a *LogEventAggregator) closeTrace(events []models.LogEvent) []models.LogEvent {
+	if a.trace == nil {
+		return events
+	}
+	a.trace.IsStackTrace = len(a.trace.Lines) > 1
+	events = append(events, *a.trace)
+	a.trace = nil
+	a.kind = traceNone
+	a.blanks = 0
+	return events
+}
+
+func (a *LogEventAggregator) context() []string {
+	return append([]string(nil), a.recent...)
+}
+
+func (a *LogEventAggregator) remember(line string) {
+	if a.contextSize <= 0 {
+		return
+	}
+	if len(a.recent) == a.contextSize {
+		a.recent = append(a.recent[:0], a.recent[1:]...)
+	}
+	a.recent = append(a.recent, line)
+}
+
+func traceStartKind(line string) traceKind {
+	switch {
+	case pythonTraceStartPattern.MatchString(line):
+		return tracePython
+	case goTraceStartPattern.MatchString(line):
+		return traceGo
+	case genericTraceStartPattern.MatchString(line):
+		return traceGeneric
+	}
+	return traceNone
+}
+
+// SplitLogLines splits raw container logs into lines.
+func SplitLogLines(logs string) []string {
+	logs = strings.TrimRight(logs, "\n")
+	if logs == "" {
+		return nil
+	}
+	return strings.Split(logs, "\n")
+}
+
+// JoinLogEvents renders events back into log text. When the batch starts
+// with a stack trace, its preceding context lines are kept as well.
+func JoinLogEvents(events []models.LogEvent) string {
+	lines := make([]string, 0)
+	if len(events) > 0 && events[0].IsStackTrace {
+		lines = append(lines, events[0].Context...)
+	}
+	for _, e := range events {
+		lines = append(lines, e.Lines...)
+	}
+	return strings.Join(lines, "\n")
+}
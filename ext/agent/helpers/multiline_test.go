@@ -0,0 +1,156 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogEventAggregatorGroupsStackTraces(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected [][]string
+	}{
+		{
+			name: "java",
+			lines: []string{
+				"INFO starting",
+				"Exception in thread \"main\" java.lang.IllegalStateException: boom",
+				"\tat com.example.App.run(App.java:12)",
+				"\tat com.example.App.main(App.java:5)",
+				"Caused by: java.io.IOException: disk full",
+				"\tat com.example.Store.write(Store.java:40)",
+				"\t... 2 more",
+				"INFO shutting down",
+			},
+			expected: [][]string{
+				{"INFO starting"},
+				{
+					"Exception in thread \"main\" java.lang.IllegalStateException: boom",
+					"\tat com.example.App.run(App.java:12)",
+					"\tat com.example.App.main(App.java:5)",
+					"Caused by: java.io.IOException: disk full",
+					"\tat com.example.Store.write(Store.java:40)",
+					"\t... 2 more",
+				},
+				{"INFO shutting down"},
+			},
+		},
+		{
+			name: "python with chained exception",
+			lines: []string{
+				"Traceback (most recent call last):",
+				"  File \"app.py\", line 3, in <module>",
+				"    main()",
+				"KeyError: 'id'",
+				"",
+				"During handling of the above exception, another exception occurred:",
+				"",
+				"Traceback (most recent call last):",
+				"  File \"app.py\", line 5, in <module>",
+				"ValueError: bad id",
+				"INFO restarting",
+			},
+			expected: [][]string{
+				{
+					"Traceback (most recent call last):",
+					"  File \"app.py\", line 3, in <module>",
+					"    main()",
+					"KeyError: 'id'",
+					"",
+					"During handling of the above exception, another exception occurred:",
+					"",
+					"Traceback (most recent call last):",
+					"  File \"app.py\", line 5, in <module>",
+					"ValueError: bad id",
+				},
+				{"INFO restarting"},
+			},
+		},
+		{
+			name: "go panic",
+			lines: []string{
+				"panic: runtime error: invalid memory address or nil pointer dereference",
+				"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4553a2]",
+				"",
+				"goroutine 1 [running]:",
+				"main.handler(0x0)",
+				"\t/app/main.go:21 +0x22",
+				"exit status 2",
+				"level=info msg=\"restarted\"",
+			},
+			expected: [][]string{
+				{
+					"panic: runtime error: invalid memory address or nil pointer dereference",
+					"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4553a2]",
+					"",
+					"goroutine 1 [running]:",
+					"main.handler(0x0)",
+					"\t/app/main.go:21 +0x22",
+					"exit status 2",
+				},
+				{"level=info msg=\"restarted\""},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			aggregator := NewLogEventAggregator(5)
+			events := aggregator.Push(test.lines)
+			if len(events) != len(test.expected) {
+				t.Fatalf("expected %d events, got %d: %+v", len(test.expected), len(events), events)
+			}
+			for i, event := range events {
+				if got, expected := strings.Join(event.Lines, "\n"), strings.Join(test.expected[i], "\n"); got != expected {
+					t.Errorf("event %d: expected\n%s\ngot\n%s", i, expected, got)
+				}
+				if event.IsStackTrace != (len(test.expected[i]) > 1) {
+					t.Errorf("event %d: expected IsStackTrace %t", i, len(test.expected[i]) > 1)
+				}
+			}
+		})
+	}
+}
+
+func TestLogEventAggregatorHoldsTraceAcrossPushes(t *testing.T) {
+	aggregator := NewLogEventAggregator(5)
+	events := aggregator.Push([]string{
+		"INFO handling request",
+		"java.lang.NullPointerException: name",
+		"\tat com.example.Handler.handle(Handler.java:7)",
+	})
+	if len(events) != 1 || events[0].Lines[0] != "INFO handling request" {
+		t.Fatalf("expected only the completed line, got %+v", events)
+	}
+	if pending := aggregator.PendingLines(); pending != 2 {
+		t.Fatalf("expected 2 pending lines, got %d", pending)
+	}
+
+	events = aggregator.Push([]string{
+		"\tat com.example.Server.serve(Server.java:30)",
+		"INFO next request",
+	})
+	if len(events) != 2 {
+		t.Fatalf("expected the trace and the next line, got %+v", events)
+	}
+	trace := events[0]
+	if !trace.IsStackTrace || len(trace.Lines) != 3 || trace.Lines[2] != "\tat com.example.Server.serve(Server.java:30)" {
+		t.Errorf("expected the trace to be whole, got %+v", trace)
+	}
+	if len(trace.Context) != 1 || trace.Context[0] != "INFO handling request" {
+		t.Errorf("expected the line before the trace as context, got %+v", trace.Context)
+	}
+	if pending := aggregator.PendingLines(); pending != 0 {
+		t.Errorf("expected no pending lines, got %d", pending)
+	}
+}
+
+func TestLogEventAggregatorEmptyPushCompletesTrace(t *testing.T) {
+	aggregator := NewLogEventAggregator(5)
+	aggregator.Push([]string{"Traceback (most recent call last):", "  File \"job.py\", line 1, in <module>"})
+
+	events := aggregator.Push(nil)
+	if len(events) != 1 || !events[0].IsStackTrace || len(events[0].Lines) != 2 {
+		t.Fatalf("expected the pending trace, got %+v", events)
+	}
+}
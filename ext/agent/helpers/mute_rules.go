@@ -0,0 +1,103 @@
+package helpers
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"signal/models"
+	"sync"
+	"time"
+)
+
+// MuteRuleSet holds the mute rules last fetched from the backend. Only rules
+// dropping detections are applied by the agent; detections marked by a rule
+// are still sent so the backend can keep them as muted issues.
+type MuteRuleSet struct {
+	mutex     sync.Mutex
+	userId    string
+	rules     []models.MuteRule
+	patterns  map[string]*regexp.Regexp
+	fetchedAt time.Time
+}
+
+func NewMuteRuleSet() *MuteRuleSet {
+	return &MuteRuleSet{
+		rules:    make([]models.MuteRule, 0),
+		patterns: make(map[string]*regexp.Regexp),
+	}
+}
+
+// Set replaces the rules with the ones fetched for the user. Rules with a
+// pattern that does not compile are left out.
+func (s *MuteRuleSet) Set(userId string, rules []models.MuteRule, fetchedAt time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.userId = userId
+	s.rules = make([]models.MuteRule, 0, len(rules))
+	s.patterns = make(map[string]*regexp.Regexp)
+	for _, rule := range rules {
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				continue
+			}
+			s.patterns[rule.Id] = pattern
+		}
+		s.rules = append(s.rules, rule)
+	}
+	s.fetchedAt = fetchedAt
+}
+
+// NeedsRefresh reports whether the rules of the user were fetched more than
+// the interval ago, or not at all.
+func (s *MuteRuleSet) NeedsRefresh(userId string, now time.Time, interval time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.userId != userId || now.Sub(s.fetchedAt) >= interval
+}
+
+// Drops returns the rule dropping the detection, or nil when the detection
+// has to be sent.
+func (s *MuteRuleSet) Drops(userId string, payload models.LogAnalysisPayload, now time.Time) *models.MuteRule {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.userId != userId || len(s.rules) == 0 {
+		return nil
+	}
+	fingerprint := ""
+	for i, rule := range s.rules {
+		if rule.Action != models.MuteActionDrop || (rule.ExpiresAt != nil && !rule.ExpiresAt.After(now)) {
+			continue
+		}
+		if rule.ContainerName != "" && rule.ContainerName != payload.ContainerName {
+			continue
+		}
+		if rule.Severity != "" && rule.Severity != IssueSeverity(payload) {
+			continue
+		}
+		if pattern, exists := s.patterns[rule.Id]; exists && !pattern.MatchString(payload.Logs) {
+			continue
+		}
+		if rule.Fingerprint != "" {
+			if fingerprint == "" {
+				fingerprint = IssueFingerprint(userId, payload)
+			}
+			if rule.Fingerprint != fingerprint {
+				continue
+			}
+		}
+		return &s.rules[i]
+	}
+	return nil
+}
+
+// FetchMuteRules returns the active mute rules of the user the agent reports
+// for.
+func FetchMuteRules(ctx context.Context, taskPayload models.TaskPayload) ([]models.MuteRule, error) {
+	var response models.MuteRulesResponse
+	err := callBackend(ctx, "GET", "/api/agent/mute-rules?userId="+url.QueryEscape(taskPayload.UserId), nil, "", taskPayload, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Rules, nil
+}
@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"signal/models"
+	"testing"
+	"time"
+)
+
+func TestMuteRuleSetDropsRecurringFingerprint(t *testing.T) {
+	detection := func(line string) models.LogAnalysisPayload {
+		return models.LogAnalysisPayload{
+			ContainerName:  "/shop-api-1",
+			ComposeProject: "shop",
+			ComposeService: "api",
+			IssueType:      models.IssueTypeError,
+			Logs:           line,
+			ParsedLogs:     []models.ParsedLogLine{ParseLogLine(line)},
+		}
+	}
+	first := detection("ERROR request 7f3e9a12-4b1c-4d2e-9f00-123456789abc failed after 1200ms")
+	recurrence := detection("ERROR request 0a1b2c3d-0000-4000-8000-000000000001 failed after 35ms")
+	recurrence.ContainerName = "/shop-api-2"
+	other := detection("ERROR failed to load configuration")
+
+	now := time.Now()
+	rules := NewMuteRuleSet()
+	rules.Set("user", []models.MuteRule{
+		{Id: "r1", Fingerprint: IssueFingerprint("user", first), Action: models.MuteActionDrop},
+		{Id: "r2", Pattern: "(", Action: models.MuteActionDrop},
+	}, now)
+
+	if rule := rules.Drops("user", recurrence, now); rule == nil || rule.Id != "r1" {
+		t.Errorf("expected the recurrence on another replica to be dropped, got %+v", rule)
+	}
+	if rule := rules.Drops("user", other, now); rule != nil {
+		t.Errorf("expected another problem not to be dropped, got %+v", rule)
+	}
+	if rule := rules.Drops("another-user", recurrence, now); rule != nil {
+		t.Errorf("expected the rules of another user not to apply, got %+v", rule)
+	}
+	if !rules.NeedsRefresh("user", now.Add(time.Hour), time.Minute) || rules.NeedsRefresh("user", now, time.Minute) {
+		t.Error("expected the rules to need a refresh only once the interval elapsed")
+	}
+}
@@ -0,0 +1,387 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"signal/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	OutboundKindLogAnalysis = "log_analysis"
+	OutboundKindRecovery    = "recovery"
+)
+
+const (
+	defaultUploadFlushInterval = 5 * time.Second
+	maxUploadBatchSize         = 100
+	outboundRetryBaseDelay     = 2 * time.Second
+	outboundRetryMaxDelay      = 5 * time.Minute
+	deadLetterFileName         = "dead_letter.jsonl"
+)
+
+type OutboundQueueOptions struct {
+	Dir           string
+	MaxSize       int
+	MaxAge        time.Duration
+	BatchSize     int
+	FlushInterval time.Duration
+	Compression   string
+}
+
+// OutboundQueue stores reports on disk until the backend accepted them, so
+// detections survive backend outages and agent restarts. Reports are
+// uploaded in compressed batches once a batch is full or the flush interval
+// elapsed. Failed deliveries are retried with exponential backoff and
+// jitter; items that are rejected by the backend, outlive the maximum age or
+// are pushed out of a full queue are moved to the dead-letter file.
+type OutboundQueue struct {
+	mutex       sync.Mutex
+	options     OutboundQueueOptions
+	items       []*models.OutboundItem
+	taskPayload models.TaskPayload
+	status      models.OutboundQueueStatus
+	random      *rand.Rand
+	wakeup      chan struct{}
+	logger      *logrus.Logger
+}
+
+func NewOutboundQueue(options OutboundQueueOptions, logger *logrus.Logger) (*OutboundQueue, error) {
+	if _, err := CompressPayload(nil, options.Compression); err != nil {
+		return nil, err
+	}
+	if options.BatchSize <= 0 || options.BatchSize > maxUploadBatchSize {
+		options.BatchSize = maxUploadBatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = defaultUploadFlushInterval
+	}
+	if err := os.MkdirAll(options.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create outbound queue directory: %v", err)
+	}
+	q := &OutboundQueue{
+		options: options,
+		items:   make([]*models.OutboundItem, 0),
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		wakeup:  make(chan struct{}, 1),
+		logger:  logger,
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// SetTaskPayload updates the backend address and credentials used for
+// deliveries, e.g. after the user logged in.
+func (q *OutboundQueue) SetTaskPayload(taskPayload models.TaskPayload) {
+	q.mutex.Lock()
+	q.taskPayload = taskPayload
+	q.mutex.Unlock()
+	q.notify()
+}
+
+func (q *OutboundQueue) EnqueueLogAnalysis(payload models.LogAnalysisPayload, taskPayload models.TaskPayload) error {
+	payload.UserId = taskPayload.UserId
+	return q.enqueue(OutboundKindLogAnalysis, payload)
+}
+
+func (q *OutboundQueue) EnqueueRecovery(payload models.RecoveryPayload, taskPayload models.TaskPayload) error {
+	payload.UserId = taskPayload.UserId
+	return q.enqueue(OutboundKindRecovery, payload)
+}
+
+// Depth returns the number of items waiting for delivery.
+func (q *OutboundQueue) Depth() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.items)
+}
+
+// Status returns the queue depth and the outcome of recent deliveries.
+func (q *OutboundQueue) Status() models.OutboundQueueStatus {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	status := q.status
+	status.QueuedUploads = len(q.items)
+	return status
+}
+
+// Run delivers queued items until the context is done, which also cancels
+// an upload in flight.
+func (q *OutboundQueue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.options.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wakeup:
+		}
+		q.deliverDue(ctx, time.Now())
+	}
+}
+
+func (q *OutboundQueue) enqueue(kind string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	item := &models.OutboundItem{
+		Id:            fmt.Sprintf("%019d-%s", now.UnixNano(), uuid.NewString()),
+		Kind:          kind,
+		Payload:       data,
+		EnqueuedAt:    now,
+		NextAttemptAt: now,
+	}
+	q.mutex.Lock()
+	if err := q.persist(item); err != nil {
+		q.mutex.Unlock()
+		return fmt.Errorf("failed to persist outbound item: %v", err)
+	}
+	q.items = append(q.items, item)
+	for len(q.items) > q.options.MaxSize {
+		q.deadLetter(0, "outbound queue is full", now)
+	}
+	isBatchFull := len(q.items) >= q.options.BatchSize
+	q.mutex.Unlock()
+	if isBatchFull {
+		q.notify()
+	}
+	return nil
+}
+
+// deliverDue uploads the items whose backoff has elapsed in batches, in the
+// order they were queued. Delivery stops at the first batch with retryable
+// failures, so an unreachable backend is not hit with every batch at once.
+func (q *OutboundQueue) deliverDue(ctx context.Context, now time.Time) {
+	q.expire(now)
+	for ctx.Err() == nil {
+		batch, taskPayload := q.nextBatch(now)
+		if len(batch) == 0 {
+			return
+		}
+		results, err := CallBatchIngestion(ctx, batch, q.options.Compression, taskPayload)
+		if !q.complete(batch, results, err, now) {
+			return
+		}
+	}
+}
+
+func (q *OutboundQueue) nextBatch(now time.Time) ([]*models.OutboundItem, models.TaskPayload) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.taskPayload.BearerToken == "" || q.taskPayload.BackendUrl == "" {
+		return nil, q.taskPayload
+	}
+	batch := make([]*models.OutboundItem, 0, q.options.BatchSize)
+	for _, item := range q.items {
+		if len(batch) == q.options.BatchSize {
+			break
+		}
+		if !item.NextAttemptAt.After(now) {
+			batch = append(batch, item)
+		}
+	}
+	return batch, q.taskPayload
+}
+
+// complete records the outcome of a batch upload and reports whether the
+// delivery round may go on. A failed request applies to every item of the
+// batch, otherwise each item is handled by its own result.
+func (q *OutboundQueue) complete(batch []*models.OutboundItem, results []models.BatchIngestionResult, batchErr error, now time.Time) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if batchErr == nil {
+		q.status.LastDeliveryAt = now
+	}
+	resultsById := make(map[string]models.BatchIngestionResult, len(results))
+	for _, result := range results {
+		resultsById[result.Id] = result
+	}
+	retried := 0
+	var retryErr error
+	for _, item := range batch {
+		index := q.indexOf(item)
+		if index < 0 {
+			continue
+		}
+		deliveryErr := batchErr
+		if deliveryErr == nil {
+			result, exists := resultsById[item.Id]
+			switch {
+			case !exists:
+				deliveryErr = errors.New("missing from the batch response")
+			case result.Status != 200:
+				deliveryErr = &BackendStatusError{StatusCode: result.Status, Status: fmt.Sprintf("%d %s", result.Status, result.Error)}
+			}
+		}
+		if deliveryErr == nil {
+			q.remove(index)
+			continue
+		}
+		item.Attempts++
+		item.LastError = deliveryErr.Error()
+		q.status.LastBackendError = deliveryErr.Error()
+		q.status.LastErrorAt = now
+		if !isRetryableDeliveryError(deliveryErr) {
+			q.deadLetter(index, fmt.Sprintf("rejected by backend: %v", deliveryErr), now)
+			continue
+		}
+		item.NextAttemptAt = now.Add(q.backoff(item.Attempts))
+		if err := q.persist(item); err != nil {
+			q.logger.Errorf("Failed to persist outbound item %s: %v", item.Id, err)
+		}
+		retried++
+		retryErr = deliveryErr
+	}
+	if retried > 0 {
+		q.logger.Warnf("Failed to deliver %d of %d reports, retrying with backoff: %v", retried, len(batch), retryErr)
+	}
+	return retried == 0
+}
+
+func (q *OutboundQueue) expire(now time.Time) {
+	if q.options.MaxAge <= 0 {
+		return
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for i := 0; i < len(q.items); {
+		if now.Sub(q.items[i].EnqueuedAt) > q.options.MaxAge {
+			q.deadLetter(i, fmt.Sprintf("not delivered within %s", q.options.MaxAge), now)
+			continue
+		}
+		i++
+	}
+}
+
+// backoff returns the delay before the next attempt: exponential in the
+// number of attempts, capped, with the upper half randomized so agents do
+// not retry in lockstep after a backend outage.
+func (q *OutboundQueue) backoff(attempts int) time.Duration {
+	delay := outboundRetryMaxDelay
+	if attempts < 20 {
+		delay = outboundRetryBaseDelay << uint(attempts-1)
+		if delay > outboundRetryMaxDelay {
+			delay = outboundRetryMaxDelay
+		}
+	}
+	return delay/2 + time.Duration(q.random.Int63n(int64(delay/2)+1))
+}
+
+func (q *OutboundQueue) load() error {
+	paths, err := filepath.Glob(filepath.Join(q.options.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		var item models.OutboundItem
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &item)
+		}
+		if err != nil || item.Id == "" {
+			q.logger.Warnf("Dropping unreadable outbound queue entry %s: %v", path, err)
+			os.Remove(path)
+			continue
+		}
+		q.items = append(q.items, &item)
+	}
+	if len(q.items) > 0 {
+		q.logger.Infof("Restored %d undelivered reports from %s", len(q.items), q.options.Dir)
+	}
+	return nil
+}
+
+func (q *OutboundQueue) persist(item *models.OutboundItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	path := q.itemPath(item)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (q *OutboundQueue) remove(index int) {
+	item := q.items[index]
+	q.items = append(q.items[:index], q.items[index+1:]...)
+	if err := os.Remove(q.itemPath(item)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		q.logger.Errorf("Failed to remove outbound item %s: %v", item.Id, err)
+	}
+}
+
+func (q *OutboundQueue) deadLetter(index int, reason string, now time.Time) {
+	item := q.items[index]
+	q.remove(index)
+	q.status.DeadLettered++
+	q.logger.Errorf("Giving up on %s report queued at %s: %s", item.Kind, item.EnqueuedAt.Format(time.RFC3339), reason)
+	data, err := json.Marshal(models.DeadLetter{
+		Item:           *item,
+		Reason:         reason,
+		DeadLetteredAt: now,
+	})
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(q.options.Dir, deadLetterFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		q.logger.Errorf("Failed to open dead-letter file: %v", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		q.logger.Errorf("Failed to write dead-letter file: %v", err)
+	}
+}
+
+func (q *OutboundQueue) indexOf(item *models.OutboundItem) int {
+	for i, queued := range q.items {
+		if queued == item {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *OutboundQueue) itemPath(item *models.OutboundItem) string {
+	return filepath.Join(q.options.Dir, item.Id+".json")
+}
+
+func (q *OutboundQueue) notify() {
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// isRetryableDeliveryError reports whether a delivery may succeed later.
+// Transport errors, server errors, throttling and expired credentials are
+// retried; other client errors mean the backend will never accept the item.
+func isRetryableDeliveryError(err error) bool {
+	var statusErr *BackendStatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	switch code := statusErr.StatusCode; {
+	case code >= 500, code == 408, code == 429, code == 401, code == 403:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package helpers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"signal/agenttest"
+	"signal/models"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestQueue(t *testing.T, dir string, backend *agenttest.FakeBackend) *OutboundQueue {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	queue, err := NewOutboundQueue(OutboundQueueOptions{
+		Dir:         dir,
+		MaxSize:     10,
+		MaxAge:      time.Hour,
+		BatchSize:   5,
+		Compression: CompressionZstd,
+	}, logger)
+	if err != nil {
+		t.Fatalf("failed to create outbound queue: %v", err)
+	}
+	queue.SetTaskPayload(models.TaskPayload{
+		BackendUrl:  backend.URL(),
+		BearerToken: "token",
+		UserId:      "user",
+	})
+	return queue
+}
+
+func testTaskPayload() models.TaskPayload {
+	return models.TaskPayload{UserId: "user"}
+}
+
+func TestOutboundQueueRetriesWhileBackendIsDown(t *testing.T) {
+	backend := agenttest.NewFakeBackend()
+	defer backend.Close()
+	queue := newTestQueue(t, t.TempDir(), backend)
+	backend.FailRequests(http.StatusServiceUnavailable)
+
+	err := queue.EnqueueLogAnalysis(models.LogAnalysisPayload{ContainerName: "/api", Logs: "panic: boom"}, testTaskPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	queue.deliverDue(context.Background(), now)
+	status := queue.Status()
+	if status.QueuedUploads != 1 || status.LastBackendError == "" || status.DeadLettered != 0 {
+		t.Fatalf("expected the report to stay queued after a failed upload, got %+v", status)
+	}
+
+	queue.deliverDue(context.Background(), now)
+	if requests := backend.Requests(); requests != 1 {
+		t.Fatalf("expected no upload before the backoff elapsed, got %d requests", requests)
+	}
+
+	backend.FailRequests(0)
+	queue.deliverDue(context.Background(), now.Add(outboundRetryMaxDelay))
+	if depth := queue.Depth(); depth != 0 {
+		t.Fatalf("expected the queue to be drained, got %d items", depth)
+	}
+	accepted := backend.Accepted()
+	if len(accepted) != 1 || accepted[0].Kind != OutboundKindLogAnalysis {
+		t.Fatalf("expected the log analysis to be delivered, got %+v", accepted)
+	}
+	var payload models.LogAnalysisPayload
+	if err := json.Unmarshal(accepted[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.UserId != "user" || payload.Logs != "panic: boom" {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+	for _, encoding := range backend.ContentEncodings() {
+		if encoding != CompressionZstd {
+			t.Errorf("expected zstd compressed uploads, got %q", encoding)
+		}
+	}
+}
+
+func TestOutboundQueueDeadLettersRejectedItems(t *testing.T) {
+	backend := agenttest.NewFakeBackend()
+	defer backend.Close()
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir, backend)
+	backend.RejectKind(OutboundKindRecovery, http.StatusBadRequest)
+
+	if err := queue.EnqueueLogAnalysis(models.LogAnalysisPayload{ContainerName: "/api"}, testTaskPayload()); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.EnqueueRecovery(models.RecoveryPayload{ContainerName: "/api"}, testTaskPayload()); err != nil {
+		t.Fatal(err)
+	}
+	queue.deliverDue(context.Background(), time.Now())
+
+	status := queue.Status()
+	if status.QueuedUploads != 0 || status.DeadLettered != 1 {
+		t.Fatalf("expected the rejected item to be dead-lettered, got %+v", status)
+	}
+	if accepted := backend.Accepted(); len(accepted) != 1 || accepted[0].Kind != OutboundKindLogAnalysis {
+		t.Errorf("expected the log analysis to be accepted, got %+v", accepted)
+	}
+	deadLetters := readDeadLetters(t, dir)
+	if len(deadLetters) != 1 || deadLetters[0].Item.Kind != OutboundKindRecovery {
+		t.Errorf("expected the recovery in the dead-letter file, got %+v", deadLetters)
+	}
+}
+
+func TestOutboundQueueRestoresUndeliveredItems(t *testing.T) {
+	backend := agenttest.NewFakeBackend()
+	defer backend.Close()
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir, backend)
+	backend.FailRequests(http.StatusBadGateway)
+	for i := 0; i < 3; i++ {
+		if err := queue.EnqueueLogAnalysis(models.LogAnalysisPayload{ContainerName: "/api"}, testTaskPayload()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue.deliverDue(context.Background(), time.Now())
+
+	restarted := newTestQueue(t, dir, backend)
+	if depth := restarted.Depth(); depth != 3 {
+		t.Fatalf("expected 3 restored items, got %d", depth)
+	}
+	backend.FailRequests(0)
+	restarted.deliverDue(context.Background(), time.Now().Add(outboundRetryMaxDelay))
+	if depth := restarted.Depth(); depth != 0 {
+		t.Errorf("expected restored items to be delivered, got %d left", depth)
+	}
+	if accepted := backend.Accepted(); len(accepted) != 3 {
+		t.Errorf("expected 3 delivered items, got %d", len(accepted))
+	}
+}
+
+func TestOutboundQueueExpiresOldItems(t *testing.T) {
+	backend := agenttest.NewFakeBackend()
+	defer backend.Close()
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir, backend)
+	backend.FailRequests(http.StatusInternalServerError)
+	if err := queue.EnqueueLogAnalysis(models.LogAnalysisPayload{ContainerName: "/api"}, testTaskPayload()); err != nil {
+		t.Fatal(err)
+	}
+	queue.deliverDue(context.Background(), time.Now().Add(2*time.Hour))
+
+	if status := queue.Status(); status.QueuedUploads != 0 || status.DeadLettered != 1 {
+		t.Fatalf("expected the expired item to be dead-lettered, got %+v", status)
+	}
+	if requests := backend.Requests(); requests != 0 {
+		t.Errorf("expected expired items not to be uploaded, got %d requests", requests)
+	}
+}
+
+func readDeadLetters(t *testing.T, dir string) []models.DeadLetter {
+	t.Helper()
+	file, err := os.Open(filepath.Join(dir, deadLetterFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	deadLetters := make([]models.DeadLetter, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var deadLetter models.DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &deadLetter); err != nil {
+			t.Fatal(err)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	return deadLetters
+}
+
+func TestOutboundQueueCancelsUploadWithItsContext(t *testing.T) {
+	backend := agenttest.NewFakeBackend()
+	defer backend.Close()
+	queue := newTestQueue(t, t.TempDir(), backend)
+	backend.Delay(time.Minute)
+
+	if err := queue.EnqueueLogAnalysis(models.LogAnalysisPayload{ContainerName: "/api"}, testTaskPayload()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	queue.deliverDue(ctx, started)
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("expected the upload to be cancelled with the context, took %v", elapsed)
+	}
+	status := queue.Status()
+	if status.QueuedUploads != 1 || status.LastBackendError == "" {
+		t.Fatalf("expected the report to stay queued for a retry, got %+v", status)
+	}
+}
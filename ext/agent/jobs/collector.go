@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"signal/models"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	MinScanInterval = 5 * time.Second
+	MaxScanInterval = time.Hour
+)
+
+// Collector runs a scan on an interval while monitoring is switched on.
+// The runtime config is guarded by a mutex, so the control API can start
+// and stop the collector and change credentials or the interval while a
+// scan runs; every scan works on its own copy of the config. Stopping the
+// collector cancels the context of the scan in progress.
+type Collector struct {
+	mutex       sync.Mutex
+	scan        func(context.Context, models.TaskPayload)
+	taskPayload models.TaskPayload
+	cancel      context.CancelFunc
+	done        chan struct{}
+	reconfigure chan struct{}
+	logger      *logrus.Logger
+}
+
+// NewCollector returns a stopped collector. A scan interval out of bounds,
+// from SCAN_INTERVAL or the persisted agent state, is clamped with a warning
+// instead of scanning in a tight loop or hardly at all.
+func NewCollector(scan func(context.Context, models.TaskPayload), taskPayload models.TaskPayload, logger *logrus.Logger) *Collector {
+	if interval := ClampScanInterval(taskPayload.ScanInterval); interval != taskPayload.ScanInterval {
+		logger.Warnf("Scan interval %s is out of bounds, using %s instead", taskPayload.ScanInterval, interval)
+		taskPayload.ScanInterval = interval
+	}
+	return &Collector{
+		scan:        scan,
+		taskPayload: taskPayload,
+		reconfigure: make(chan struct{}, 1),
+		logger:      logger,
+	}
+}
+
+// ClampScanInterval returns the closest interval within MinScanInterval and
+// MaxScanInterval.
+func ClampScanInterval(interval time.Duration) time.Duration {
+	if interval < MinScanInterval {
+		return MinScanInterval
+	}
+	if interval > MaxScanInterval {
+		return MaxScanInterval
+	}
+	return interval
+}
+
+// Start launches the collector loop unless it is already running.
+func (c *Collector) Start() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go c.run(ctx, c.done)
+}
+
+// Stop ends the collector loop, cancelling a scan in progress, and waits
+// until the scan returned.
+func (c *Collector) Stop() {
+	c.mutex.Lock()
+	cancel, done := c.cancel, c.done
+	c.cancel, c.done = nil, nil
+	c.mutex.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (c *Collector) IsRunning() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cancel != nil
+}
+
+func (c *Collector) TaskPayload() models.TaskPayload {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.taskPayload
+}
+
+// SetAuthData replaces the credentials used by the following scans.
+func (c *Collector) SetAuthData(userId string, bearerToken string) models.TaskPayload {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.taskPayload.UserId = userId
+	c.taskPayload.BearerToken = bearerToken
+	return c.taskPayload
+}
+
+// SetScanInterval changes the time between scans, taking effect for the
+// wait in progress.
+func (c *Collector) SetScanInterval(interval time.Duration) error {
+	if interval < MinScanInterval || interval > MaxScanInterval {
+		return fmt.Errorf("scan interval must be between %s and %s", MinScanInterval, MaxScanInterval)
+	}
+	c.mutex.Lock()
+	c.taskPayload.ScanInterval = interval
+	c.mutex.Unlock()
+	select {
+	case c.reconfigure <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *Collector) run(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	for {
+		c.runScan(ctx)
+		if !c.wait(ctx) {
+			return
+		}
+	}
+}
+
+// runScan runs a single scan, recovering from a panic so that one failed
+// scan does not end monitoring. Panics in the goroutines the scan starts are
+// recovered there, like in the workers scanning the containers.
+func (c *Collector) runScan(ctx context.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Errorf("Scan failed: %v\n%s", r, debug.Stack())
+		}
+	}()
+	taskPayload := c.TaskPayload()
+	scanCtx, cancel := withOptionalTimeout(ctx, taskPayload.ScanTimeout)
+	defer cancel()
+	c.scan(scanCtx, taskPayload)
+}
+
+func (c *Collector) wait(ctx context.Context) bool {
+	for {
+		timer := time.NewTimer(c.TaskPayload().ScanInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-c.reconfigure:
+			timer.Stop()
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"signal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCollectorStopCancelsScan(t *testing.T) {
+	started := make(chan struct{}, 1)
+	collector := NewCollector(func(ctx context.Context, taskPayload models.TaskPayload) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+	}, models.TaskPayload{ScanInterval: MinScanInterval}, testLogger())
+
+	collector.Start()
+	<-started
+	stopped := make(chan struct{})
+	go func() {
+		collector.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not cancel the scan in progress")
+	}
+	if collector.IsRunning() {
+		t.Error("expected the collector to be stopped")
+	}
+}
+
+func TestCollectorSurvivesPanickingScan(t *testing.T) {
+	scanned := make(chan struct{}, 1)
+	collector := NewCollector(func(ctx context.Context, taskPayload models.TaskPayload) {
+		scanned <- struct{}{}
+		panic("scan failed")
+	}, models.TaskPayload{ScanInterval: MinScanInterval}, testLogger())
+
+	collector.Start()
+	<-scanned
+	if !collector.IsRunning() {
+		t.Fatal("expected the collector to keep running after a panic")
+	}
+	collector.Stop()
+}
+
+// TestCollectorConcurrentControl drives the collector from several goroutines
+// like concurrent control API requests; run with -race.
+func TestCollectorConcurrentControl(t *testing.T) {
+	collector := NewCollector(func(ctx context.Context, taskPayload models.TaskPayload) {
+		if taskPayload.UserId == "" {
+			panic("scan without user")
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Millisecond):
+		}
+	}, models.TaskPayload{UserId: "user", ScanInterval: MinScanInterval, ScanTimeout: time.Second}, testLogger())
+
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				switch (worker + i) % 5 {
+				case 0:
+					collector.Start()
+				case 1:
+					collector.Stop()
+				case 2:
+					collector.SetAuthData(fmt.Sprintf("user-%d", worker), "token")
+				case 3:
+					if err := collector.SetScanInterval(MinScanInterval + time.Duration(i)*time.Second); err != nil {
+						t.Error(err)
+					}
+				case 4:
+					collector.IsRunning()
+					collector.TaskPayload()
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	collector.Stop()
+	if collector.IsRunning() {
+		t.Error("expected the collector to be stopped")
+	}
+	if err := collector.SetScanInterval(time.Second); err == nil {
+		t.Error("expected an interval below the minimum to be rejected")
+	}
+}
+
+func TestNewCollectorClampsScanInterval(t *testing.T) {
+	scan := func(ctx context.Context, taskPayload models.TaskPayload) {}
+	tests := []struct {
+		interval time.Duration
+		expected time.Duration
+	}{
+		{0, MinScanInterval},
+		{time.Millisecond, MinScanInterval},
+		{30 * time.Second, 30 * time.Second},
+		{24 * time.Hour, MaxScanInterval},
+	}
+	for _, test := range tests {
+		collector := NewCollector(scan, models.TaskPayload{ScanInterval: test.interval}, testLogger())
+		if interval := collector.TaskPayload().ScanInterval; interval != test.expected {
+			t.Errorf("expected %s to be clamped to %s, got %s", test.interval, test.expected, interval)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"context"
+	"signal/helpers"
+	"signal/models"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+// snapshotContainer captures the configuration of a container for its
+// detections. The registry digest is looked up once per image; a failed
+// lookup is not retried unless the scan was cancelled.
+func snapshotContainer(ctx context.Context, dockerClient helpers.DockerClient, container types.ContainerJSON,
+	state *containerScanState, logger *logrus.Logger) *models.ContainerSnapshot {
+	if state.imageId != container.Image {
+		digest, err := helpers.GetImageDigest(ctx, dockerClient, container.Image)
+		if err != nil {
+			logger.Warnf("Failed to look up the digest of image %s: %v", container.Image, err)
+		}
+		if ctx.Err() == nil {
+			state.imageId = container.Image
+			state.imageDigest = digest
+		}
+	}
+	imageDigest := ""
+	if state.imageId == container.Image {
+		imageDigest = state.imageDigest
+	}
+	snapshot := helpers.BuildContainerSnapshot(container, imageDigest)
+	return &snapshot
+}
@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"signal/helpers"
+	"signal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	crashLoopRunLogTail   = 30
+	crashLoopReportedRuns = 10
+)
+
+// detectCrashLoop records the runs of a container that failed since the
+// previous scan and returns a report once the container restarted more than
+// the configured number of times within the crash-loop window. Restarts are
+// tracked across scans, so a container caught while running between two
+// crashes is still detected.
+func detectCrashLoop(ctx context.Context, dockerClient helpers.DockerClient, container types.ContainerJSON, state *containerScanState,
+	taskPayload models.TaskPayload, hasFinishedSinceLastScan bool, since time.Time, until time.Time) (*models.CrashLoopReport, error) {
+	if hasFinishedSinceLastScan {
+		dieEvents, err := helpers.CollectDieEvents(ctx, container.ID, dockerClient, since, until)
+		if err != nil {
+			return nil, err
+		}
+		for _, dieEvent := range dieEvents {
+			exitCode, _ := strconv.Atoi(dieEvent.Actor.Attributes["exitCode"])
+			run := models.ContainerRun{
+				ExitCode:   exitCode,
+				FinishedAt: time.Unix(0, dieEvent.TimeNano),
+			}
+			state.lastExitCode = run.ExitCode
+			state.lastExitedAt = run.FinishedAt
+			// A run that exited with code 0 finished its work, like a job
+			// restarted on schedule, and does not count as a crash.
+			if run.ExitCode == 0 {
+				continue
+			}
+			run.Logs, err = helpers.CollectRunLogs(ctx, container.ID, dockerClient, run.FinishedAt, crashLoopRunLogTail, container.Config.Tty)
+			if err != nil {
+				return nil, err
+			}
+			state.failedRuns = append(state.failedRuns, run)
+		}
+	}
+
+	windowStart := until.Add(-taskPayload.CrashLoopWindow)
+	recentRuns := make([]models.ContainerRun, 0, len(state.failedRuns))
+	for _, run := range state.failedRuns {
+		if run.FinishedAt.After(windowStart) {
+			recentRuns = append(recentRuns, run)
+		}
+	}
+	state.failedRuns = recentRuns
+
+	if len(state.failedRuns) <= taskPayload.CrashLoopRestartThreshold || isInCrashLoopCooldown(state, taskPayload, until) {
+		return nil, nil
+	}
+
+	reportedRuns := state.failedRuns
+	if len(reportedRuns) > crashLoopReportedRuns {
+		reportedRuns = reportedRuns[len(reportedRuns)-crashLoopReportedRuns:]
+	}
+	report := &models.CrashLoopReport{
+		Restarts:          len(state.failedRuns),
+		WindowSeconds:     int(taskPayload.CrashLoopWindow.Seconds()),
+		TotalRestartCount: container.RestartCount,
+		StartedAt:         container.State.StartedAt,
+		FinishedAt:        container.State.FinishedAt,
+		Runs:              reportedRuns,
+	}
+	state.crashLoopReportedAt = until
+	state.failedRuns = make([]models.ContainerRun, 0)
+	return report, nil
+}
+
+// isInCrashLoopCooldown reports whether a crash loop was already reported for
+// the container within the window and it has kept restarting since, in which
+// case further detections would only duplicate the crash-loop issue.
+func isInCrashLoopCooldown(state *containerScanState, taskPayload models.TaskPayload, now time.Time) bool {
+	return !state.crashLoopReportedAt.IsZero() &&
+		now.Sub(state.crashLoopReportedAt) < taskPayload.CrashLoopWindow &&
+		len(state.failedRuns) > 0
+}
+
+func formatCrashLoopLogs(report *models.CrashLoopReport) string {
+	lines := []string{
+		fmt.Sprintf("Container restarted %d times within %d seconds", report.Restarts, report.WindowSeconds),
+	}
+	for _, run := range report.Runs {
+		lines = append(lines,
+			fmt.Sprintf("--- Run finished at %s with exit code %d ---", run.FinishedAt.Format(time.RFC3339), run.ExitCode),
+			strings.TrimRight(run.Logs, "\n"),
+		)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// crash makes the container log a line, exit and get restarted.
+func (f *scanFixture) crash(id string, exitCode int, line string) {
+	f.engine.Log(id, time.Now(), line)
+	time.Sleep(time.Millisecond)
+	f.engine.Exit(id, exitCode, time.Now())
+	time.Sleep(time.Millisecond)
+	f.engine.Restart(id, time.Now())
+	time.Sleep(time.Millisecond)
+}
+
+func TestScanReportsCrashLoop(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	f.scan()
+
+	for i := 0; i < 4; i++ {
+		f.crash("c1", 2, "listen tcp :8080: bind: address already in use")
+	}
+	f.scan()
+	reports := f.reports(t)
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	crashLoop := reports[0].CrashLoop
+	if crashLoop == nil {
+		t.Fatalf("expected a crash-loop report, got %+v", reports[0])
+	}
+	if crashLoop.Restarts != 4 || len(crashLoop.Runs) != 4 || crashLoop.TotalRestartCount != 4 {
+		t.Errorf("expected 4 restarts and runs, got %d restarts and %d runs", crashLoop.Restarts, len(crashLoop.Runs))
+	}
+	for _, run := range crashLoop.Runs {
+		if run.ExitCode != 2 || !strings.Contains(run.Logs, "address already in use") {
+			t.Errorf("unexpected run %+v", run)
+		}
+	}
+
+	f.crash("c1", 2, "listen tcp :8080: bind: address already in use")
+	f.scan()
+	if reports := f.reports(t); len(reports) != 1 {
+		t.Errorf("expected further crashes within the window to be suppressed, got %d reports", len(reports))
+	}
+}
+
+func TestScanTracksRestartsAcrossScans(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	f.scan()
+
+	for i := 0; i < 4; i++ {
+		f.crash("c1", 1, "starting server")
+		f.scan()
+	}
+	reports := f.reports(t)
+	if len(reports) == 0 || reports[len(reports)-1].CrashLoop == nil {
+		t.Fatalf("expected the last report to be a crash loop, got %+v", reports)
+	}
+	for _, report := range reports[:len(reports)-1] {
+		if report.CrashLoop != nil {
+			t.Errorf("expected a single crash-loop report, got %+v", reports)
+		}
+	}
+	if restarts := reports[len(reports)-1].CrashLoop.Restarts; restarts != 4 {
+		t.Errorf("expected 4 restarts, got %d", restarts)
+	}
+}
+
+func TestScanIgnoresCleanExitsForCrashLoop(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "job", nil)
+	f.scan()
+
+	for i := 0; i < 4; i++ {
+		f.crash("c1", 0, "batch finished")
+	}
+	f.scan()
+	for _, report := range f.reports(t) {
+		if report.CrashLoop != nil {
+			t.Fatalf("expected clean exits not to be a crash loop, got %+v", report.CrashLoop)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		f.crash("c1", 1, "batch failed")
+	}
+	f.scan()
+	reports := f.reports(t)
+	if len(reports) == 0 || reports[len(reports)-1].CrashLoop == nil {
+		t.Fatalf("expected failed runs to still be a crash loop, got %+v", reports)
+	}
+	crashLoop := reports[len(reports)-1].CrashLoop
+	if crashLoop.Restarts != 4 {
+		t.Errorf("expected only the 4 failed runs to count, got %d", crashLoop.Restarts)
+	}
+	for _, run := range crashLoop.Runs {
+		if run.ExitCode == 0 {
+			t.Errorf("expected clean runs to be left out, got %+v", run)
+		}
+	}
+}
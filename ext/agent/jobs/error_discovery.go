@@ -2,7 +2,10 @@ package jobs
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"regexp"
+	"runtime/debug"
 	"signal/helpers"
 	"signal/models"
 	"strings"
@@ -10,55 +13,346 @@ import (
 	"time"
 
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
 )
 
-func ScanForErrors(dockerClient *client.Client, logger *logrus.Logger, taskPayload models.TaskPayload) {
-	containers, err := helpers.ListContainers(dockerClient)
+const logEventContextSize = 10
+
+const logContextBufferSize = 1000
+
+const execTimeOffsetInSeconds = 5
+
+// containerScanState is the per-container state kept between scans.
+type containerScanState struct {
+	aggregator   *helpers.LogEventAggregator
+	logContext   *helpers.LogContextBuffer
+	lastScanTime time.Time
+
+	lastFinishedAt      string
+	lastMemoryUsage     uint64
+	failedRuns          []models.ContainerRun
+	crashLoopReportedAt time.Time
+	unhealthyReported   bool
+	lastExitCode        int
+	lastExitedAt        time.Time
+	awaitingStableRun   bool
+	container           types.Container
+	imageId             string
+	imageDigest         string
+	resources           *resourceBaseline
+	logRate             *logRateBaseline
+}
+
+var (
+	containerStatesMutex sync.Mutex
+	containerStates      = make(map[string]*containerScanState)
+)
+
+// ScanForErrors scans the watched containers with a bounded number of
+// workers. Each container is scanned under its own deadline within the
+// deadline of the scan; containers left when the scan is cancelled or runs
+// out of time are skipped until the next scan.
+func ScanForErrors(ctx context.Context, dockerClient helpers.DockerClient, logger *logrus.Logger, outboundQueue *helpers.OutboundQueue, taskPayload models.TaskPayload) {
+	scanStartedAt := time.Now()
+	containers, err := helpers.ListContainers(ctx, dockerClient)
 	if err != nil {
 		logger.Errorf("Failed to list containers: %v", err)
 		return
 	}
+	for _, state := range pruneContainerStates(containers) {
+		flushPendingTrace(outboundQueue, state, taskPayload, logger)
+	}
+	refreshMuteRules(ctx, taskPayload, logger, scanStartedAt)
 	wg := sync.WaitGroup{}
+	workers := make(chan struct{}, maxInt(taskPayload.ScanWorkers, 1))
+	containersWatched := 0
+	containersSkipped := 0
 	for _, c := range containers {
+		settings, err := helpers.GetContainerScanSettings(c.Labels, taskPayload.ScanMode)
+		if err != nil {
+			logger.Warnf("Container %s: %v", c.Names[0], err)
+		}
+		if !settings.Enabled {
+			continue
+		}
+		containersWatched++
+		state := getContainerScanState(c.ID)
+		state.container = c
+		if !state.lastScanTime.IsZero() && time.Since(state.lastScanTime) < settings.ScanInterval {
+			continue
+		}
+		acquired := false
+		select {
+		case workers <- struct{}{}:
+			acquired = true
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			// A slot taken while the scan was cancelled is released, so it is
+			// not held by a container that is skipped.
+			if acquired {
+				<-workers
+			}
+			containersSkipped++
+			continue
+		}
+		scanTime := time.Now()
+		timeTail := scanTime.Add(-(taskPayload.ScanInterval + execTimeOffsetInSeconds*time.Second))
+		if !state.lastScanTime.IsZero() {
+			timeTail = state.lastScanTime
+		}
+		state.lastScanTime = scanTime
+		recordContainerScan(c.ID, c.Names[0], scanTime)
 		wg.Add(1)
-		go func(dockerClient *client.Client,
+		go func(dockerClient helpers.DockerClient,
 			c types.Container, l *logrus.Logger,
-			wg *sync.WaitGroup, taskPayload models.TaskPayload) {
+			wg *sync.WaitGroup, taskPayload models.TaskPayload,
+			state *containerScanState, settings models.ContainerScanSettings, timeTail time.Time, scanTime time.Time) {
 			isErrorState := false
-			execTimeOffsetInSeconds := -5
-			timeTail := time.Now().Add(time.Duration(-15 + execTimeOffsetInSeconds)).Format(time.RFC3339)
+			// windowConsumed tells whether the logs since timeTail were read,
+			// or deliberately skipped while a crash loop is reported.
+			windowConsumed := false
+			containerCtx, cancel := withOptionalTimeout(ctx, taskPayload.ContainerScanTimeout)
+			defer func() {
+				<-workers
+			}()
 			defer wg.Done()
-			l.Infof("Authorization: Bearer %s \n", taskPayload.BearerToken)
-			container, err := dockerClient.ContainerInspect(context.Background(), c.ID)
+			defer cancel()
+			defer func() {
+				// A scan that failed or was interrupted before the logs were
+				// read leaves the window to the next scan instead of losing it.
+				if !windowConsumed {
+					state.lastScanTime = timeTail
+				}
+				recordContainerScanDuration(c.ID, time.Since(scanTime), errors.Is(containerCtx.Err(), context.DeadlineExceeded),
+					taskPayload.ContainerScanTimeout, l)
+			}()
+			// A panic ends the scan of this container only; the collector
+			// cannot recover panics of the workers.
+			defer func() {
+				if r := recover(); r != nil {
+					l.Errorf("Scan of container %s failed: %v\n%s", c.Names[0], r, debug.Stack())
+				}
+			}()
+			container, err := dockerClient.ContainerInspect(containerCtx, c.ID)
 			if err != nil {
 				l.Errorf("Failed to inspect container %s: %v", c.ID, err)
 				return
 			}
-			logs, err := helpers.CollectLogsForAnalysis(c.ID, dockerClient, timeTail)
+			containerSnapshot := snapshotContainer(containerCtx, dockerClient, container, state, l)
+			anomalies := make([]*models.AnomalyReport, 0)
+			if container.State.Running {
+				stats, err := helpers.CollectContainerStats(containerCtx, c.ID, dockerClient)
+				if err != nil {
+					l.Warnf("Failed to collect stats for container %s: %v", c.Names[0], err)
+				} else {
+					state.lastMemoryUsage = helpers.GetMemoryUsage(stats)
+					state.resources.addSample(stats, container, scanTime)
+					anomalies = state.resources.detectAnomalies(scanTime)
+				}
+			}
+			hasFinishedSinceLastScan := hasContainerFinishedSince(container.State, state.lastFinishedAt, timeTail)
+			state.lastFinishedAt = container.State.FinishedAt
+			crashLoopReport, err := detectCrashLoop(containerCtx, dockerClient, container, state, taskPayload, hasFinishedSinceLastScan, timeTail, scanTime)
+			if err != nil {
+				l.Errorf("Failed to check container %s for a crash loop: %v", c.Names[0], err)
+			}
+			if crashLoopReport != nil {
+				err := reportLogAnalysis(outboundQueue, c, models.LogAnalysisPayload{
+					ContainerName:     c.Names[0],
+					IssueType:         models.IssueTypeError,
+					Logs:              formatCrashLoopLogs(crashLoopReport),
+					ParsedLogs:        make([]models.ParsedLogLine, 0),
+					CrashLoop:         crashLoopReport,
+					Termination:       buildTerminationReport(container, state.lastMemoryUsage),
+					ContainerSnapshot: containerSnapshot,
+				}, taskPayload)
+				if err != nil {
+					l.Errorf("Failed to queue log analysis for container %s: %v", c.Names[0], err)
+				}
+				state.awaitingStableRun = true
+				windowConsumed = true
+				return
+			}
+			if isInCrashLoopCooldown(state, taskPayload, scanTime) {
+				windowConsumed = true
+				return
+			}
+			healthReport, recovered := checkContainerHealth(container, state)
+			recoveries := detectRecovery(container, state, taskPayload, timeTail, scanTime)
+			if recovered {
+				recoveries = append(recoveries, models.RecoveryPayload{Reason: models.RecoveryReasonHealthy})
+			}
+			for _, recovery := range recoveries {
+				err := reportRecovery(outboundQueue, c, recovery, taskPayload)
+				if err != nil {
+					l.Errorf("Failed to queue recovery of container %s: %v", c.Names[0], err)
+				}
+			}
+			rawLogs, err := helpers.CollectLogsForAnalysis(containerCtx, c.ID, dockerClient, formatLogTimeTail(timeTail), container.Config.Tty)
 			if err != nil {
 				l.Errorf("Failed to collect logs for container %s: %v", c.ID, err)
+			} else {
+				windowConsumed = true
+			}
+			timestampedLines := helpers.SplitTimestampedLogLines(rawLogs)
+			logLines := helpers.LogContextText(timestampedLines)
+			if container.State.Running && err == nil {
+				if anomaly := state.logRate.observe(len(logLines), scanTime.Sub(timeTail), scanTime); anomaly != nil {
+					anomalies = append(anomalies, anomaly)
+				}
+			}
+			pendingLines := state.aggregator.PendingLines()
+			events := filterIgnoredEvents(state.aggregator.Push(logLines), settings.IgnorePatterns)
+			state.logContext.Push(timestampedLines)
+			parsedLogs := make([]models.ParsedLogLine, 0, len(events))
+			for _, e := range events {
+				parsedLogs = append(parsedLogs, helpers.ParseLogEvent(e))
+			}
+			triggeringEvents := getTriggeringEvents(events, parsedLogs, settings.SeverityFloor)
+			analysisPayload := models.LogAnalysisPayload{
+				ContainerName: c.Names[0],
+				IssueType:     models.IssueTypeError,
+				Logs:          helpers.JoinLogEvents(events),
+				ParsedLogs:    parsedLogs,
+				LogContext: state.logContext.Window(getTriggeringLines(triggeringEvents), len(timestampedLines)+pendingLines,
+					taskPayload.LogContextLinesBefore, taskPayload.LogContextTimeBefore, taskPayload.LogContextLinesAfter),
+				ContainerSnapshot: containerSnapshot,
+			}
+			for _, anomaly := range anomalies {
+				anomalyPayload := analysisPayload
+				anomalyPayload.IssueType = models.IssueTypeAnomaly
+				anomalyPayload.Anomaly = anomaly
+				err := reportLogAnalysis(outboundQueue, c, anomalyPayload, taskPayload)
+				if err != nil {
+					l.Errorf("Failed to queue log analysis for container %s: %v", c.Names[0], err)
+				}
+			}
+			if healthReport != nil {
+				analysisPayload.Health = healthReport
+				analysisPayload.Logs = strings.TrimRight(formatHealthLogs(healthReport)+"\n"+analysisPayload.Logs, "\n")
+				err := reportLogAnalysis(outboundQueue, c, analysisPayload, taskPayload)
+				if err != nil {
+					l.Errorf("Failed to queue log analysis for container %s: %v", c.Names[0], err)
+				}
+				return
 			}
 			isErrorState = isContainerInErrorState(container.State)
-			if isErrorState && logs != "" {
-				err := helpers.CallLogAnalysis(logs, c.Names[0], taskPayload)
+			if isErrorState && (analysisPayload.Logs != "" || hasFinishedSinceLastScan) {
+				analysisPayload.Termination = buildTerminationReport(container, state.lastMemoryUsage)
+				err := reportLogAnalysis(outboundQueue, c, analysisPayload, taskPayload)
 				if err != nil {
-					l.Errorf("Failed to call log analysis for container %s: %v", c.Names[0], err)
+					l.Errorf("Failed to queue log analysis for container %s: %v", c.Names[0], err)
 				}
+				state.awaitingStableRun = true
 				return
 			}
-			isErrorState = areLogsIndicatingErrorOrWarning(logs)
+			isErrorState = len(triggeringEvents) > 0
 			if isErrorState {
-				err := helpers.CallLogAnalysis(logs, c.Names[0], taskPayload)
+				err := reportLogAnalysis(outboundQueue, c, analysisPayload, taskPayload)
 				if err != nil {
-					l.Errorf("Failed to call log analysis for container %s: %v", c.Names[0], err)
+					l.Errorf("Failed to queue log analysis for container %s: %v", c.Names[0], err)
 				}
 			}
-		}(dockerClient, c, logger, &wg, taskPayload)
+		}(dockerClient, c, logger, &wg, taskPayload, state, settings, timeTail, scanTime)
 	}
 
 	wg.Wait()
+	if containersSkipped > 0 {
+		logger.Warnf("Scan ended before %d containers were scanned: %v", containersSkipped, ctx.Err())
+	}
+	recordScan(scanStartedAt, time.Since(scanStartedAt), containersWatched, containersSkipped)
+}
+
+func getContainerScanState(containerID string) *containerScanState {
+	containerStatesMutex.Lock()
+	defer containerStatesMutex.Unlock()
+	state, exists := containerStates[containerID]
+	if !exists {
+		state = &containerScanState{
+			aggregator: helpers.NewLogEventAggregator(logEventContextSize),
+			logContext: helpers.NewLogContextBuffer(logContextBufferSize),
+			failedRuns: make([]models.ContainerRun, 0),
+			resources:  newResourceBaseline(),
+			logRate:    newLogRateBaseline(),
+		}
+		containerStates[containerID] = state
+	}
+	return state
+}
+
+// pruneContainerStates drops the state of containers that no longer exist
+// and returns it.
+func pruneContainerStates(containers []types.Container) []*containerScanState {
+	current := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		current[c.ID] = true
+	}
+	containerStatesMutex.Lock()
+	defer containerStatesMutex.Unlock()
+	removed := make([]*containerScanState, 0)
+	for containerID, state := range containerStates {
+		if !current[containerID] {
+			removed = append(removed, state)
+			delete(containerStates, containerID)
+		}
+	}
+	pruneScanStatus(current)
+	return removed
+}
+
+// flushPendingTrace reports the stack trace a removed container was still
+// writing when it was last scanned, which no later scan would complete.
+func flushPendingTrace(outboundQueue *helpers.OutboundQueue, state *containerScanState, taskPayload models.TaskPayload, logger *logrus.Logger) {
+	c := state.container
+	if state.aggregator.PendingLines() == 0 || len(c.Names) == 0 {
+		return
+	}
+	settings, _ := helpers.GetContainerScanSettings(c.Labels, taskPayload.ScanMode)
+	events := filterIgnoredEvents(state.aggregator.Push(nil), settings.IgnorePatterns)
+	parsedLogs := make([]models.ParsedLogLine, 0, len(events))
+	for _, e := range events {
+		parsedLogs = append(parsedLogs, helpers.ParseLogEvent(e))
+	}
+	if len(getTriggeringEvents(events, parsedLogs, settings.SeverityFloor)) == 0 {
+		return
+	}
+	err := reportLogAnalysis(outboundQueue, c, models.LogAnalysisPayload{
+		ContainerName: c.Names[0],
+		IssueType:     models.IssueTypeError,
+		Logs:          helpers.JoinLogEvents(events),
+		ParsedLogs:    parsedLogs,
+	}, taskPayload)
+	if err != nil {
+		logger.Errorf("Failed to queue log analysis for removed container %s: %v", c.Names[0], err)
+	}
+}
+
+// formatLogTimeTail renders a timestamp with sub-second precision so that
+// consecutive scan windows neither overlap nor leave gaps.
+func formatLogTimeTail(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+func filterIgnoredEvents(events []models.LogEvent, ignorePatterns []*regexp.Regexp) []models.LogEvent {
+	if len(ignorePatterns) == 0 {
+		return events
+	}
+	filteredEvents := make([]models.LogEvent, 0, len(events))
+	for _, e := range events {
+		text := strings.Join(e.Lines, "\n")
+		ignored := false
+		for _, pattern := range ignorePatterns {
+			if pattern.MatchString(text) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			filteredEvents = append(filteredEvents, e)
+		}
+	}
+	return filteredEvents
 }
 
 func isContainerInErrorState(state *types.ContainerState) bool {
@@ -66,11 +360,52 @@ func isContainerInErrorState(state *types.ContainerState) bool {
 		(!state.Running && state.ExitCode != 0))
 }
 
-func areLogsIndicatingErrorOrWarning(logs string) bool {
-	regexWarningError := `(?i)(abort|blocked|corrupt|crash|critical|deadlock|denied|
-		err|error|exception|fatal|forbidden|freeze|hang|illegal|invalid|issue|missing|
-		panic|rejected|refused|stacktrace|timeout|traceback|unauthorized|uncaught|unexpected|unhandled|
-		unimplemented|unsupported|warn|warning)`
-	matched, _ := regexp.MatchString(regexWarningError, strings.ToLower(logs))
-	return matched
+// hasContainerFinishedSince reports whether the container exited after the
+// given time and the exit was not seen by a previous scan.
+func hasContainerFinishedSince(state *types.ContainerState, lastFinishedAt string, since time.Time) bool {
+	if state.FinishedAt == lastFinishedAt {
+		return false
+	}
+	finishedAt, err := time.Parse(time.RFC3339Nano, state.FinishedAt)
+	return err == nil && finishedAt.After(since)
+}
+
+// getTriggeringEvents returns the events indicating a problem at or above the
+// severity floor of the container.
+func getTriggeringEvents(events []models.LogEvent, parsedLogs []models.ParsedLogLine, severityFloor string) []models.LogEvent {
+	triggeringEvents := make([]models.LogEvent, 0)
+	for i, e := range events {
+		level := getEventProblemLevel(e, parsedLogs[i])
+		if level != "" && helpers.LogLevelRank(level) >= helpers.LogLevelRank(severityFloor) {
+			triggeringEvents = append(triggeringEvents, e)
+		}
+	}
+	return triggeringEvents
+}
+
+func getTriggeringLines(events []models.LogEvent) map[string]bool {
+	lines := make(map[string]bool)
+	for _, e := range events {
+		for _, line := range e.Lines {
+			lines[line] = true
+		}
+	}
+	return lines
+}
+
+// getEventProblemLevel returns the level of an event that indicates a problem
+// or an empty string otherwise. The level reported by structured logs is
+// preferred over keyword matching, which would otherwise fire on field names.
+func getEventProblemLevel(e models.LogEvent, parsed models.ParsedLogLine) string {
+	if e.IsStackTrace {
+		return helpers.LogLevelError
+	}
+	return helpers.LogLineProblemLevel(parsed)
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
 }
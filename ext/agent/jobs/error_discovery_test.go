@@ -0,0 +1,365 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"signal/agenttest"
+	"signal/helpers"
+	"signal/models"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+// scanFixture runs scans against a fake engine and reads the reports they
+// queued from the outbound queue directory.
+type scanFixture struct {
+	engine      *agenttest.FakeEngine
+	queue       *helpers.OutboundQueue
+	queueDir    string
+	taskPayload models.TaskPayload
+	logger      *logrus.Logger
+}
+
+func newScanFixture(t *testing.T) *scanFixture {
+	t.Helper()
+	resetScanState()
+	t.Cleanup(resetScanState)
+	logger := testLogger()
+	queueDir := t.TempDir()
+	queue, err := helpers.NewOutboundQueue(helpers.OutboundQueueOptions{
+		Dir:         queueDir,
+		MaxSize:     100,
+		Compression: helpers.CompressionNone,
+	}, logger)
+	if err != nil {
+		t.Fatalf("failed to create outbound queue: %v", err)
+	}
+	return &scanFixture{
+		engine:   agenttest.NewFakeEngine(),
+		queue:    queue,
+		queueDir: queueDir,
+		taskPayload: models.TaskPayload{
+			UserId:                    "user",
+			ScanMode:                  helpers.ScanModeOptOut,
+			ScanInterval:              15 * time.Second,
+			ContainerScanTimeout:      time.Second,
+			ScanWorkers:               2,
+			CrashLoopRestartThreshold: 3,
+			CrashLoopWindow:           5 * time.Minute,
+			RecoveryStabilityWindow:   time.Minute,
+			LogContextLinesBefore:     2,
+			LogContextTimeBefore:      time.Minute,
+			LogContextLinesAfter:      1,
+		},
+		logger: logger,
+	}
+}
+
+func (f *scanFixture) scan() {
+	ScanForErrors(context.Background(), f.engine, f.logger, f.queue, f.taskPayload)
+}
+
+// reports returns the queued log analysis reports in the order they were
+// queued.
+func (f *scanFixture) reports(t *testing.T) []models.LogAnalysisPayload {
+	t.Helper()
+	reports := make([]models.LogAnalysisPayload, 0)
+	for _, item := range f.queued(t, helpers.OutboundKindLogAnalysis) {
+		var payload models.LogAnalysisPayload
+		if err := json.Unmarshal(item.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		reports = append(reports, payload)
+	}
+	return reports
+}
+
+// queued returns the items of the kind in the outbound queue directory in
+// the order they were queued.
+func (f *scanFixture) queued(t *testing.T, kind string) []models.OutboundItem {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(f.queueDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	items := make([]models.OutboundItem, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var item models.OutboundItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			t.Fatal(err)
+		}
+		if item.Kind == kind {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func resetScanState() {
+	containerStatesMutex.Lock()
+	containerStates = make(map[string]*containerScanState)
+	containerStatesMutex.Unlock()
+	scanStatus.mutex.Lock()
+	scanStatus.lastScanAt = time.Time{}
+	scanStatus.lastScanDuration = 0
+	scanStatus.containersWatched = 0
+	scanStatus.containersSkipped = 0
+	scanStatus.containers = make(map[string]*models.ContainerStatus)
+	scanStatus.mutex.Unlock()
+	muteRules = helpers.NewMuteRuleSet()
+}
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestScanReportsContainerExitedWithError(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	f.scan()
+	if reports := f.reports(t); len(reports) != 0 {
+		t.Fatalf("expected no reports for a running container, got %d", len(reports))
+	}
+
+	f.engine.Log("c1", time.Now(), "loading configuration")
+	f.engine.Exit("c1", 1, time.Now())
+	f.scan()
+	reports := f.reports(t)
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	report := reports[0]
+	if report.ContainerName != "/api" || report.UserId != "user" {
+		t.Errorf("unexpected report for %q of %q", report.ContainerName, report.UserId)
+	}
+	if report.Termination == nil || report.Termination.ExitCode != 1 {
+		t.Fatalf("expected a termination report with exit code 1, got %+v", report.Termination)
+	}
+	if !strings.Contains(report.Logs, "loading configuration") {
+		t.Errorf("expected the logs before the exit to be reported, got %q", report.Logs)
+	}
+
+	f.scan()
+	if reports := f.reports(t); len(reports) != 1 {
+		t.Errorf("expected the exit to be reported once, got %d reports", len(reports))
+	}
+}
+
+func TestScanReportsErrorKeywords(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "shop-api-2", map[string]string{
+		helpers.LabelComposeProject:         "shop",
+		helpers.LabelComposeService:         "api",
+		helpers.LabelComposeContainerNumber: "2",
+	})
+	f.engine.AddContainer("c2", "worker", nil)
+	f.engine.AddContainer("c3", "proxy", map[string]string{helpers.LabelIgnorePatterns: "upstream timed out"})
+	f.engine.Update("c2", func(c *agenttest.FakeContainer) {
+		c.Tty = true
+	})
+	now := time.Now()
+	f.engine.Log("c1", now, "GET /health 200")
+	f.engine.LogStderr("c1", now, "ERROR failed to connect to database: connection refused")
+	f.engine.Log("c2", now, "processed 12 jobs", "processed 7 jobs")
+	f.engine.LogStderr("c3", now, "upstream timed out while reading response header")
+	f.scan()
+
+	reports := f.reports(t)
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	if reports[0].ContainerName != "/shop-api-2" || !strings.Contains(reports[0].Logs, "connection refused") {
+		t.Errorf("expected the database error of /shop-api-2 to be reported, got %+v", reports[0])
+	}
+	if reports[0].ComposeProject != "shop" || reports[0].ComposeService != "api" || reports[0].ComposeContainerNumber != 2 {
+		t.Errorf("expected the Compose labels to be reported, got %+v", reports[0])
+	}
+	if reports[0].Labels[helpers.LabelComposeService] != "api" {
+		t.Errorf("expected the container labels to be sent for routing, got %v", reports[0].Labels)
+	}
+	if reports[0].Termination != nil {
+		t.Errorf("expected no termination report for a running container")
+	}
+
+	f.scan()
+	if reports := f.reports(t); len(reports) != 1 {
+		t.Errorf("expected log lines to be reported once, got %d reports", len(reports))
+	}
+}
+
+func TestScanReportsLogContextBeforeError(t *testing.T) {
+	f := newScanFixture(t)
+	f.taskPayload.LogContextTimeBefore = 0
+	f.engine.AddContainer("c1", "api", nil)
+	f.engine.Log("c1", time.Now(), "starting worker", "connecting to redis", "redis connection established")
+	f.scan()
+	time.Sleep(time.Millisecond)
+
+	f.engine.Log("c1", time.Now(), "ERROR lost connection to redis", "retrying in 5s")
+	f.scan()
+	reports := f.reports(t)
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	logContext := reports[0].LogContext
+	expected := []string{"connecting to redis", "redis connection established", "ERROR lost connection to redis", "retrying in 5s"}
+	if len(logContext) != len(expected) {
+		t.Fatalf("expected %d context lines, got %+v", len(expected), logContext)
+	}
+	for i, line := range logContext {
+		if line.Line != expected[i] || line.IsTrigger != (i == 2) || line.Timestamp.IsZero() {
+			t.Errorf("unexpected context line %d: %+v", i, line)
+		}
+	}
+}
+
+func TestScanSkipsContainersNotOptedIn(t *testing.T) {
+	f := newScanFixture(t)
+	f.taskPayload.ScanMode = helpers.ScanModeOptIn
+	f.engine.AddContainer("c1", "api", nil)
+	f.engine.AddContainer("c2", "worker", map[string]string{helpers.LabelEnable: "true"})
+	now := time.Now()
+	f.engine.Log("c1", now, "fatal: out of disk space")
+	f.engine.Log("c2", now, "fatal: out of disk space")
+	f.scan()
+
+	reports := f.reports(t)
+	if len(reports) != 1 || reports[0].ContainerName != "/worker" {
+		t.Fatalf("expected only /worker to be reported, got %+v", reports)
+	}
+	if status := GetScanStatus(); status.ContainersWatched != 1 {
+		t.Errorf("expected 1 watched container, got %d", status.ContainersWatched)
+	}
+}
+
+func TestScanRetriesWindowOfTimedOutContainer(t *testing.T) {
+	f := newScanFixture(t)
+	f.taskPayload.ContainerScanTimeout = 50 * time.Millisecond
+	f.engine.AddContainer("c1", "api", nil)
+	f.engine.Log("c1", time.Now(), "ERROR failed to flush cache: disk quota exceeded")
+	f.engine.Hang(agenttest.MethodContainerLogs, true)
+	f.scan()
+
+	if reports := f.reports(t); len(reports) != 0 {
+		t.Fatalf("expected no reports from a timed out scan, got %d", len(reports))
+	}
+	status := GetScanStatus()
+	if len(status.Containers) != 1 || status.Containers[0].TimedOutScans != 1 {
+		t.Fatalf("expected the scan of /api to be recorded as timed out, got %+v", status.Containers)
+	}
+
+	f.engine.Hang(agenttest.MethodContainerLogs, false)
+	f.scan()
+	reports := f.reports(t)
+	if len(reports) != 1 || !strings.Contains(reports[0].Logs, "disk quota exceeded") {
+		t.Fatalf("expected the lines of the timed out window to be reported, got %+v", reports)
+	}
+}
+
+func TestScanKeepsGoingWhenInspectFails(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	f.engine.Fail(agenttest.MethodContainerInspect, context.DeadlineExceeded)
+	f.scan()
+	if reports := f.reports(t); len(reports) != 0 {
+		t.Fatalf("expected no reports, got %d", len(reports))
+	}
+	if calls := f.engine.Calls(agenttest.MethodContainerLogs); calls != 0 {
+		t.Errorf("expected no logs to be read after a failed inspect, got %d calls", calls)
+	}
+}
+
+func TestScanRetriesWindowAfterFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+	}{
+		{"inspect", agenttest.MethodContainerInspect},
+		{"logs", agenttest.MethodContainerLogs},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newScanFixture(t)
+			f.engine.AddContainer("c1", "api", nil)
+			f.engine.Log("c1", time.Now(), "ERROR failed to flush cache: disk quota exceeded")
+			f.engine.Fail(test.method, errors.New("connection reset by peer"))
+			f.scan()
+			if reports := f.reports(t); len(reports) != 0 {
+				t.Fatalf("expected no reports from a failed scan, got %d", len(reports))
+			}
+
+			f.engine.Fail(test.method, nil)
+			f.scan()
+			reports := f.reports(t)
+			if len(reports) != 1 || !strings.Contains(reports[0].Logs, "disk quota exceeded") {
+				t.Fatalf("expected the lines of the failed window to be reported, got %+v", reports)
+			}
+		})
+	}
+}
+
+// panickingEngine panics while reading the logs of one container.
+type panickingEngine struct {
+	*agenttest.FakeEngine
+	containerID string
+}
+
+func (e *panickingEngine) ContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	if containerID == e.containerID {
+		panic("unexpected log frame")
+	}
+	return e.FakeEngine.ContainerLogs(ctx, containerID, options)
+}
+
+func TestScanKeepsGoingWhenContainerScanPanics(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	f.engine.AddContainer("c2", "worker", nil)
+	f.engine.Log("c1", time.Now(), "ERROR failed to flush cache: disk quota exceeded")
+	f.engine.Log("c2", time.Now(), "ERROR failed to send mail: connection refused")
+
+	ScanForErrors(context.Background(), &panickingEngine{FakeEngine: f.engine, containerID: "c1"}, f.logger, f.queue, f.taskPayload)
+	reports := f.reports(t)
+	if len(reports) != 1 || reports[0].ContainerName != "/worker" {
+		t.Fatalf("expected the other container to be reported, got %+v", reports)
+	}
+
+	f.scan()
+	reports = f.reports(t)
+	if len(reports) != 2 || !strings.Contains(reports[1].Logs, "disk quota exceeded") {
+		t.Fatalf("expected the lines of the panicked scan to be reported, got %+v", reports)
+	}
+}
+
+func TestScanReportsPendingTraceOfRemovedContainer(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	f.engine.Log("c1", time.Now(), "panic: runtime error: index out of range [3] with length 3", "", "goroutine 1 [running]:")
+	f.scan()
+	if reports := f.reports(t); len(reports) != 0 {
+		t.Fatalf("expected the trace to be held back for the next scan, got %+v", reports)
+	}
+
+	f.engine.RemoveContainer("c1")
+	f.scan()
+	reports := f.reports(t)
+	if len(reports) != 1 || reports[0].ContainerName != "/api" || !strings.Contains(reports[0].Logs, "index out of range") {
+		t.Fatalf("expected the pending trace of the removed container to be reported, got %+v", reports)
+	}
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"fmt"
+	"signal/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// checkContainerHealth compares the healthcheck status of a container with
+// the one seen by previous scans. It returns a report when the container has
+// just become unhealthy and reports whether it has become healthy again after
+// such a report. Containers without a healthcheck are ignored.
+func checkContainerHealth(container types.ContainerJSON, state *containerScanState) (*models.HealthReport, bool) {
+	health := container.State.Health
+	if health == nil {
+		return nil, false
+	}
+	switch health.Status {
+	case types.Unhealthy:
+		if state.unhealthyReported {
+			return nil, false
+		}
+		state.unhealthyReported = true
+		return buildHealthReport(health), false
+	case types.Healthy:
+		if !state.unhealthyReported {
+			return nil, false
+		}
+		state.unhealthyReported = false
+		return nil, true
+	}
+	return nil, false
+}
+
+// RestoreUnhealthyContainers marks the containers that were reported
+// unhealthy before the agent restarted, so that their recovery is reported
+// instead of a second unhealthy report.
+func RestoreUnhealthyContainers(containerIDs []string) {
+	for _, containerID := range containerIDs {
+		getContainerScanState(containerID).unhealthyReported = true
+	}
+}
+
+// UnhealthyContainers returns the ids of the containers reported unhealthy
+// that have not become healthy since. It must not be called during a scan.
+func UnhealthyContainers() []string {
+	containerStatesMutex.Lock()
+	defer containerStatesMutex.Unlock()
+	containerIDs := make([]string, 0)
+	for containerID, state := range containerStates {
+		if state.unhealthyReported {
+			containerIDs = append(containerIDs, containerID)
+		}
+	}
+	sort.Strings(containerIDs)
+	return containerIDs
+}
+
+func buildHealthReport(health *types.Health) *models.HealthReport {
+	report := &models.HealthReport{
+		Status:        health.Status,
+		FailingStreak: health.FailingStreak,
+		Probes:        make([]models.HealthProbe, 0, len(health.Log)),
+	}
+	for _, result := range health.Log {
+		if result == nil {
+			continue
+		}
+		report.Probes = append(report.Probes, models.HealthProbe{
+			Start:    result.Start,
+			End:      result.End,
+			ExitCode: result.ExitCode,
+			Output:   result.Output,
+		})
+	}
+	return report
+}
+
+func formatHealthLogs(report *models.HealthReport) string {
+	lines := []string{
+		fmt.Sprintf("Container healthcheck is %s after %d consecutive failed probes", report.Status, report.FailingStreak),
+	}
+	for _, probe := range report.Probes {
+		lines = append(lines,
+			fmt.Sprintf("--- Probe at %s exited with code %d ---", probe.Start.Format(time.RFC3339), probe.ExitCode),
+			strings.TrimRight(probe.Output, "\n"),
+		)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"signal/agenttest"
+	"signal/models"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func setHealth(f *scanFixture, id string, status string) {
+	f.engine.Update(id, func(c *agenttest.FakeContainer) {
+		c.State.Health = &types.Health{Status: status, FailingStreak: 3}
+	})
+}
+
+func TestScanReportsHealthRecovery(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	setHealth(f, "c1", types.Unhealthy)
+	f.scan()
+	f.scan()
+	if reports := f.reports(t); len(reports) != 1 {
+		t.Fatalf("expected the container to be reported unhealthy once, got %+v", reports)
+	}
+	if unhealthy := UnhealthyContainers(); len(unhealthy) != 1 || unhealthy[0] != "c1" {
+		t.Errorf("expected c1 to be unhealthy, got %v", unhealthy)
+	}
+
+	setHealth(f, "c1", types.Healthy)
+	f.scan()
+	recoveries := f.recoveries(t)
+	if len(recoveries) != 1 || recoveries[0].Reason != models.RecoveryReasonHealthy {
+		t.Fatalf("expected the container to recover, got %+v", recoveries)
+	}
+	if unhealthy := UnhealthyContainers(); len(unhealthy) != 0 {
+		t.Errorf("expected no unhealthy container, got %v", unhealthy)
+	}
+}
+
+func TestScanReportsHealthRecoveryAfterRestore(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	RestoreUnhealthyContainers([]string{"c1", "removed"})
+
+	setHealth(f, "c1", types.Healthy)
+	f.scan()
+	recoveries := f.recoveries(t)
+	if len(recoveries) != 1 || recoveries[0].Reason != models.RecoveryReasonHealthy || recoveries[0].ContainerName != "/api" {
+		t.Fatalf("expected the restored container to recover, got %+v", recoveries)
+	}
+	if unhealthy := UnhealthyContainers(); len(unhealthy) != 0 {
+		t.Errorf("expected the removed container to be forgotten, got %v", unhealthy)
+	}
+}
+
+func TestScanDoesNotReportRestoredUnhealthyContainerAgain(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	RestoreUnhealthyContainers([]string{"c1"})
+
+	setHealth(f, "c1", types.Unhealthy)
+	f.scan()
+	if reports := f.reports(t); len(reports) != 0 {
+		t.Errorf("expected no second unhealthy report, got %+v", reports)
+	}
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"fmt"
+	"math"
+	"signal/models"
+	"time"
+)
+
+const (
+	logRateHistorySize     = 40
+	logRateWarmupWindows   = 10
+	logRateSmoothingFactor = 0.1
+	logRateAnomalyCooldown = 30 * time.Minute
+
+	logRateSpikeZScore        = 4.0
+	logRateSpikeFactor        = 10.0
+	logRateSpikeMinPerSecond  = 1.0
+	logSilenceMinPerSecond    = 0.2
+	logSilenceWindows         = 3
+	logRateStdDevMinPerSecond = 0.01
+)
+
+// logRateBaseline tracks the log rate of a container with an exponentially
+// weighted moving average and variance, so a lasting change of the rate
+// slowly becomes the new normal instead of being reported over and over.
+type logRateBaseline struct {
+	mean          float64
+	variance      float64
+	windows       int
+	silentWindows int
+	history       []models.LogRateSample
+	reportedAt    map[string]time.Time
+}
+
+func newLogRateBaseline() *logRateBaseline {
+	return &logRateBaseline{
+		history:    make([]models.LogRateSample, 0, logRateHistorySize),
+		reportedAt: make(map[string]time.Time),
+	}
+}
+
+// observe records the number of lines logged within a scan window and
+// returns a report when the rate spiked or the container went silent.
+func (b *logRateBaseline) observe(lines int, window time.Duration, now time.Time) *models.AnomalyReport {
+	if window <= 0 {
+		return nil
+	}
+	rate := float64(lines) / window.Seconds()
+	stdDev := math.Max(math.Sqrt(b.variance), logRateStdDevMinPerSecond)
+
+	if len(b.history) == logRateHistorySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, models.LogRateSample{
+		Timestamp:      now,
+		LinesPerSecond: rate,
+		BaselineMean:   b.mean,
+		BaselineStdDev: stdDev,
+	})
+
+	if rate == 0 {
+		b.silentWindows++
+	} else {
+		b.silentWindows = 0
+	}
+
+	var kind, description string
+	if b.windows >= logRateWarmupWindows {
+		zScore := (rate - b.mean) / stdDev
+		switch {
+		case zScore >= logRateSpikeZScore && rate >= logRateSpikeFactor*b.mean && rate >= logRateSpikeMinPerSecond:
+			kind = models.AnomalyKindLogRateSpike
+			description = fmt.Sprintf("Log volume spiked to %.1f lines/s, %.0fx the usual %.2f lines/s (z-score %.1f)",
+				rate, rate/math.Max(b.mean, logRateStdDevMinPerSecond), b.mean, zScore)
+		case b.silentWindows >= logSilenceWindows && b.mean >= logSilenceMinPerSecond:
+			kind = models.AnomalyKindLogSilence
+			description = fmt.Sprintf("Container stopped logging for %d consecutive scans after a usual %.2f lines/s",
+				b.silentWindows, b.mean)
+		}
+	}
+	b.update(rate)
+	if kind == "" {
+		return nil
+	}
+	if reportedAt, exists := b.reportedAt[kind]; exists && now.Sub(reportedAt) < logRateAnomalyCooldown {
+		return nil
+	}
+	b.reportedAt[kind] = now
+	return &models.AnomalyReport{
+		Kind:          kind,
+		Description:   description,
+		LogRateSeries: append([]models.LogRateSample(nil), b.history...),
+	}
+}
+
+func (b *logRateBaseline) update(rate float64) {
+	if b.windows == 0 {
+		b.mean = rate
+	} else {
+		diff := rate - b.mean
+		increment := logRateSmoothingFactor * diff
+		b.mean += increment
+		b.variance = (1 - logRateSmoothingFactor) * (b.variance + diff*increment)
+	}
+	b.windows++
+}
@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"signal/models"
+	"testing"
+	"time"
+)
+
+const logRateWindow = 15 * time.Second
+
+// logRateFixture feeds scan windows of 15 seconds to a log rate baseline.
+type logRateFixture struct {
+	baseline *logRateBaseline
+	now      time.Time
+}
+
+func newLogRateFixture() *logRateFixture {
+	return &logRateFixture{baseline: newLogRateBaseline(), now: sampleStart}
+}
+
+func (f *logRateFixture) observe(lines int) *models.AnomalyReport {
+	f.now = f.now.Add(logRateWindow)
+	return f.baseline.observe(lines, logRateWindow, f.now)
+}
+
+// warmUp observes the usual number of lines, about 1 line per second with
+// some noise, until the baseline is warmed up.
+func (f *logRateFixture) warmUp(t *testing.T) {
+	t.Helper()
+	for i := 0; i < logRateWarmupWindows; i++ {
+		if anomaly := f.observe(14 + i%3); anomaly != nil {
+			t.Fatalf("expected no report of the usual rate, got %+v", anomaly)
+		}
+	}
+}
+
+func TestLogRateIsNotReportedDuringWarmUp(t *testing.T) {
+	f := newLogRateFixture()
+	for i := 0; i < logRateWarmupWindows-1; i++ {
+		f.observe(15)
+	}
+	if anomaly := f.observe(3000); anomaly != nil {
+		t.Errorf("expected no report before the baseline is warmed up, got %+v", anomaly)
+	}
+}
+
+func TestLogRateSpikeIsReported(t *testing.T) {
+	f := newLogRateFixture()
+	f.warmUp(t)
+
+	anomaly := f.observe(3000)
+	if anomaly == nil || anomaly.Kind != models.AnomalyKindLogRateSpike {
+		t.Fatalf("expected a log rate spike, got %+v", anomaly)
+	}
+	if series := anomaly.LogRateSeries; len(series) != logRateWarmupWindows+1 || series[len(series)-1].LinesPerSecond != 200 {
+		t.Errorf("expected the series to end with the spike, got %+v", series)
+	}
+}
+
+func TestLogRateModerateIncreaseIsNotReported(t *testing.T) {
+	f := newLogRateFixture()
+	f.warmUp(t)
+
+	if anomaly := f.observe(60); anomaly != nil {
+		t.Errorf("expected a fourfold rate not to be a spike, got %+v", anomaly)
+	}
+}
+
+func TestLogSilenceIsReportedAfterThreeWindows(t *testing.T) {
+	f := newLogRateFixture()
+	f.warmUp(t)
+
+	for i := 1; i < logSilenceWindows; i++ {
+		if anomaly := f.observe(0); anomaly != nil {
+			t.Fatalf("expected no report after %d silent windows, got %+v", i, anomaly)
+		}
+	}
+	anomaly := f.observe(0)
+	if anomaly == nil || anomaly.Kind != models.AnomalyKindLogSilence {
+		t.Fatalf("expected log silence, got %+v", anomaly)
+	}
+
+	f.observe(15)
+	for i := 0; i < logSilenceWindows-1; i++ {
+		f.observe(0)
+	}
+	if anomaly := f.observe(14); anomaly != nil {
+		t.Errorf("expected logging again to end the silence, got %+v", anomaly)
+	}
+}
+
+func TestLogSilenceOfQuietContainerIsNotReported(t *testing.T) {
+	f := newLogRateFixture()
+	for i := 0; i < logRateWarmupWindows; i++ {
+		f.observe(i % 2)
+	}
+	for i := 0; i < logSilenceWindows; i++ {
+		if anomaly := f.observe(0); anomaly != nil {
+			t.Fatalf("expected no report of a container that rarely logs, got %+v", anomaly)
+		}
+	}
+}
+
+func TestLogRateAnomaliesAreReportedOncePerCooldown(t *testing.T) {
+	f := newLogRateFixture()
+	f.warmUp(t)
+	if anomaly := f.observe(3000); anomaly == nil {
+		t.Fatal("expected a log rate spike")
+	}
+	spikedAt := f.now
+	f.warmUp(t)
+
+	if anomaly := f.observe(30000); anomaly != nil {
+		t.Errorf("expected no report within the cooldown, got %+v", anomaly)
+	}
+	f.now = spikedAt.Add(logRateAnomalyCooldown)
+	f.warmUp(t)
+	anomaly := f.observe(30000)
+	if anomaly == nil || anomaly.Kind != models.AnomalyKindLogRateSpike {
+		t.Errorf("expected a report after the cooldown, got %+v", anomaly)
+	}
+}
@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"context"
+	"signal/helpers"
+	"signal/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	muteRulesRefreshInterval = time.Minute
+	muteRulesFetchTimeout    = 10 * time.Second
+)
+
+// muteRules are the mute rules of the user the agent reports for, refreshed
+// by the scans.
+var muteRules = helpers.NewMuteRuleSet()
+
+// refreshMuteRules fetches the mute rules when they are older than the
+// refresh interval. The previous rules stay in effect while the backend
+// cannot be reached.
+func refreshMuteRules(ctx context.Context, taskPayload models.TaskPayload, logger *logrus.Logger, now time.Time) {
+	if taskPayload.BackendUrl == "" || taskPayload.BearerToken == "" || taskPayload.UserId == "" {
+		return
+	}
+	if !muteRules.NeedsRefresh(taskPayload.UserId, now, muteRulesRefreshInterval) {
+		return
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, muteRulesFetchTimeout)
+	defer cancel()
+	rules, err := helpers.FetchMuteRules(fetchCtx, taskPayload)
+	if err != nil {
+		logger.Warnf("Failed to fetch mute rules: %v", err)
+		return
+	}
+	muteRules.Set(taskPayload.UserId, rules, now)
+}
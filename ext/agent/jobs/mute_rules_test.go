@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"signal/agenttest"
+	"signal/models"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestScanSkipsMutedDetections(t *testing.T) {
+	f := newScanFixture(t)
+	backend := agenttest.NewFakeBackend()
+	defer backend.Close()
+	f.taskPayload.BackendUrl = backend.URL()
+	f.taskPayload.BearerToken = "token"
+	expired := time.Now().Add(-time.Minute)
+	backend.SetMuteRules(
+		models.MuteRule{Id: "r1", ContainerName: "/proxy", Action: models.MuteActionDrop},
+		models.MuteRule{Id: "r2", Pattern: "cache miss", Severity: "WARNING", Action: models.MuteActionDrop},
+		models.MuteRule{Id: "r3", ContainerName: "/api", Action: models.MuteActionMark},
+		models.MuteRule{Id: "r4", ContainerName: "/worker", Action: models.MuteActionDrop, ExpiresAt: &expired},
+	)
+	f.engine.AddContainer("c1", "api", nil)
+	f.engine.AddContainer("c2", "proxy", nil)
+	f.engine.AddContainer("c3", "cache", nil)
+	f.engine.AddContainer("c4", "worker", nil)
+	now := time.Now()
+	f.engine.Log("c1", now, "ERROR failed to connect to database")
+	f.engine.Log("c2", now, "ERROR upstream connection refused")
+	f.engine.Log("c3", now, `level=warn msg="cache miss for key 42"`)
+	f.engine.Log("c4", now, "ERROR job 7 failed")
+	f.scan()
+
+	reports := f.reports(t)
+	names := make([]string, 0, len(reports))
+	for _, report := range reports {
+		names = append(names, report.ContainerName)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "/api" || names[1] != "/worker" {
+		t.Fatalf("expected only /api and /worker to be reported, got %v", names)
+	}
+	muted := 0
+	for _, container := range GetScanStatus().Containers {
+		muted += container.MutedDetections
+	}
+	if muted != 2 {
+		t.Errorf("expected 2 muted detections, got %d", muted)
+	}
+}
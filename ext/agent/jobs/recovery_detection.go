@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"signal/helpers"
+	"signal/models"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// detectRecovery returns the recoveries of a running container seen by this
+// scan besides a passing healthcheck: a clean restart, when its previous run
+// exited with code 0 since the given time, or a run lasting the stability
+// window after the container was reported as failed. Each recovery covers
+// the issues raised before the current run started.
+func detectRecovery(container types.ContainerJSON, state *containerScanState, taskPayload models.TaskPayload,
+	since time.Time, now time.Time) []models.RecoveryPayload {
+	recoveries := make([]models.RecoveryPayload, 0)
+	if !container.State.Running {
+		return recoveries
+	}
+	startedAt, err := time.Parse(time.RFC3339Nano, container.State.StartedAt)
+	if err != nil {
+		return recoveries
+	}
+	if state.lastExitCode == 0 && state.lastExitedAt.After(since) && !state.lastExitedAt.After(startedAt) {
+		recoveries = append(recoveries, models.RecoveryPayload{
+			Reason: models.RecoveryReasonRestarted,
+			Since:  startedAt,
+		})
+		state.awaitingStableRun = false
+	}
+	if state.awaitingStableRun && taskPayload.RecoveryStabilityWindow > 0 && now.Sub(startedAt) >= taskPayload.RecoveryStabilityWindow {
+		recoveries = append(recoveries, models.RecoveryPayload{
+			Reason: models.RecoveryReasonStable,
+			Since:  startedAt,
+		})
+		state.awaitingStableRun = false
+	}
+	return recoveries
+}
+
+// reportRecovery queues the recovery of a container for the backend. The
+// backend matches the issues of its Compose replica when it has one, so the
+// recovery covers issues raised before the container was recreated.
+func reportRecovery(outboundQueue *helpers.OutboundQueue, c types.Container, payload models.RecoveryPayload, taskPayload models.TaskPayload) error {
+	payload.ContainerName = c.Names[0]
+	payload.ComposeProject = c.Labels[helpers.LabelComposeProject]
+	payload.ComposeService = c.Labels[helpers.LabelComposeService]
+	payload.ComposeContainerNumber, _ = strconv.Atoi(c.Labels[helpers.LabelComposeContainerNumber])
+	return outboundQueue.EnqueueRecovery(payload, taskPayload)
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"encoding/json"
+	"signal/helpers"
+	"signal/models"
+	"testing"
+	"time"
+)
+
+// recoveries returns the queued recoveries in the order they were queued.
+func (f *scanFixture) recoveries(t *testing.T) []models.RecoveryPayload {
+	t.Helper()
+	recoveries := make([]models.RecoveryPayload, 0)
+	for _, item := range f.queued(t, helpers.OutboundKindRecovery) {
+		var payload models.RecoveryPayload
+		if err := json.Unmarshal(item.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		recoveries = append(recoveries, payload)
+	}
+	return recoveries
+}
+
+func TestScanReportsCleanRestart(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "api", nil)
+	f.scan()
+
+	f.engine.Exit("c1", 0, time.Now())
+	time.Sleep(time.Millisecond)
+	startedAt := time.Now()
+	f.engine.Restart("c1", startedAt)
+	f.scan()
+	recoveries := f.recoveries(t)
+	if len(recoveries) != 1 || recoveries[0].Reason != models.RecoveryReasonRestarted || recoveries[0].ContainerName != "/api" {
+		t.Fatalf("expected a clean restart of /api, got %+v", recoveries)
+	}
+	if !recoveries[0].Since.Equal(startedAt) {
+		t.Errorf("expected the recovery to cover issues before %v, got %v", startedAt, recoveries[0].Since)
+	}
+
+	f.crash("c1", 1, "ERROR out of memory")
+	f.scan()
+	if recoveries := f.recoveries(t); len(recoveries) != 1 {
+		t.Errorf("expected a failed run not to be reported as a recovery, got %+v", recoveries)
+	}
+}
+
+func TestScanReportsStableRunAfterFailure(t *testing.T) {
+	f := newScanFixture(t)
+	f.taskPayload.RecoveryStabilityWindow = 50 * time.Millisecond
+	f.engine.AddContainer("c1", "api", nil)
+	f.scan()
+
+	f.engine.Log("c1", time.Now(), "ERROR failed to bind port")
+	f.engine.Exit("c1", 1, time.Now())
+	f.scan()
+	if reports := f.reports(t); len(reports) != 1 || reports[0].Termination == nil {
+		t.Fatalf("expected a termination report, got %+v", reports)
+	}
+
+	startedAt := time.Now()
+	f.engine.Restart("c1", startedAt)
+	f.scan()
+	if recoveries := f.recoveries(t); len(recoveries) != 0 {
+		t.Fatalf("expected no recovery before the stability window, got %+v", recoveries)
+	}
+
+	time.Sleep(f.taskPayload.RecoveryStabilityWindow)
+	f.scan()
+	f.scan()
+	recoveries := f.recoveries(t)
+	if len(recoveries) != 1 || recoveries[0].Reason != models.RecoveryReasonStable || !recoveries[0].Since.Equal(startedAt) {
+		t.Fatalf("expected a single stable run recovery since %v, got %+v", startedAt, recoveries)
+	}
+}
+
+func TestScanReportsRecoveryOfComposeReplica(t *testing.T) {
+	f := newScanFixture(t)
+	f.engine.AddContainer("c1", "shop-api-2", map[string]string{
+		helpers.LabelComposeProject:         "shop",
+		helpers.LabelComposeService:         "api",
+		helpers.LabelComposeContainerNumber: "2",
+	})
+	f.scan()
+
+	f.engine.Exit("c1", 0, time.Now())
+	time.Sleep(time.Millisecond)
+	f.engine.Restart("c1", time.Now())
+	f.scan()
+	recoveries := f.recoveries(t)
+	if len(recoveries) != 1 {
+		t.Fatalf("expected a clean restart, got %+v", recoveries)
+	}
+	recovery := recoveries[0]
+	if recovery.ComposeProject != "shop" || recovery.ComposeService != "api" || recovery.ComposeContainerNumber != 2 {
+		t.Errorf("expected the recovery to identify replica 2 of shop/api, got %+v", recovery)
+	}
+}
@@ -0,0 +1,270 @@
+package jobs
+
+import (
+	"fmt"
+	"math"
+	"signal/helpers"
+	"signal/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	resourceBaselineSize      = 40
+	resourceAnomalyMinSamples = 8
+	resourceAnomalyCooldown   = 30 * time.Minute
+
+	memoryExhaustionHorizon = 30 * time.Minute
+	memoryPressureRatio     = 0.6
+
+	cpuSaturationRatio   = 0.9
+	cpuSaturationSamples = 4
+
+	pidExplosionFactor      = 3.0
+	pidExplosionMinIncrease = 100
+	pidLimitRatio           = 0.9
+
+	trafficDropMinBytesPerSecond = 1024.0
+	trafficDropSamples           = 3
+)
+
+// resourceCounters keeps the cumulative counters of the previous stats
+// sample, which rates and CPU usage are computed from.
+type resourceCounters struct {
+	timestamp    time.Time
+	cpuTotal     uint64
+	systemCpu    uint64
+	networkRx    uint64
+	networkTx    uint64
+	blockRead    uint64
+	blockWrite   uint64
+	restartCount int
+}
+
+// resourceBaseline is the rolling window of resource samples of a container.
+type resourceBaseline struct {
+	previous   *resourceCounters
+	samples    []models.ResourceSample
+	reportedAt map[string]time.Time
+}
+
+func newResourceBaseline() *resourceBaseline {
+	return &resourceBaseline{
+		samples:    make([]models.ResourceSample, 0, resourceBaselineSize),
+		reportedAt: make(map[string]time.Time),
+	}
+}
+
+// addSample appends a stats sample to the baseline. The first sample after
+// the agent started or the container restarted only seeds the counters.
+func (b *resourceBaseline) addSample(stats types.StatsJSON, container types.ContainerJSON, now time.Time) {
+	current := &resourceCounters{
+		timestamp:    now,
+		cpuTotal:     stats.CPUStats.CPUUsage.TotalUsage,
+		systemCpu:    stats.CPUStats.SystemUsage,
+		restartCount: container.RestartCount,
+	}
+	for _, network := range stats.Networks {
+		current.networkRx += network.RxBytes
+		current.networkTx += network.TxBytes
+	}
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			current.blockRead += entry.Value
+		case "write":
+			current.blockWrite += entry.Value
+		}
+	}
+
+	previous := b.previous
+	b.previous = current
+	if previous == nil || previous.restartCount != current.restartCount || current.cpuTotal < previous.cpuTotal {
+		b.samples = b.samples[:0]
+		return
+	}
+	seconds := current.timestamp.Sub(previous.timestamp).Seconds()
+	if seconds <= 0 {
+		return
+	}
+
+	onlineCpus := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCpus == 0 {
+		onlineCpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	cpuLimit := onlineCpus
+	if container.HostConfig != nil && container.HostConfig.NanoCPUs > 0 {
+		cpuLimit = math.Min(cpuLimit, float64(container.HostConfig.NanoCPUs)/1e9)
+	}
+	cpuPercent := 0.0
+	if current.systemCpu > previous.systemCpu {
+		cpuPercent = float64(current.cpuTotal-previous.cpuTotal) / float64(current.systemCpu-previous.systemCpu) * onlineCpus * 100
+	}
+
+	sample := models.ResourceSample{
+		Timestamp:                now,
+		CpuPercent:               cpuPercent,
+		CpuLimitPercent:          cpuLimit * 100,
+		MemoryUsageBytes:         helpers.GetMemoryUsage(stats),
+		MemoryLimitBytes:         stats.MemoryStats.Limit,
+		NetworkRxBytesPerSecond:  counterRate(previous.networkRx, current.networkRx, seconds),
+		NetworkTxBytesPerSecond:  counterRate(previous.networkTx, current.networkTx, seconds),
+		BlockReadBytesPerSecond:  counterRate(previous.blockRead, current.blockRead, seconds),
+		BlockWriteBytesPerSecond: counterRate(previous.blockWrite, current.blockWrite, seconds),
+		Pids:                     stats.PidsStats.Current,
+		PidsLimit:                stats.PidsStats.Limit,
+	}
+	if len(b.samples) == resourceBaselineSize {
+		b.samples = append(b.samples[:0], b.samples[1:]...)
+	}
+	b.samples = append(b.samples, sample)
+}
+
+// detectAnomalies checks the baseline for memory growing toward the limit,
+// CPU saturation, PID explosion and traffic suddenly dropping to zero. Each
+// kind of anomaly is reported at most once per cooldown period.
+func (b *resourceBaseline) detectAnomalies(now time.Time) []*models.AnomalyReport {
+	anomalies := make([]*models.AnomalyReport, 0)
+	if len(b.samples) < resourceAnomalyMinSamples {
+		return anomalies
+	}
+	detectors := map[string]func([]models.ResourceSample) string{
+		models.AnomalyKindMemoryExhaustion: detectMemoryExhaustion,
+		models.AnomalyKindCpuSaturation:    detectCpuSaturation,
+		models.AnomalyKindPidExplosion:     detectPidExplosion,
+		models.AnomalyKindTrafficDrop:      detectTrafficDrop,
+	}
+	for kind, detect := range detectors {
+		description := detect(b.samples)
+		if description == "" {
+			continue
+		}
+		if reportedAt, exists := b.reportedAt[kind]; exists && now.Sub(reportedAt) < resourceAnomalyCooldown {
+			continue
+		}
+		b.reportedAt[kind] = now
+		anomalies = append(anomalies, &models.AnomalyReport{
+			Kind:           kind,
+			Description:    description,
+			ResourceSeries: append([]models.ResourceSample(nil), b.samples...),
+		})
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Kind < anomalies[j].Kind
+	})
+	return anomalies
+}
+
+func detectMemoryExhaustion(samples []models.ResourceSample) string {
+	last := samples[len(samples)-1]
+	if last.MemoryLimitBytes == 0 || float64(last.MemoryUsageBytes) < memoryPressureRatio*float64(last.MemoryLimitBytes) {
+		return ""
+	}
+	start := samples[0].Timestamp
+	xs := make([]float64, len(samples))
+	ys := make([]float64, len(samples))
+	for i, sample := range samples {
+		xs[i] = sample.Timestamp.Sub(start).Seconds()
+		ys[i] = float64(sample.MemoryUsageBytes)
+	}
+	slope := linearRegressionSlope(xs, ys)
+	if slope <= 0 {
+		return ""
+	}
+	secondsToLimit := 0.0
+	if last.MemoryUsageBytes < last.MemoryLimitBytes {
+		secondsToLimit = float64(last.MemoryLimitBytes-last.MemoryUsageBytes) / slope
+	}
+	if secondsToLimit > memoryExhaustionHorizon.Seconds() {
+		return ""
+	}
+	return fmt.Sprintf("Memory usage grows by %.1f MiB/min and is at %.0f%% of the %d MiB limit, reaching it in about %s",
+		slope*60/(1<<20), 100*float64(last.MemoryUsageBytes)/float64(last.MemoryLimitBytes),
+		last.MemoryLimitBytes/(1<<20), (time.Duration(secondsToLimit) * time.Second).Round(time.Second))
+}
+
+func detectCpuSaturation(samples []models.ResourceSample) string {
+	recent := samples[len(samples)-cpuSaturationSamples:]
+	for _, sample := range recent {
+		if sample.CpuLimitPercent == 0 || sample.CpuPercent < cpuSaturationRatio*sample.CpuLimitPercent {
+			return ""
+		}
+	}
+	last := recent[len(recent)-1]
+	return fmt.Sprintf("CPU usage stays at %.0f%% of the available %.0f%% for %d consecutive samples",
+		last.CpuPercent, last.CpuLimitPercent, cpuSaturationSamples)
+}
+
+func detectPidExplosion(samples []models.ResourceSample) string {
+	last := samples[len(samples)-1]
+	if last.PidsLimit > 0 && float64(last.Pids) >= pidLimitRatio*float64(last.PidsLimit) {
+		return fmt.Sprintf("Container runs %d processes, close to its limit of %d", last.Pids, last.PidsLimit)
+	}
+	baseline := make([]float64, 0, len(samples)-1)
+	for _, sample := range samples[:len(samples)-1] {
+		baseline = append(baseline, float64(sample.Pids))
+	}
+	median := medianOf(baseline)
+	if float64(last.Pids) >= pidExplosionFactor*median && float64(last.Pids)-median >= pidExplosionMinIncrease {
+		return fmt.Sprintf("Container runs %d processes, up from a usual %.0f", last.Pids, median)
+	}
+	return ""
+}
+
+func detectTrafficDrop(samples []models.ResourceSample) string {
+	baseline := samples[:len(samples)-trafficDropSamples]
+	recent := samples[len(samples)-trafficDropSamples:]
+	total := 0.0
+	for _, sample := range baseline {
+		total += sample.NetworkRxBytesPerSecond + sample.NetworkTxBytesPerSecond
+	}
+	mean := total / float64(len(baseline))
+	if mean < trafficDropMinBytesPerSecond {
+		return ""
+	}
+	for _, sample := range recent {
+		if sample.NetworkRxBytesPerSecond+sample.NetworkTxBytesPerSecond > 0 {
+			return ""
+		}
+	}
+	return fmt.Sprintf("Network traffic dropped to zero from a usual %.1f KiB/s", mean/1024)
+}
+
+func counterRate(previous uint64, current uint64, seconds float64) float64 {
+	if current < previous {
+		return 0
+	}
+	return float64(current-previous) / seconds
+}
+
+func linearRegressionSlope(xs []float64, ys []float64) float64 {
+	n := float64(len(xs))
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
+
+func medianOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
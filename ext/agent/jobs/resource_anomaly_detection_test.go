@@ -0,0 +1,206 @@
+package jobs
+
+import (
+	"signal/models"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+const mib = 1 << 20
+
+var sampleStart = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// resourceSeries returns n samples taken every 15 seconds.
+func resourceSeries(n int, sample func(i int) models.ResourceSample) []models.ResourceSample {
+	samples := make([]models.ResourceSample, n)
+	for i := range samples {
+		samples[i] = sample(i)
+		samples[i].Timestamp = sampleStart.Add(time.Duration(i) * 15 * time.Second)
+	}
+	return samples
+}
+
+func TestDetectMemoryExhaustion(t *testing.T) {
+	tests := []struct {
+		name     string
+		usage    func(i int) uint64
+		detected bool
+	}{
+		{"growing toward the limit", func(i int) uint64 { return uint64(600+10*i) * mib }, true},
+		{"flat under pressure", func(i int) uint64 { return 900 * mib }, false},
+		{"growing below the pressure ratio", func(i int) uint64 { return uint64(100+10*i) * mib }, false},
+		{"growing too slowly to reach the limit soon", func(i int) uint64 { return uint64(700+i) * mib }, false},
+		{"shrinking", func(i int) uint64 { return uint64(1000-10*i) * mib }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			samples := resourceSeries(resourceAnomalyMinSamples, func(i int) models.ResourceSample {
+				return models.ResourceSample{MemoryUsageBytes: test.usage(i), MemoryLimitBytes: 1024 * mib}
+			})
+			if description := detectMemoryExhaustion(samples); (description != "") != test.detected {
+				t.Errorf("expected detected %t, got %q", test.detected, description)
+			}
+		})
+	}
+	unlimited := resourceSeries(resourceAnomalyMinSamples, func(i int) models.ResourceSample {
+		return models.ResourceSample{MemoryUsageBytes: uint64(600+10*i) * mib}
+	})
+	if description := detectMemoryExhaustion(unlimited); description != "" {
+		t.Errorf("expected no report without a memory limit, got %q", description)
+	}
+}
+
+func TestDetectCpuSaturation(t *testing.T) {
+	tests := []struct {
+		name     string
+		cpu      func(i int) float64
+		detected bool
+	}{
+		{"saturated", func(i int) float64 { return 195 }, true},
+		{"saturated only recently", func(i int) float64 {
+			if i < resourceAnomalyMinSamples-cpuSaturationSamples {
+				return 20
+			}
+			return 190
+		}, true},
+		{"one sample below the limit", func(i int) float64 {
+			if i == resourceAnomalyMinSamples-2 {
+				return 150
+			}
+			return 195
+		}, false},
+		{"busy but not saturated", func(i int) float64 { return 170 }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			samples := resourceSeries(resourceAnomalyMinSamples, func(i int) models.ResourceSample {
+				return models.ResourceSample{CpuPercent: test.cpu(i), CpuLimitPercent: 200}
+			})
+			if description := detectCpuSaturation(samples); (description != "") != test.detected {
+				t.Errorf("expected detected %t, got %q", test.detected, description)
+			}
+		})
+	}
+}
+
+func TestDetectPidExplosion(t *testing.T) {
+	tests := []struct {
+		name     string
+		last     uint64
+		limit    uint64
+		detected bool
+	}{
+		{"explosion", 200, 0, true},
+		{"small increase", 60, 0, false},
+		{"close to the limit", 95, 100, true},
+		{"far from the limit", 50, 100, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			samples := resourceSeries(resourceAnomalyMinSamples, func(i int) models.ResourceSample {
+				if i == resourceAnomalyMinSamples-1 {
+					return models.ResourceSample{Pids: test.last, PidsLimit: test.limit}
+				}
+				return models.ResourceSample{Pids: 10, PidsLimit: test.limit}
+			})
+			if description := detectPidExplosion(samples); (description != "") != test.detected {
+				t.Errorf("expected detected %t, got %q", test.detected, description)
+			}
+		})
+	}
+}
+
+func TestDetectTrafficDrop(t *testing.T) {
+	tests := []struct {
+		name         string
+		usual        float64
+		silentRecent int
+		detected     bool
+	}{
+		{"drop to zero", 5 * 1024, trafficDropSamples, true},
+		{"drop shorter than the window", 5 * 1024, trafficDropSamples - 1, false},
+		{"idle container", 100, trafficDropSamples, false},
+		{"steady traffic", 5 * 1024, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			samples := resourceSeries(resourceAnomalyMinSamples, func(i int) models.ResourceSample {
+				if i >= resourceAnomalyMinSamples-test.silentRecent {
+					return models.ResourceSample{}
+				}
+				return models.ResourceSample{NetworkRxBytesPerSecond: test.usual / 2, NetworkTxBytesPerSecond: test.usual / 2}
+			})
+			if description := detectTrafficDrop(samples); (description != "") != test.detected {
+				t.Errorf("expected detected %t, got %q", test.detected, description)
+			}
+		})
+	}
+}
+
+func TestResourceAnomaliesAreReportedOncePerCooldown(t *testing.T) {
+	baseline := newResourceBaseline()
+	baseline.samples = resourceSeries(resourceAnomalyMinSamples, func(i int) models.ResourceSample {
+		return models.ResourceSample{CpuPercent: 100, CpuLimitPercent: 100, Pids: 10}
+	})
+	now := sampleStart.Add(2 * time.Minute)
+
+	anomalies := baseline.detectAnomalies(now)
+	if len(anomalies) != 1 || anomalies[0].Kind != models.AnomalyKindCpuSaturation {
+		t.Fatalf("expected CPU saturation, got %+v", anomalies)
+	}
+	if len(anomalies[0].ResourceSeries) != resourceAnomalyMinSamples {
+		t.Errorf("expected the series to be attached, got %d samples", len(anomalies[0].ResourceSeries))
+	}
+	if anomalies := baseline.detectAnomalies(now.Add(resourceAnomalyCooldown - time.Minute)); len(anomalies) != 0 {
+		t.Errorf("expected no report within the cooldown, got %+v", anomalies)
+	}
+	if anomalies := baseline.detectAnomalies(now.Add(resourceAnomalyCooldown)); len(anomalies) != 1 {
+		t.Errorf("expected a report after the cooldown, got %+v", anomalies)
+	}
+}
+
+func TestResourceAnomaliesNeedEnoughSamples(t *testing.T) {
+	baseline := newResourceBaseline()
+	baseline.samples = resourceSeries(resourceAnomalyMinSamples-1, func(i int) models.ResourceSample {
+		return models.ResourceSample{CpuPercent: 100, CpuLimitPercent: 100}
+	})
+	if anomalies := baseline.detectAnomalies(sampleStart); len(anomalies) != 0 {
+		t.Errorf("expected no report before the baseline is filled, got %+v", anomalies)
+	}
+}
+
+func TestResourceBaselineResetsOnRestart(t *testing.T) {
+	baseline := newResourceBaseline()
+	container := types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{}}
+	stats := func(i int) types.StatsJSON {
+		var s types.StatsJSON
+		s.CPUStats.OnlineCPUs = 1
+		s.CPUStats.CPUUsage.TotalUsage = uint64(i) * 1e9
+		s.CPUStats.SystemUsage = uint64(i) * 1e9
+		return s
+	}
+	for i := 0; i <= resourceAnomalyMinSamples; i++ {
+		baseline.addSample(stats(i), container, sampleStart.Add(time.Duration(i)*15*time.Second))
+	}
+	if len(baseline.samples) != resourceAnomalyMinSamples {
+		t.Fatalf("expected %d samples, got %d", resourceAnomalyMinSamples, len(baseline.samples))
+	}
+	if anomalies := baseline.detectAnomalies(sampleStart.Add(5 * time.Minute)); len(anomalies) != 1 || anomalies[0].Kind != models.AnomalyKindCpuSaturation {
+		t.Fatalf("expected CPU saturation, got %+v", anomalies)
+	}
+
+	container.RestartCount = 1
+	baseline.addSample(stats(resourceAnomalyMinSamples+1), container, sampleStart.Add(10*time.Minute))
+	if len(baseline.samples) != 0 {
+		t.Errorf("expected the restart to reset the samples, got %d", len(baseline.samples))
+	}
+	baseline.addSample(stats(resourceAnomalyMinSamples+2), container, sampleStart.Add(10*time.Minute+15*time.Second))
+	if len(baseline.samples) != 1 {
+		t.Errorf("expected the counters to be seeded again, got %d samples", len(baseline.samples))
+	}
+	if anomalies := baseline.detectAnomalies(sampleStart.Add(11 * time.Minute)); len(anomalies) != 0 {
+		t.Errorf("expected no report until the baseline is filled again, got %+v", anomalies)
+	}
+}
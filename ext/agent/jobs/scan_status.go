@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"signal/helpers"
+	"signal/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	detectionKindCrashLoop   = "crash_loop"
+	detectionKindUnhealthy   = "unhealthy"
+	detectionKindTermination = "termination"
+	detectionKindLogEvents   = "log_events"
+)
+
+const slowContainerScanDivisor = 2
+
+// scanStatus is the outcome of recent scans reported by the control API. It
+// is kept apart from the per-container scan state, which only the scan
+// goroutines touch.
+var scanStatus = struct {
+	mutex             sync.Mutex
+	lastScanAt        time.Time
+	lastScanDuration  time.Duration
+	containersWatched int
+	containersSkipped int
+	containers        map[string]*models.ContainerStatus
+}{
+	containers: make(map[string]*models.ContainerStatus),
+}
+
+// GetScanStatus returns the last scan and the containers it watched.
+func GetScanStatus() models.ScanStatus {
+	scanStatus.mutex.Lock()
+	defer scanStatus.mutex.Unlock()
+	status := models.ScanStatus{
+		LastScanAt:         scanStatus.lastScanAt,
+		LastScanDurationMs: scanStatus.lastScanDuration.Milliseconds(),
+		ContainersWatched:  scanStatus.containersWatched,
+		ContainersSkipped:  scanStatus.containersSkipped,
+		Containers:         make([]models.ContainerStatus, 0, len(scanStatus.containers)),
+	}
+	for _, container := range scanStatus.containers {
+		status.Containers = append(status.Containers, *container)
+	}
+	sort.Slice(status.Containers, func(i, j int) bool {
+		return status.Containers[i].Name < status.Containers[j].Name
+	})
+	return status
+}
+
+func recordScan(startedAt time.Time, duration time.Duration, containersWatched int, containersSkipped int) {
+	scanStatus.mutex.Lock()
+	defer scanStatus.mutex.Unlock()
+	scanStatus.lastScanAt = startedAt
+	scanStatus.lastScanDuration = duration
+	scanStatus.containersWatched = containersWatched
+	scanStatus.containersSkipped = containersSkipped
+}
+
+func recordContainerScan(containerID string, name string, scanTime time.Time) {
+	scanStatus.mutex.Lock()
+	defer scanStatus.mutex.Unlock()
+	container, exists := scanStatus.containers[containerID]
+	if !exists {
+		container = &models.ContainerStatus{Id: containerID}
+		scanStatus.containers[containerID] = container
+	}
+	container.Name = name
+	container.LastScanAt = scanTime
+}
+
+// recordContainerScanDuration counts container scans that took more than
+// half of their deadline as slow, so containers with a slow Docker API or
+// huge logs show up in the status before they start timing out.
+func recordContainerScanDuration(containerID string, duration time.Duration, timedOut bool, timeout time.Duration, logger *logrus.Logger) {
+	isSlow := timedOut || (timeout > 0 && duration >= timeout/slowContainerScanDivisor)
+	scanStatus.mutex.Lock()
+	defer scanStatus.mutex.Unlock()
+	container, exists := scanStatus.containers[containerID]
+	if !exists {
+		return
+	}
+	container.LastScanDurationMs = duration.Milliseconds()
+	if isSlow {
+		container.SlowScans++
+	}
+	if timedOut {
+		container.TimedOutScans++
+	}
+	if isSlow {
+		logger.Warnf("Scan of container %s took %s (timed out: %v)", container.Name, duration.Round(time.Millisecond), timedOut)
+	}
+}
+
+func recordDetection(containerID string, payload models.LogAnalysisPayload, detectedAt time.Time) {
+	scanStatus.mutex.Lock()
+	defer scanStatus.mutex.Unlock()
+	if container, exists := scanStatus.containers[containerID]; exists {
+		container.LastDetection = &models.DetectionSummary{
+			IssueType:  payload.IssueType,
+			Kind:       detectionKind(payload),
+			DetectedAt: detectedAt,
+		}
+	}
+}
+
+func recordMutedDetection(containerID string) {
+	scanStatus.mutex.Lock()
+	defer scanStatus.mutex.Unlock()
+	if container, exists := scanStatus.containers[containerID]; exists {
+		container.MutedDetections++
+	}
+}
+
+func pruneScanStatus(current map[string]bool) {
+	scanStatus.mutex.Lock()
+	defer scanStatus.mutex.Unlock()
+	for containerID := range scanStatus.containers {
+		if !current[containerID] {
+			delete(scanStatus.containers, containerID)
+		}
+	}
+}
+
+// reportLogAnalysis labels a detection with the Compose service of the
+// container, queues it for the backend and records it as the last detection
+// of the container. Detections dropped by a mute rule are only counted.
+func reportLogAnalysis(outboundQueue *helpers.OutboundQueue, c types.Container, payload models.LogAnalysisPayload, taskPayload models.TaskPayload) error {
+	helpers.SetContainerLabels(&payload, c.Labels)
+	if rule := muteRules.Drops(taskPayload.UserId, payload, time.Now()); rule != nil {
+		recordMutedDetection(c.ID)
+		return nil
+	}
+	err := outboundQueue.EnqueueLogAnalysis(payload, taskPayload)
+	if err == nil {
+		recordDetection(c.ID, payload, time.Now())
+	}
+	return err
+}
+
+func detectionKind(payload models.LogAnalysisPayload) string {
+	switch {
+	case payload.CrashLoop != nil:
+		return detectionKindCrashLoop
+	case payload.Anomaly != nil:
+		return payload.Anomaly.Kind
+	case payload.Health != nil:
+		return detectionKindUnhealthy
+	case payload.Termination != nil:
+		return detectionKindTermination
+	}
+	return detectionKindLogEvents
+}
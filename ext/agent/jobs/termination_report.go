@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"signal/helpers"
+	"signal/models"
+
+	"github.com/docker/docker/api/types"
+)
+
+func buildTerminationReport(container types.ContainerJSON, lastMemoryUsage uint64) *models.TerminationReport {
+	meaning, signal := helpers.DescribeExitCode(container.State.ExitCode)
+	report := &models.TerminationReport{
+		ExitCode:             container.State.ExitCode,
+		ExitCodeMeaning:      meaning,
+		Signal:               signal,
+		OOMKilled:            container.State.OOMKilled,
+		Error:                container.State.Error,
+		FinishedAt:           container.State.FinishedAt,
+		LastMemoryUsageBytes: lastMemoryUsage,
+		RestartCount:         container.RestartCount,
+	}
+	if container.HostConfig != nil {
+		report.MemoryLimitBytes = container.HostConfig.Memory
+		report.RestartPolicy = container.HostConfig.RestartPolicy.Name
+		report.MaximumRetryCount = container.HostConfig.RestartPolicy.MaximumRetryCount
+	}
+	if report.OOMKilled {
+		report.ExitCodeMeaning = "Killed by the kernel OOM killer after reaching the memory limit"
+	}
+	return report
+}
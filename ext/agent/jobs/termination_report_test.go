@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+func exitedContainer(exitCode int, oomKilled bool) types.ContainerJSON {
+	return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+		State:        &types.ContainerState{ExitCode: exitCode, OOMKilled: oomKilled, FinishedAt: "2024-05-01T10:00:00Z"},
+		RestartCount: 2,
+		HostConfig: &container.HostConfig{
+			Resources:     container.Resources{Memory: 512 << 20},
+			RestartPolicy: container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 5},
+		},
+	}}
+}
+
+func TestBuildTerminationReport(t *testing.T) {
+	tests := []struct {
+		name      string
+		exitCode  int
+		oomKilled bool
+		meaning   string
+		signal    string
+	}{
+		{"clean exit", 0, false, "Exited successfully", ""},
+		{"application error", 1, false, "Application error", ""},
+		{"killed", 137, false, "Killed (SIGKILL), e.g. out of memory or docker kill/stop timeout", "SIGKILL"},
+		{"OOM killed", 137, true, "Killed by the kernel OOM killer after reaching the memory limit", "SIGKILL"},
+		{"segmentation fault", 139, false, "Segmentation fault (SIGSEGV), invalid memory access", "SIGSEGV"},
+		{"stopped", 143, false, "Terminated gracefully (SIGTERM), e.g. docker stop", "SIGTERM"},
+		{"unknown exit code", 42, false, "Application specific exit code", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := buildTerminationReport(exitedContainer(test.exitCode, test.oomKilled), 500<<20)
+			if report.ExitCode != test.exitCode || report.OOMKilled != test.oomKilled {
+				t.Errorf("expected exit code %d and OOM killed %t, got %+v", test.exitCode, test.oomKilled, report)
+			}
+			if report.ExitCodeMeaning != test.meaning || report.Signal != test.signal {
+				t.Errorf("expected %q by %q, got %q by %q", test.meaning, test.signal, report.ExitCodeMeaning, report.Signal)
+			}
+			if report.MemoryLimitBytes != 512<<20 || report.LastMemoryUsageBytes != 500<<20 {
+				t.Errorf("expected the memory limit and last usage, got %+v", report)
+			}
+			if report.RestartPolicy != "on-failure" || report.MaximumRetryCount != 5 || report.RestartCount != 2 {
+				t.Errorf("expected the restart policy and count, got %+v", report)
+			}
+		})
+	}
+}
+
+func TestBuildTerminationReportWithoutHostConfig(t *testing.T) {
+	c := exitedContainer(1, false)
+	c.HostConfig = nil
+	report := buildTerminationReport(c, 0)
+	if report.MemoryLimitBytes != 0 || report.RestartPolicy != "" {
+		t.Errorf("expected no limit or restart policy, got %+v", report)
+	}
+}
@@ -1,33 +1,44 @@
 package main
 
 import (
+	"context"
 	"os"
 	"signal/helpers"
 	"signal/jobs"
 	"signal/models"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/client"
-	"github.com/go-co-op/gocron/v2"
-	"github.com/google/uuid"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
 	"github.com/sirupsen/logrus"
 )
 
+var version = "dev"
 var logger = logrus.New()
-var jobScheduler, _ = gocron.NewScheduler()
-var state = false
-var taskPayload = models.TaskPayload{
-	BearerToken: "",
-	BackendUrl:  "",
-	UserId:      "",
-}
-var jobId = uuid.Nil
+var logBuffer = helpers.NewLogBuffer(500)
+var agentConfig helpers.ConfigServer
+var collector *jobs.Collector
+var controlMutex sync.Mutex
 var dockerClient *client.Client
+var outboundQueue *helpers.OutboundQueue
+var agentStateStore *helpers.AgentStateStore
+var agentStateMutex sync.Mutex
+var unhealthyContainers []string
 
 type AgentStatePayload struct {
-	State bool `json:"state"`
+	State      bool `json:"state"`
+	QueueDepth int  `json:"queue_depth"`
+}
+
+type AgentStateRequestPayload struct {
+	State *bool `json:"state"`
+}
+
+type AgentSettingsPayload struct {
+	ScanInterval string `json:"scan_interval"`
 }
 
 type AgentAuthDataPayload struct {
@@ -37,59 +48,198 @@ type AgentAuthDataPayload struct {
 
 func main() {
 	logger.SetOutput(os.Stdout)
+	logger.AddHook(logBuffer)
 
 	cfs := helpers.GetEnvVariables()
+	agentConfig = cfs
+	if err := helpers.ValidateScanMode(cfs.ScanMode); err != nil {
+		logger.Fatalf("Failed to read the configuration: %v", err)
+	}
 	dockerClient, _ = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	taskPayload.BackendUrl = cfs.BackendApiAddress
-	job, err := jobScheduler.NewJob(
-		gocron.DurationJob(time.Second*15),
-		gocron.NewTask(jobs.ScanForErrors, dockerClient, logger, taskPayload),
-	)
+	taskPayload := models.TaskPayload{
+		BackendUrl:                cfs.BackendApiAddress,
+		ScanMode:                  cfs.ScanMode,
+		ScanInterval:              cfs.ScanInterval,
+		ScanTimeout:               cfs.ScanTimeout,
+		ContainerScanTimeout:      cfs.ContainerScanTimeout,
+		ScanWorkers:               cfs.ScanWorkers,
+		CrashLoopRestartThreshold: cfs.CrashLoopRestartThreshold,
+		CrashLoopWindow:           cfs.CrashLoopWindow,
+		RecoveryStabilityWindow:   cfs.RecoveryStabilityWindow,
+		LogContextLinesBefore:     cfs.LogContextLinesBefore,
+		LogContextTimeBefore:      cfs.LogContextTimeBefore,
+		LogContextLinesAfter:      cfs.LogContextLinesAfter,
+	}
+	agentStateSecret, err := helpers.ReadAgentStateSecret(cfs.AgentStateSecret, cfs.AgentStateSecretFile)
+	if err != nil {
+		logger.Fatalf("Failed to read the agent state secret: %v", err)
+	}
+	agentConfig.AgentStateSecret = agentStateSecret
+	if agentStateSecret == "" {
+		logger.Warnf("Neither AGENT_STATE_SECRET nor %s is set, the agent state is only obscured by the key in %s.key", cfs.AgentStateSecretFile, cfs.AgentStateFile)
+	}
+	agentStateStore, err = helpers.NewAgentStateStore(cfs.AgentStateFile, agentStateSecret)
+	if err != nil {
+		logger.Fatalf("Failed to open agent state: %v", err)
+	}
+	persistedState, err := agentStateStore.Load()
+	if err != nil {
+		logger.Errorf("Failed to restore agent state, starting with defaults: %v", err)
+	}
+	taskPayload.UserId = persistedState.UserId
+	taskPayload.BearerToken = persistedState.BearerToken
+	if persistedState.ScanInterval != 0 {
+		taskPayload.ScanInterval = persistedState.ScanInterval
+	}
+	unhealthyContainers = persistedState.UnhealthyContainers
+	jobs.RestoreUnhealthyContainers(unhealthyContainers)
+	outboundQueue, err = helpers.NewOutboundQueue(helpers.OutboundQueueOptions{
+		Dir:           cfs.OutboundQueueDir,
+		MaxSize:       cfs.OutboundQueueMaxSize,
+		MaxAge:        cfs.OutboundQueueMaxAge,
+		BatchSize:     cfs.UploadBatchSize,
+		FlushInterval: cfs.UploadFlushInterval,
+		Compression:   cfs.UploadCompression,
+	}, logger)
 	if err != nil {
-		logger.Fatalf("Failed to create job: %v", err)
+		logger.Fatalf("Failed to open outbound queue: %v", err)
 	}
-	jobId = job.ID()
+	outboundQueue.SetTaskPayload(taskPayload)
+	go outboundQueue.Run(context.Background())
+	collector = jobs.NewCollector(func(ctx context.Context, taskPayload models.TaskPayload) {
+		jobs.ScanForErrors(ctx, dockerClient, logger, outboundQueue, taskPayload)
+		persistUnhealthyContainers(jobs.UnhealthyContainers())
+	}, taskPayload, logger)
+	if persistedState.Enabled {
+		collector.Start()
+		logger.Infof("Collector restored")
+	}
+	listener, err := helpers.ListenControlApi(cfs.ControlApiSocket, cfs.ControlApiAddress)
+	if err != nil {
+		logger.Fatalf("Failed to listen for the control API: %v", err)
+	}
+	secret := ""
+	if cfs.ControlApiSocket == "" {
+		secret = cfs.ControlApiSecret
+		if secret == "" {
+			secret, err = helpers.LoadOrCreateControlApiSecret(cfs.ControlApiSecretFile)
+			if err != nil {
+				logger.Fatalf("Failed to load the control API secret: %v", err)
+			}
+			logger.Infof("Control API requires the %s header with the secret from %s", helpers.ControlApiSecretHeader, cfs.ControlApiSecretFile)
+		}
+		// The diagnostics bundle redacts the secret, also when it was generated.
+		agentConfig.ControlApiSecret = secret
+	}
+	router := newControlApiRouter(cfs.ControlApiAllowedOrigins, secret)
+	router.Listener = listener
+	logger.Fatal(router.Start(""))
+
+}
+
+// newControlApiRouter returns the router of the control API. Without a
+// secret the API is only protected by the permissions of its Unix socket.
+func newControlApiRouter(allowedOrigins []string, secret string) *echo.Echo {
 	router := echo.New()
 	router.HideBanner = true
+	router.Use(middleware.BodyLimit("16K"))
 	router.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{echo.GET, echo.PUT, echo.POST, echo.DELETE},
+		AllowOrigins: allowedOrigins,
+		AllowMethods: []string{echo.GET, echo.POST},
+		AllowHeaders: []string{echo.HeaderContentType, helpers.ControlApiSecretHeader},
 	}))
+	router.Use(helpers.RequireAllowedOrigin(allowedOrigins))
+	if secret != "" {
+		router.Use(helpers.RequireSharedSecret(secret))
+	}
+	router.Use(helpers.RequireJSONBody())
 
 	router.POST("/api/control/state", ControlPower)
 	router.GET("/api/control/state", GetState)
 	router.POST("/api/control/auth_data", ControlAuthData)
-	logger.Fatal(router.Start(":37002"))
-
+	router.GET("/api/control/settings", GetSettings)
+	router.POST("/api/control/settings", ControlSettings)
+	router.GET("/api/control/status", GetStatus)
+	router.GET("/api/control/diagnostics", GetDiagnostics)
+	return router
 }
 
 func GetState(c echo.Context) error {
 	var statePayload AgentStatePayload
-	statePayload.State = state
+	statePayload.State = collector.IsRunning()
+	statePayload.QueueDepth = outboundQueue.Depth()
 	c.JSON(200, statePayload)
 	return nil
 }
 
+func GetStatus(c echo.Context) error {
+	c.JSON(200, getAgentStatus())
+	return nil
+}
+
+// GetDiagnostics returns a support bundle with the agent status, the
+// configuration and recent agent logs, with credentials masked.
+func GetDiagnostics(c echo.Context) error {
+	secrets := []string{collector.TaskPayload().BearerToken, agentConfig.BackendApiKey, agentConfig.AgentStateSecret, agentConfig.ControlApiSecret}
+	logs := logBuffer.Lines()
+	for i := range logs {
+		for _, secret := range secrets {
+			if secret != "" {
+				logs[i] = strings.ReplaceAll(logs[i], secret, "[REDACTED]")
+			}
+		}
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="signalone-agent-diagnostics.json"`)
+	c.JSON(200, models.DiagnosticsBundle{
+		GeneratedAt: time.Now(),
+		Status:      getAgentStatus(),
+		Config:      helpers.RedactedConfig(agentConfig),
+		Logs:        logs,
+	})
+	return nil
+}
+
+func getAgentStatus() models.AgentStatus {
+	status := models.AgentStatus{
+		State:        collector.IsRunning(),
+		AgentVersion: version,
+		Scan:         jobs.GetScanStatus(),
+		Uploads:      outboundQueue.Status(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	serverVersion, err := dockerClient.ServerVersion(ctx)
+	if err != nil {
+		status.DockerError = err.Error()
+	} else {
+		status.DockerEngineVersion = serverVersion.Version
+	}
+	status.DockerApiVersion = dockerClient.ClientVersion()
+	return status
+}
+
 func ControlPower(c echo.Context) error {
-	var statePayload AgentStatePayload
-	if err := c.Bind(&statePayload); err != nil {
-		c.JSON(400, "Invalid value for on")
+	var statePayload AgentStateRequestPayload
+	if err := c.Bind(&statePayload); err != nil || statePayload.State == nil {
+		c.JSON(400, "Invalid value for state")
 		return nil
 	}
-	logger.Infof("State: %v", statePayload.State)
-	if state == statePayload.State {
+	controlMutex.Lock()
+	defer controlMutex.Unlock()
+	logger.Infof("State: %v", *statePayload.State)
+	if collector.IsRunning() == *statePayload.State {
 		c.JSON(200, "Success")
 		return nil
 	}
-	if statePayload.State {
-		state = statePayload.State
+	if *statePayload.State {
 		logger.Infof("Starting collector")
-		jobScheduler.Start()
+		collector.Start()
 		logger.Infof("Collector started")
 	} else {
-		state = statePayload.State
-		jobScheduler.StopJobs()
+		collector.Stop()
+		logger.Infof("Collector stopped")
 	}
+	saveAgentState()
 	c.JSON(200, "Success")
 	return nil
 }
@@ -100,13 +250,76 @@ func ControlAuthData(c echo.Context) error {
 		c.JSON(400, "Invalid value for token")
 		return nil
 	}
-	taskPayload.BearerToken = agentAuthDataPayload.Token
-	taskPayload.UserId = agentAuthDataPayload.UserId
-	jobScheduler.Update(
-		jobId,
-		gocron.DurationJob(time.Second*15),
-		gocron.NewTask(jobs.ScanForErrors, dockerClient, logger, taskPayload),
-	)
+	if err := helpers.ValidateAuthData(agentAuthDataPayload.UserId, agentAuthDataPayload.Token); err != nil {
+		c.JSON(400, err.Error())
+		return nil
+	}
+	controlMutex.Lock()
+	defer controlMutex.Unlock()
+	taskPayload := collector.SetAuthData(agentAuthDataPayload.UserId, agentAuthDataPayload.Token)
+	outboundQueue.SetTaskPayload(taskPayload)
+	saveAgentState()
 	c.JSON(200, "Success")
 	return nil
 }
+
+func GetSettings(c echo.Context) error {
+	c.JSON(200, AgentSettingsPayload{
+		ScanInterval: collector.TaskPayload().ScanInterval.String(),
+	})
+	return nil
+}
+
+func ControlSettings(c echo.Context) error {
+	var settingsPayload AgentSettingsPayload
+	if err := c.Bind(&settingsPayload); err != nil {
+		c.JSON(400, "Invalid settings")
+		return nil
+	}
+	scanInterval, err := time.ParseDuration(settingsPayload.ScanInterval)
+	if err != nil {
+		c.JSON(400, "Invalid value for scan_interval")
+		return nil
+	}
+	controlMutex.Lock()
+	defer controlMutex.Unlock()
+	if err := collector.SetScanInterval(scanInterval); err != nil {
+		c.JSON(400, err.Error())
+		return nil
+	}
+	saveAgentState()
+	c.JSON(200, "Success")
+	return nil
+}
+
+func saveAgentState() {
+	agentStateMutex.Lock()
+	defer agentStateMutex.Unlock()
+	writeAgentState()
+}
+
+// persistUnhealthyContainers saves the containers reported unhealthy when
+// they changed, so that their recovery is reported after the agent restarts.
+func persistUnhealthyContainers(containerIDs []string) {
+	agentStateMutex.Lock()
+	defer agentStateMutex.Unlock()
+	if strings.Join(containerIDs, ",") == strings.Join(unhealthyContainers, ",") {
+		return
+	}
+	unhealthyContainers = containerIDs
+	writeAgentState()
+}
+
+func writeAgentState() {
+	taskPayload := collector.TaskPayload()
+	err := agentStateStore.Save(models.PersistedAgentState{
+		Enabled:             collector.IsRunning(),
+		UserId:              taskPayload.UserId,
+		BearerToken:         taskPayload.BearerToken,
+		ScanInterval:        taskPayload.ScanInterval,
+		UnhealthyContainers: unhealthyContainers,
+	})
+	if err != nil {
+		logger.Errorf("Failed to persist agent state: %v", err)
+	}
+}
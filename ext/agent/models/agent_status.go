@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// DetectionSummary describes the last report raised for a container.
+type DetectionSummary struct {
+	IssueType  string    `json:"issue_type"`
+	Kind       string    `json:"kind"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+type ContainerStatus struct {
+	Id                 string            `json:"id"`
+	Name               string            `json:"name"`
+	LastScanAt         time.Time         `json:"last_scan_at"`
+	LastScanDurationMs int64             `json:"last_scan_duration_ms"`
+	SlowScans          int               `json:"slow_scans"`
+	TimedOutScans      int               `json:"timed_out_scans"`
+	MutedDetections    int               `json:"muted_detections"`
+	LastDetection      *DetectionSummary `json:"last_detection,omitempty"`
+}
+
+type ScanStatus struct {
+	LastScanAt         time.Time         `json:"last_scan_at"`
+	LastScanDurationMs int64             `json:"last_scan_duration_ms"`
+	ContainersWatched  int               `json:"containers_watched"`
+	ContainersSkipped  int               `json:"containers_skipped"`
+	Containers         []ContainerStatus `json:"containers"`
+}
+
+type OutboundQueueStatus struct {
+	QueuedUploads    int       `json:"queued_uploads"`
+	DeadLettered     int       `json:"dead_lettered"`
+	LastDeliveryAt   time.Time `json:"last_delivery_at"`
+	LastBackendError string    `json:"last_backend_error,omitempty"`
+	LastErrorAt      time.Time `json:"last_error_at"`
+}
+
+type AgentStatus struct {
+	State               bool                `json:"state"`
+	AgentVersion        string              `json:"agent_version"`
+	DockerApiVersion    string              `json:"docker_api_version"`
+	DockerEngineVersion string              `json:"docker_engine_version,omitempty"`
+	DockerError         string              `json:"docker_error,omitempty"`
+	Scan                ScanStatus          `json:"scan"`
+	Uploads             OutboundQueueStatus `json:"uploads"`
+}
+
+// DiagnosticsBundle is the support bundle returned by the control API.
+type DiagnosticsBundle struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Status      AgentStatus       `json:"status"`
+	Config      map[string]string `json:"config"`
+	Logs        []string          `json:"logs"`
+}
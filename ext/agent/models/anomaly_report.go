@@ -0,0 +1,19 @@
+package models
+
+const (
+	AnomalyKindMemoryExhaustion = "memory_exhaustion"
+	AnomalyKindCpuSaturation    = "cpu_saturation"
+	AnomalyKindPidExplosion     = "pid_explosion"
+	AnomalyKindTrafficDrop      = "traffic_drop"
+	AnomalyKindLogRateSpike     = "log_rate_spike"
+	AnomalyKindLogSilence       = "log_silence"
+)
+
+// AnomalyReport describes unusual container behaviour together with the
+// series it was detected in.
+type AnomalyReport struct {
+	Kind           string           `json:"kind"`
+	Description    string           `json:"description"`
+	ResourceSeries []ResourceSample `json:"resourceSeries,omitempty"`
+	LogRateSeries  []LogRateSample  `json:"logRateSeries,omitempty"`
+}
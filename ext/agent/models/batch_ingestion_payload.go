@@ -0,0 +1,27 @@
+package models
+
+import "encoding/json"
+
+type BatchIngestionItem struct {
+	Id      string          `json:"id"`
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type BatchIngestionPayload struct {
+	Items []BatchIngestionItem `json:"items"`
+}
+
+type BatchIngestionResult struct {
+	Id      string `json:"id"`
+	Status  int    `json:"status"`
+	IssueId string `json:"issueId,omitempty"`
+	Count   int64  `json:"count,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type BatchIngestionResponse struct {
+	Accepted int                    `json:"accepted"`
+	Rejected int                    `json:"rejected"`
+	Results  []BatchIngestionResult `json:"results"`
+}
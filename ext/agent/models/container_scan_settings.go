@@ -0,0 +1,15 @@
+package models
+
+import (
+	"regexp"
+	"time"
+)
+
+// ContainerScanSettings controls how a single container is scanned. It is
+// built from the agent defaults and the container's signalone.* labels.
+type ContainerScanSettings struct {
+	Enabled        bool
+	IgnorePatterns []*regexp.Regexp
+	SeverityFloor  string
+	ScanInterval   time.Duration
+}
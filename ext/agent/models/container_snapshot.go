@@ -0,0 +1,35 @@
+package models
+
+type ContainerMount struct {
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	ReadWrite   bool   `json:"readWrite"`
+}
+
+type ContainerResources struct {
+	MemoryBytes            int64 `json:"memoryBytes"`
+	MemoryReservationBytes int64 `json:"memoryReservationBytes"`
+	NanoCpus               int64 `json:"nanoCpus"`
+	CpuShares              int64 `json:"cpuShares"`
+	PidsLimit              int64 `json:"pidsLimit"`
+}
+
+// ContainerSnapshot is the configuration of a container at the time of a
+// detection. Environment variable values and values that look like secrets
+// are masked before the snapshot leaves the agent.
+type ContainerSnapshot struct {
+	Image             string             `json:"image"`
+	ImageId           string             `json:"imageId"`
+	ImageDigest       string             `json:"imageDigest,omitempty"`
+	Command           []string           `json:"command"`
+	Entrypoint        []string           `json:"entrypoint"`
+	Env               []string           `json:"env"`
+	Mounts            []ContainerMount   `json:"mounts"`
+	Ports             []string           `json:"ports"`
+	Networks          []string           `json:"networks"`
+	RestartPolicy     string             `json:"restartPolicy"`
+	MaximumRetryCount int                `json:"maximumRetryCount"`
+	Resources         ContainerResources `json:"resources"`
+	Labels            map[string]string  `json:"labels"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ContainerRun describes a single failed run of a restarting container.
+type ContainerRun struct {
+	ExitCode   int       `json:"exitCode"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Logs       string    `json:"logs"`
+}
+
+type CrashLoopReport struct {
+	Restarts          int            `json:"restarts"`
+	WindowSeconds     int            `json:"windowSeconds"`
+	TotalRestartCount int            `json:"totalRestartCount"`
+	StartedAt         string         `json:"startedAt"`
+	FinishedAt        string         `json:"finishedAt"`
+	Runs              []ContainerRun `json:"runs"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type HealthProbe struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exitCode"`
+	Output   string    `json:"output"`
+}
+
+// HealthReport carries the Docker healthcheck state of a container together
+// with the outputs of its last probes.
+type HealthReport struct {
+	Status        string        `json:"status"`
+	FailingStreak int           `json:"failingStreak"`
+	Probes        []HealthProbe `json:"probes"`
+}
@@ -0,0 +1,24 @@
+package models
+
+const (
+	IssueTypeError   = "ERROR"
+	IssueTypeAnomaly = "ANOMALY"
+)
+
+type LogAnalysisPayload struct {
+	UserId                 string             `json:"userId"`
+	ContainerName          string             `json:"containerName"`
+	ComposeProject         string             `json:"composeProject,omitempty"`
+	ComposeService         string             `json:"composeService,omitempty"`
+	ComposeContainerNumber int                `json:"composeContainerNumber,omitempty"`
+	Labels                 map[string]string  `json:"labels,omitempty"`
+	IssueType              string             `json:"issueType"`
+	Logs                   string             `json:"logs"`
+	ParsedLogs             []ParsedLogLine    `json:"parsedLogs"`
+	LogContext             []LogContextLine   `json:"logContext,omitempty"`
+	CrashLoop              *CrashLoopReport   `json:"crashLoop,omitempty"`
+	Termination            *TerminationReport `json:"termination,omitempty"`
+	Health                 *HealthReport      `json:"health,omitempty"`
+	Anomaly                *AnomalyReport     `json:"anomaly,omitempty"`
+	ContainerSnapshot      *ContainerSnapshot `json:"containerSnapshot,omitempty"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LogContextLine is a line of the log context sent with a detection. The
+// lines that triggered the detection are marked.
+type LogContextLine struct {
+	Timestamp time.Time `json:"timestamp"`
+	Line      string    `json:"line"`
+	IsTrigger bool      `json:"isTrigger,omitempty"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// LogEvent is a group of log lines describing a single occurrence, e.g. one
+// plain log line or a complete multi-line stack trace.
+type LogEvent struct {
+	Context      []string
+	Lines        []string
+	IsStackTrace bool
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LogRateSample is a single point of a container's log-rate history together
+// with the baseline it was compared against.
+type LogRateSample struct {
+	Timestamp      time.Time `json:"timestamp"`
+	LinesPerSecond float64   `json:"linesPerSecond"`
+	BaselineMean   float64   `json:"baselineMean"`
+	BaselineStdDev float64   `json:"baselineStdDev"`
+}
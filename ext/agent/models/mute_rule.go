@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	MuteActionDrop = "drop"
+	MuteActionMark = "mark"
+)
+
+// MuteRule silences the detections matching every criterion it sets, until
+// it expires. Detections the rule drops are not sent to the backend.
+type MuteRule struct {
+	Id            string     `json:"id"`
+	ContainerName string     `json:"containerName,omitempty"`
+	Fingerprint   string     `json:"fingerprint,omitempty"`
+	Pattern       string     `json:"pattern,omitempty"`
+	Severity      string     `json:"severity,omitempty"`
+	Action        string     `json:"action"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+}
+
+type MuteRulesResponse struct {
+	Rules []MuteRule `json:"rules"`
+}
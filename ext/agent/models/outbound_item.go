@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboundItem is a report waiting in the outbound queue to be delivered to
+// the backend.
+type OutboundItem struct {
+	Id            string          `json:"id"`
+	Kind          string          `json:"kind"`
+	Payload       json.RawMessage `json:"payload"`
+	EnqueuedAt    time.Time       `json:"enqueuedAt"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	LastError     string          `json:"lastError,omitempty"`
+}
+
+// DeadLetter is an outbound item given up on, kept for manual inspection.
+type DeadLetter struct {
+	Item           OutboundItem `json:"item"`
+	Reason         string       `json:"reason"`
+	DeadLetteredAt time.Time    `json:"deadLetteredAt"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// ParsedLogLine is the structured form of a log line recognised by one of the
+// agent's log format parsers.
+type ParsedLogLine struct {
+	Format    string            `json:"format"`
+	Level     string            `json:"level,omitempty"`
+	Message   string            `json:"message"`
+	Timestamp string            `json:"timestamp,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
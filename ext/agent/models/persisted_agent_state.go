@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PersistedAgentState is the state set through the control API, along with
+// the health reports of the scans, that is restored when the agent restarts.
+type PersistedAgentState struct {
+	Enabled     bool   `json:"enabled"`
+	UserId      string `json:"userId"`
+	BearerToken string `json:"bearerToken"`
+
+	ScanInterval time.Duration `json:"scanInterval,omitempty"`
+
+	// UnhealthyContainers are the containers reported unhealthy that have
+	// not become healthy since.
+	UnhealthyContainers []string `json:"unhealthyContainers,omitempty"`
+
+	UpdatedAt time.Time `json:"updatedAt"`
+}
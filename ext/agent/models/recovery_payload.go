@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+const (
+	RecoveryReasonHealthy   = "healthy"
+	RecoveryReasonStable    = "stable"
+	RecoveryReasonRestarted = "restarted"
+)
+
+// RecoveryPayload reports that a container recovered. Since is when the
+// recovered run of the container started, issues raised before it are
+// resolved; it is zero when the reason does not depend on a run. The Compose
+// fields identify the replica, like in detections.
+type RecoveryPayload struct {
+	UserId                 string    `json:"userId"`
+	ContainerName          string    `json:"containerName"`
+	ComposeProject         string    `json:"composeProject,omitempty"`
+	ComposeService         string    `json:"composeService,omitempty"`
+	ComposeContainerNumber int       `json:"composeContainerNumber,omitempty"`
+	Reason                 string    `json:"reason"`
+	Since                  time.Time `json:"since"`
+}
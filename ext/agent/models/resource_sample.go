@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ResourceSample is a single point of a container's resource usage series.
+// Rates are computed against the previous sample.
+type ResourceSample struct {
+	Timestamp                time.Time `json:"timestamp"`
+	CpuPercent               float64   `json:"cpuPercent"`
+	CpuLimitPercent          float64   `json:"cpuLimitPercent"`
+	MemoryUsageBytes         uint64    `json:"memoryUsageBytes"`
+	MemoryLimitBytes         uint64    `json:"memoryLimitBytes"`
+	NetworkRxBytesPerSecond  float64   `json:"networkRxBytesPerSecond"`
+	NetworkTxBytesPerSecond  float64   `json:"networkTxBytesPerSecond"`
+	BlockReadBytesPerSecond  float64   `json:"blockReadBytesPerSecond"`
+	BlockWriteBytesPerSecond float64   `json:"blockWriteBytesPerSecond"`
+	Pids                     uint64    `json:"pids"`
+	PidsLimit                uint64    `json:"pidsLimit"`
+}
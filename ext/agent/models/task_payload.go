@@ -1,7 +1,24 @@
 package models
 
+import "time"
+
 type TaskPayload struct {
 	BearerToken string
 	BackendUrl  string
 	UserId      string
+	ScanMode    string
+
+	ScanInterval         time.Duration
+	ScanTimeout          time.Duration
+	ContainerScanTimeout time.Duration
+	ScanWorkers          int
+
+	CrashLoopRestartThreshold int
+	CrashLoopWindow           time.Duration
+
+	RecoveryStabilityWindow time.Duration
+
+	LogContextLinesBefore int
+	LogContextTimeBefore  time.Duration
+	LogContextLinesAfter  int
 }
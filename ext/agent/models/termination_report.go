@@ -0,0 +1,17 @@
+package models
+
+// TerminationReport describes why a container stopped, based on the state
+// reported by the Docker engine.
+type TerminationReport struct {
+	ExitCode             int    `json:"exitCode"`
+	ExitCodeMeaning      string `json:"exitCodeMeaning"`
+	Signal               string `json:"signal,omitempty"`
+	OOMKilled            bool   `json:"oomKilled"`
+	Error                string `json:"error,omitempty"`
+	FinishedAt           string `json:"finishedAt"`
+	MemoryLimitBytes     int64  `json:"memoryLimitBytes"`
+	LastMemoryUsageBytes uint64 `json:"lastMemoryUsageBytes"`
+	RestartPolicy        string `json:"restartPolicy"`
+	MaximumRetryCount    int    `json:"maximumRetryCount"`
+	RestartCount         int    `json:"restartCount"`
+}
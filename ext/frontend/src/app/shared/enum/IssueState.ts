@@ -0,0 +1,8 @@
+export enum IssueState {
+  OPEN = 'open',
+  ACKNOWLEDGED = 'acknowledged',
+  IN_PROGRESS = 'inProgress',
+  RESOLVED = 'resolved',
+  IGNORED = 'ignored',
+  REOPENED = 'reopened'
+}
@@ -21,6 +21,12 @@ export class AuthInterceptor implements HttpInterceptor {
       });
 
       return next.handle(authorizedRequest);
+    } else if (environment.agentApiSecret && request.url?.indexOf(environment.agentApiUrl) > -1) {
+      return next.handle(request.clone({
+        setHeaders: {
+          'X-Signalone-Agent-Secret': environment.agentApiSecret
+        }
+      }));
     } else {
       return next.handle(request);
     }
@@ -4,9 +4,28 @@ export class DetailedIssueDTO extends IssueDTO {
   public logSummary : string;
   public userId: string;
   public logs: string[];
+  public logContext?: LogContextLine[];
+  public comments: IssueComment[];
   public score: DetailedIssueScore;
   public predictedSolutionsSummary: string;
   public issuePredictedSolutionsSources: string[];
 }
 
+export interface LogContextLine {
+  timestamp: string;
+  line: string;
+  isTrigger?: boolean;
+}
+
+export interface IssueComment {
+  id: string;
+  parentId?: string;
+  authorId: string;
+  authorName: string;
+  body: string;
+  isDeleted?: boolean;
+  createdAt: string;
+  updatedAt?: string;
+}
+
 export type DetailedIssueScore = -1 | 0 | 1;
\ No newline at end of file
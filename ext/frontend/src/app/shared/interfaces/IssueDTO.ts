@@ -1,11 +1,18 @@
 import { IssueType } from 'app/shared/enum/IssueType';
 import { IssueSeverity } from 'app/shared/enum/IssueSeverity';
+import { IssueState } from 'app/shared/enum/IssueState';
 
 export class IssueDTO {
   public id: string;
   public containerName: string
+  public composeProject: string;
+  public composeService: string;
   public title: string;
   public severity: IssueSeverity;
   public isResolved: boolean;
+  public state: IssueState;
   public timestamp: string;
+  public lastActivityAt: string;
+  public assignee?: string;
+  public tags?: string[];
 }
\ No newline at end of file
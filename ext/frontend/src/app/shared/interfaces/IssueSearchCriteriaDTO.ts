@@ -5,6 +5,8 @@ import { PaginationCriteriaDTO } from 'app/shared/interfaces/PaginationCriteriaD
 export class IssueSearchCriteriaDTO extends PaginationCriteriaDTO{
   public searchString: string;
   public container: string;
+  public composeProject: string;
+  public composeService: string;
   public issueType: IssueType;
   public issueSeverity: IssueSeverity
   public startTimestamp: string;
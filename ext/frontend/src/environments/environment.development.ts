@@ -4,6 +4,7 @@ export const environment = {
   apiUrl: 'http://localhost:8080/api',
   authUrl: 'http://localhost:8080/api/auth',
   agentApiUrl: 'http://localhost:37002/api',
+  agentApiSecret: '',
   googleLoginProvider: '359898712853-rcec16l3ivs24rod4hb2kcsr9qvotf3t.apps.googleusercontent.com',
   githubClientId: '6c88a4f9d4868879974e'
 };
@@ -0,0 +1,38 @@
+#!/bin/sh
+# Configures the proxy to the agent control API, which adds the secret of
+# the control API to every request of the UI, so the secret never leaves the
+# web server. The secret is AGENT_API_SECRET when set, otherwise the one the
+# agent generated into its data volume, which may take a moment to appear on
+# the first start.
+set -e
+
+secret_file="${AGENT_API_SECRET_FILE:-/agent-data/control_api_secret}"
+agent_url="${AGENT_API_URL:-http://signaloneagent:37002}"
+config_file=/etc/nginx/agent-api.conf
+
+secret="$AGENT_API_SECRET"
+if [ -z "$secret" ]; then
+    attempt=0
+    while [ ! -s "$secret_file" ] && [ "$attempt" -lt 30 ]; do
+        attempt=$((attempt + 1))
+        sleep 1
+    done
+    if [ -s "$secret_file" ]; then
+        secret=$(tr -d '[:space:]' < "$secret_file")
+    fi
+fi
+
+umask 077
+printf 'proxy_pass %s/api/;\n' "$agent_url" > "$config_file"
+case "$secret" in
+    "")
+        echo "agent-config: no secret in $secret_file, requests to the agent will be rejected" >&2
+        ;;
+    *[!A-Za-z0-9_-]*)
+        echo "agent-config: the agent secret may only contain letters, digits, '_' and '-'" >&2
+        exit 1
+        ;;
+    *)
+        printf 'proxy_set_header X-Signalone-Agent-Secret "%s";\n' "$secret" >> "$config_file"
+        ;;
+esac